@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/aslinger/s3-tidy/pkg/cost"
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/aslinger/s3-tidy/pkg/signing"
+	"github.com/spf13/cobra"
+)
+
+// Apply Flags
+var (
+	applyPlan        string
+	applyPlanKey     string
+	applyApprovalKey string
+)
+
+func newApplyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Delete the objects of an approved plan",
+		Long: `Deletes exactly the objects a plan lists, once both its signature and its
+approval verify and the approval came from another key and person than the
+plan. Each object is re-read first; one that is missing, or whose size or
+last-modified time changed since planning, is skipped. Runs go through the
+same dry run, batching, pre-delete hooks, output files, notifications and
+history as a scan.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := resolvePricing(cmd, cost.Pricing{}); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			if err := runApply(context.Background()); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+		},
+	}
+	cmd.Flags().StringVar(&applyPlan, "plan", "", "Plan written by 'plan --out' and approved with 'approve' (required)")
+	cmd.Flags().StringVar(&applyPlanKey, "plan-key", "", "Planner's Ed25519 public key (PEM) (required)")
+	cmd.Flags().StringVar(&applyApprovalKey, "approval-key", "", "Approver's Ed25519 public key (PEM) (required)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", true, "Simulate deletion without taking action")
+	cmd.Flags().StringVar(&verifyDelete, "verify-before-delete", "etag", "Guard against objects rewritten since they were read: etag (conditional delete), head (HeadObject re-check) or none")
+	cmd.Flags().IntVar(&deleteBatchSize, "delete-batch-size", scanner.MaxDeleteBatch, "Keys per DeleteObjects request (1-1000)")
+
+	addOutputFlags(cmd)
+	addSinkFlags(cmd)
+	addHistoryFlags(cmd)
+	addPricingFlags(cmd)
+	addProviderFlags(cmd)
+	addNotifyFlags(cmd)
+	addTelemetryFlags(cmd)
+	cmd.MarkFlagRequired("plan")
+	cmd.MarkFlagRequired("plan-key")
+	cmd.MarkFlagRequired("approval-key")
+	return cmd
+}
+
+func runApply(ctx context.Context) error {
+	planVerifier, err := signing.LoadKey(applyPlanKey)
+	if err != nil {
+		return err
+	}
+	approvalVerifier, err := signing.LoadKey(applyApprovalKey)
+	if err != nil {
+		return err
+	}
+	manifest, sig, err := planVerifier.VerifyFile(applyPlan, "")
+	if err != nil {
+		return fmt.Errorf("%s: %w", applyPlan, err)
+	}
+	approval, err := approvalVerifier.VerifyApprovalFile(applyPlan, sig)
+	if err != nil {
+		return fmt.Errorf("%s: %w", applyPlan, err)
+	}
+	if err := signing.FourEyes(sig, approval); err != nil {
+		return fmt.Errorf("%s: %w", applyPlan, err)
+	}
+	// Act on the bytes that verified, not on a second read of the file.
+	bucket, objects, err := scanner.PlannedObjects(manifest)
+	if err != nil {
+		return fmt.Errorf("%s: %w", applyPlan, err)
+	}
+	if len(objects) == 0 {
+		return fmt.Errorf("%s: the plan has no objects to delete", applyPlan)
+	}
+	fmt.Fprintf(progressWriter(), "🤝 Plan signed by %s on %s, approved by %s on %s\n", sig.Signer, sig.Signed.Format("2006-01-02 15:04 MST"), approval.Signer, approval.Signed.Format("2006-01-02 15:04 MST"))
+
+	opts := scanner.Options{
+		Bucket:         bucket,
+		DryRun:         dryRun,
+		Verify:         scanner.Verify(verifyDelete),
+		Pricing:        pricing,
+		RedactKeys:     redaction(),
+		Out:            progressWriter(),
+		BatchSize:      deleteBatchSize,
+		PreDeleteHooks: preDeleteHooks(),
+	}
+	return runDeletion(ctx, opts, func(ctx context.Context, sc *scanner.Scanner, opts scanner.Options) (*scanner.Result, error) {
+		return sc.ApplyPlan(ctx, opts, objects)
+	})
+}
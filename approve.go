@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aslinger/s3-tidy/internal/humanize"
+	"github.com/aslinger/s3-tidy/pkg/policy"
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/aslinger/s3-tidy/pkg/signing"
+	"github.com/spf13/cobra"
+)
+
+// Approve Flags
+var (
+	approvePlan     string
+	approvePlanKey  string
+	approveKey      string
+	approveIdentity string
+	approveListKeys bool
+	approveYes      bool
+)
+
+func newApproveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "approve",
+		Short: "Countersign a plan so that apply may execute it",
+		Long: `Verifies a plan written by 'plan' against the planner's public key, shows
+what it deletes by top-level prefix (every key with --list-keys), asks for
+confirmation, and countersigns it with your own Ed25519 key and identity (the
+AWS caller ARN, or --identity), writing <plan>.approval. Approving with the
+planner's key or as the planner is refused: a plan needs two people.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runApprove(context.Background(), os.Stdin, os.Stdout); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+		},
+	}
+	cmd.Flags().StringVar(&approvePlan, "plan", "", "Plan written by 'plan --out' (required)")
+	cmd.Flags().StringVar(&approvePlanKey, "plan-key", "", "Planner's Ed25519 public key (PEM) to verify the plan with (required)")
+	cmd.Flags().StringVar(&approveKey, "key", "", "Your Ed25519 private key (PEM) to approve with (required)")
+	cmd.Flags().StringVar(&approveIdentity, "identity", "", "Who is approving, recorded in the approval (default: the AWS caller ARN)")
+	cmd.Flags().BoolVar(&approveListKeys, "list-keys", false, "Print every key the plan deletes, not only the per-prefix totals")
+	cmd.Flags().BoolVarP(&approveYes, "yes", "y", false, "Approve without asking for confirmation")
+	addProviderFlags(cmd)
+	cmd.MarkFlagRequired("plan")
+	cmd.MarkFlagRequired("plan-key")
+	cmd.MarkFlagRequired("key")
+	return cmd
+}
+
+func runApprove(ctx context.Context, in io.Reader, out io.Writer) error {
+	planVerifier, err := signing.LoadKey(approvePlanKey)
+	if err != nil {
+		return err
+	}
+	key, err := signing.LoadKey(approveKey)
+	if err != nil {
+		return err
+	}
+	if !key.CanSign() {
+		return fmt.Errorf("--key is a public key; approve with the private key")
+	}
+	manifest, sig, err := planVerifier.VerifyFile(approvePlan, "")
+	if err != nil {
+		return fmt.Errorf("%s: %w", approvePlan, err)
+	}
+	bucket, objects, err := scanner.PlannedObjects(manifest)
+	if err != nil {
+		return fmt.Errorf("%s: %w", approvePlan, err)
+	}
+	if len(objects) == 0 {
+		return fmt.Errorf("%s: the plan has no objects to delete", approvePlan)
+	}
+	who, err := principal(ctx, approveIdentity)
+	if err != nil {
+		return err
+	}
+	// Check before signing, so a refused approval leaves no file behind.
+	if err := signing.FourEyes(sig, &signing.Signature{Algorithm: key.Algorithm(), KeyID: key.ID(), Signer: who}); err != nil {
+		return err
+	}
+
+	printPlan(out, bucket, objects, sig)
+	if !approveYes {
+		fmt.Fprintf(out, "   Approve deleting these objects as %s? [y/N] ", who)
+		answer, _ := bufio.NewReader(in).ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+		default:
+			// EOF on stdin included: never assume consent.
+			fmt.Fprintln(out, "\n🛑 Not approved.")
+			return nil
+		}
+	}
+	approval, err := key.ApproveFile(approvePlan, sig, who, time.Now())
+	if err != nil {
+		return fmt.Errorf("unable to approve plan: %w", err)
+	}
+	fmt.Fprintf(out, "✅ Approved by %s (%s, key %s): %s\n", who, approval.Algorithm, approval.KeyID, approvePlan+signing.ApprovalSuffix)
+	return nil
+}
+
+// printPlan shows a reviewer what a plan deletes: its totals by top-level
+// prefix, largest first, and with --list-keys every object.
+func printPlan(out io.Writer, bucket string, objects []scanner.ManifestObject, sig *signing.Signature) {
+	type prefixTotal struct {
+		prefix  string
+		objects int
+		bytes   int64
+	}
+	byPrefix := map[string]*prefixTotal{}
+	var total int64
+	for _, o := range objects {
+		p := policy.GroupKey(o.Key, 1)
+		t := byPrefix[p]
+		if t == nil {
+			t = &prefixTotal{prefix: p}
+			byPrefix[p] = t
+		}
+		t.objects++
+		t.bytes += o.Size
+		total += o.Size
+	}
+	rows := make([]*prefixTotal, 0, len(byPrefix))
+	for _, t := range byPrefix {
+		rows = append(rows, t)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].bytes != rows[j].bytes {
+			return rows[i].bytes > rows[j].bytes
+		}
+		return rows[i].prefix < rows[j].prefix
+	})
+
+	fmt.Fprintf(out, "🔍 %s: %d objects (%s) in s3://%s, planned %s by %s\n", approvePlan, len(objects), humanize.Bytes(total), bucket, sig.Signed.Format("2006-01-02 15:04 MST"), sig.Signer)
+	for _, t := range rows {
+		name := t.prefix
+		if name == "" {
+			name = "(bucket root)"
+		}
+		fmt.Fprintf(out, "   📁 %s: %d objects (%s)\n", name, t.objects, humanize.Bytes(t.bytes))
+	}
+	if approveListKeys {
+		for _, o := range objects {
+			fmt.Fprintf(out, "   • %s (%s, %s)\n", o.Key, humanize.Bytes(o.Size), o.LastModified.Format("2006-01-02"))
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aslinger/s3-tidy/internal/humanize"
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+)
+
+// exitStaleBudgetExceeded is returned when a run finds more stale storage than
+// --fail-if-stale-bytes/--fail-if-stale-count allow. It differs from the generic
+// failure code 1 so pipelines can tell "dirty bucket" from "tool broke".
+const exitStaleBudgetExceeded = 3
+
+// staleBudget is the storage-hygiene gate for CI. Zero values disable a limit.
+type staleBudget struct {
+	MaxBytes int64
+	MaxCount int
+}
+
+// Check returns a human-readable violation for each exceeded limit.
+func (b staleBudget) Check(res *scanner.Result) []string {
+	var violations []string
+	if b.MaxBytes > 0 && res.StaleBytes > b.MaxBytes {
+		violations = append(violations, fmt.Sprintf("stale bytes %s exceed budget of %s", humanize.Bytes(res.StaleBytes), humanize.Bytes(b.MaxBytes)))
+	}
+	if b.MaxCount > 0 && res.Stale > b.MaxCount {
+		violations = append(violations, fmt.Sprintf("stale objects %d exceed budget of %d", res.Stale, b.MaxCount))
+	}
+	return violations
+}
+
+// parseSize reads sizes such as "500MB", "1.5TiB" or a plain byte count.
+// Decimal (KB, MB, GB, TB) and binary (KiB, MiB, GiB, TiB) units are both
+// accepted, since budgets come from people who use either.
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	units := []struct {
+		suffix string
+		mult   float64
+	}{
+		{"kib", 1 << 10}, {"mib", 1 << 20}, {"gib", 1 << 30}, {"tib", 1 << 40}, {"pib", 1 << 50},
+		{"kb", 1e3}, {"mb", 1e6}, {"gb", 1e9}, {"tb", 1e12}, {"pb", 1e15},
+		{"k", 1 << 10}, {"m", 1 << 20}, {"g", 1 << 30}, {"t", 1 << 40},
+		{"b", 1},
+	}
+
+	lower := strings.ToLower(s)
+	mult := 1.0
+	for _, u := range units {
+		if strings.HasSuffix(lower, u.suffix) {
+			lower = strings.TrimSpace(strings.TrimSuffix(lower, u.suffix))
+			mult = u.mult
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(lower, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (expected e.g. 500GB, 1.5TiB or a byte count)", s)
+	}
+	return int64(n * mult), nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aslinger/s3-tidy/internal/humanize"
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+// exitBucketsDiffer is returned when compare finds an object of the first
+// bucket missing or different in the second, so a pipeline can refuse to
+// delete from the source without parsing the output.
+const exitBucketsDiffer = 4
+
+// Compare Flags
+var (
+	compareBuckets     []string
+	compareJSON        bool
+	compareConcurrency int
+)
+
+func newCompareCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compare",
+		Short: "List keys that differ between two buckets",
+		Long: `Lists both buckets (--bucket A --bucket B) side by side and reports keys only
+in one of them, keys whose size differs, and keys modified in A after B's copy
+was made. Use it to check that an archive bucket fully mirrors its source
+before deleting from it: the command exits with code 4 unless every object of
+A is in B at the same size and no older. Extra objects in B are reported but
+don't fail it.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(compareBuckets) != 2 {
+				log.Fatalf("❌ compare needs exactly two --bucket flags (got %d)", len(compareBuckets))
+			}
+			res, err := runCompare(context.Background())
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			if !res.Mirrors() {
+				os.Exit(exitBucketsDiffer)
+			}
+		},
+	}
+	cmd.Flags().StringArrayVarP(&compareBuckets, "bucket", "b", nil, "Bucket to compare; give it twice, the source first (required)")
+	cmd.Flags().BoolVar(&compareJSON, "json", false, "Print each difference, then the totals, as JSON lines")
+	cmd.Flags().IntVar(&compareConcurrency, "list-concurrency", scanner.DefaultListConcurrency, "Top-level prefixes listed in parallel per bucket (1 = a single ListObjectsV2 stream)")
+	addProviderFlags(cmd)
+	cmd.MarkFlagRequired("bucket")
+	return cmd
+}
+
+func runCompare(ctx context.Context) (*scanner.Comparison, error) {
+	sc, err := newScanner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a, b := compareBuckets[0], compareBuckets[1]
+	enc := json.NewEncoder(os.Stdout)
+	if !compareJSON {
+		fmt.Printf("🔍 Comparing 's3://%s' with 's3://%s'...\n", a, b)
+	}
+	res, err := sc.Compare(ctx, scanner.CompareOptions{A: a, B: b, ListConcurrency: compareConcurrency}, func(d scanner.Difference) {
+		if compareJSON {
+			enc.Encode(d)
+			return
+		}
+		switch d.Kind {
+		case scanner.OnlyInA:
+			fmt.Printf("➖ ONLY IN s3://%s: %s (%s)\n", a, d.Key, humanize.Bytes(d.A.Size))
+		case scanner.OnlyInB:
+			fmt.Printf("➕ ONLY IN s3://%s: %s (%s)\n", b, d.Key, humanize.Bytes(d.B.Size))
+		case scanner.SizeDiffers:
+			fmt.Printf("📏 SIZE DIFFERS: %s (%d bytes in %s, %d in %s)\n", d.Key, d.A.Size, a, d.B.Size, b)
+		case scanner.NewerInA:
+			fmt.Printf("🕒 MODIFIED SINCE COPIED: %s (%s in %s, copy from %s)\n", d.Key, d.A.LastModified.Format("2006-01-02 15:04 MST"), a, d.B.LastModified.Format("2006-01-02 15:04 MST"))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if compareJSON {
+		return res, enc.Encode(res)
+	}
+
+	fmt.Println("------------------------------------------------")
+	fmt.Println("📊 BUCKET COMPARISON")
+	fmt.Printf("   • Objects in s3://%s: %d (%s)\n", a, res.ObjectsA, humanize.Bytes(res.BytesA))
+	fmt.Printf("   • Objects in s3://%s: %d (%s)\n", b, res.ObjectsB, humanize.Bytes(res.BytesB))
+	fmt.Printf("   • Only in s3://%s: %d\n", a, res.OnlyInA)
+	fmt.Printf("   • Only in s3://%s: %d\n", b, res.OnlyInB)
+	fmt.Printf("   • Size Differs: %d\n", res.SizeDiffers)
+	fmt.Printf("   • Modified Since Copied: %d\n", res.NewerInA)
+	if res.Mirrors() {
+		fmt.Printf("✅ s3://%s fully mirrors s3://%s.\n", b, a)
+	} else {
+		fmt.Printf("❌ s3://%s does not mirror s3://%s: %d objects are missing or differ.\n", b, a, res.OnlyInA+res.SizeDiffers+res.NewerInA)
+	}
+	return res, nil
+}
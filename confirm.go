@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aslinger/s3-tidy/internal/humanize"
+	"github.com/aslinger/s3-tidy/pkg/policy"
+)
+
+// prefixConfirmer asks the operator before acting on each top-level prefix.
+// ListObjectsV2 returns keys in lexicographic order, so every prefix arrives as
+// one contiguous run and only the current prefix has to be held in memory.
+// Keys at the bucket root interleave with prefixes and are asked about last.
+type prefixConfirmer struct {
+	in  *bufio.Reader
+	out io.Writer
+	now time.Time
+	act func(policy.Candidate)
+	// done runs after an approved prefix, so its deletions go out before the
+	// next question rather than at the end of the run.
+	done func()
+
+	current *reviewGroup
+	root    *reviewGroup
+	quit    bool
+
+	Approved int
+	Skipped  int
+}
+
+func newPrefixConfirmer(in io.Reader, out io.Writer, now time.Time, act func(policy.Candidate), done func()) *prefixConfirmer {
+	return &prefixConfirmer{
+		in:   bufio.NewReader(in),
+		out:  out,
+		now:  now,
+		act:  act,
+		done: done,
+		root: &reviewGroup{},
+	}
+}
+
+func (p *prefixConfirmer) Add(c policy.Candidate) {
+	prefix := policy.GroupKey(c.Key, 1)
+	if prefix == "" {
+		p.root.add(c)
+		return
+	}
+	if p.current == nil || p.current.Prefix != prefix {
+		p.flush(p.current)
+		p.current = &reviewGroup{Prefix: prefix}
+	}
+	p.current.add(c)
+}
+
+// Finish prompts for whatever is still buffered.
+func (p *prefixConfirmer) Finish() {
+	p.flush(p.current)
+	p.current = nil
+	p.flush(p.root)
+	p.root = &reviewGroup{}
+	fmt.Fprintf(p.out, "\n👀 Operator approved %d prefixes and skipped %d.\n", p.Approved, p.Skipped)
+}
+
+func (p *prefixConfirmer) flush(g *reviewGroup) {
+	if g == nil || len(g.Objects) == 0 {
+		return
+	}
+	if p.quit {
+		p.Skipped++
+		return
+	}
+
+	name := g.Prefix
+	if name == "" {
+		name = "(bucket root)"
+	}
+	fmt.Fprintf(p.out, "\n📁 %s\n", name)
+	fmt.Fprintf(p.out, "   • Stale Objects: %d\n", len(g.Objects))
+	fmt.Fprintf(p.out, "   • Reclaimable: %s (~%s/month)\n", humanize.Bytes(g.Bytes), pricing.Format(g.Savings, 4))
+	fmt.Fprintf(p.out, "   • Age Range: %s – %s\n", humanize.Age(p.now.Sub(g.Newest)), humanize.Age(p.now.Sub(g.Oldest)))
+	fmt.Fprint(p.out, "   Proceed with this prefix? [y/N/q] ")
+
+	answer, err := p.in.ReadString('\n')
+	if err != nil && answer == "" {
+		// EOF on stdin: never assume consent.
+		fmt.Fprintln(p.out)
+		p.quit = true
+		p.Skipped++
+		return
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		p.Approved++
+		for _, c := range g.Objects {
+			p.act(c)
+		}
+		if p.done != nil {
+			p.done()
+		}
+	case "q", "quit":
+		p.quit = true
+		p.Skipped++
+	default:
+		p.Skipped++
+	}
+}
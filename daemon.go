@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aslinger/s3-tidy/pkg/history"
+	"github.com/aslinger/s3-tidy/pkg/notify"
+	"github.com/aslinger/s3-tidy/pkg/policy"
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+)
+
+// Daemon Flags
+var (
+	daemonSchedule    string
+	daemonConfig      string
+	daemonHistoryFile string
+	daemonRunNow      bool
+	daemonMetricsAddr string
+)
+
+func newDaemonCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Run configured policies on a cron schedule as a long-lived process",
+		Long: `Runs every policy in --config on --schedule until SIGINT/SIGTERM.
+Each run's summary is appended as a JSON line to --history-file, so run history
+survives container restarts when the file lives on a volume.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runDaemon(cmd); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&daemonSchedule, "schedule", "0 3 * * *", "Cron expression (5 fields, or @daily/@every 6h)")
+	cmd.Flags().StringVarP(&daemonConfig, "config", "c", "", "Path to policies.yaml (required)")
+	cmd.Flags().StringVar(&daemonHistoryFile, "history-file", "s3-tidy-history.jsonl", "Append each run's summary here as JSON lines (empty to disable)")
+	cmd.Flags().BoolVar(&daemonRunNow, "run-now", false, "Run all policies once at startup before waiting for the schedule")
+	cmd.Flags().StringVar(&daemonMetricsAddr, "metrics-addr", "", "Serve Prometheus metrics on this address (e.g. :9102); disabled when empty")
+	addOutputFlags(cmd)
+	addNotifyFlags(cmd)
+	addHistoryFlags(cmd)
+	addPricingFlags(cmd)
+	addProviderFlags(cmd)
+	addTelemetryFlags(cmd)
+	cmd.MarkFlagRequired("config")
+	return cmd
+}
+
+func runDaemon(cmd *cobra.Command) error {
+	pf, err := policy.LoadFile(daemonConfig)
+	if err != nil {
+		return err
+	}
+	if err := resolvePricing(cmd, pf.Pricing); err != nil {
+		return err
+	}
+
+	loc, err := policy.LoadTimezone(pf.Timezone)
+	if err != nil {
+		return err
+	}
+
+	out := progressWriter()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	tel, err := startTelemetry(ctx)
+	if err != nil {
+		return err
+	}
+	defer tel.shutdown()
+
+	var metrics *runMetrics
+	if daemonMetricsAddr != "" {
+		metrics = newRunMetrics()
+		metrics.serve(daemonMetricsAddr)
+		fmt.Fprintf(out, "📈 Serving Prometheus metrics on %s/metrics\n", daemonMetricsAddr)
+	}
+
+	sc, err := newScanner(ctx)
+	if err != nil {
+		return err
+	}
+	notifiers, err := buildNotifiers(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to set up notifications: %w", err)
+	}
+	store, err := openHistory()
+	if err != nil {
+		return err
+	}
+	if store != nil {
+		defer store.Close()
+	}
+
+	// A slow sweep must never overlap with the next tick on the same buckets.
+	logger := cron.PrintfLogger(log.Default())
+	c := cron.New(cron.WithLocation(loc), cron.WithChain(cron.SkipIfStillRunning(logger)))
+	if _, err := c.AddFunc(daemonSchedule, func() { runPolicies(ctx, sc, pf, metrics, notifiers, store) }); err != nil {
+		return fmt.Errorf("invalid --schedule %q: %w", daemonSchedule, err)
+	}
+
+	fmt.Fprintf(out, "⏰ s3-tidy daemon started: %d policies on schedule %q (%s)\n", len(pf.Policies), daemonSchedule, loc)
+	if daemonRunNow {
+		runPolicies(ctx, sc, pf, metrics, notifiers, store)
+	}
+
+	c.Start()
+	if next := c.Entries(); len(next) > 0 {
+		fmt.Fprintf(out, "   Next run at %s\n", next[0].Next.Format(time.RFC3339))
+	}
+
+	<-ctx.Done()
+	fmt.Fprintln(out, "🛑 Shutting down, waiting for the current run to finish...")
+	<-c.Stop().Done()
+	return nil
+}
+
+// runPolicies executes every policy once. One policy failing never stops the
+// others; the failure is recorded in the run history instead.
+func runPolicies(ctx context.Context, sc *scanner.Scanner, pf *policy.File, metrics *runMetrics, notifiers []notify.Notifier, store *history.Store) {
+	for _, p := range pf.Policies {
+		if ctx.Err() != nil {
+			return
+		}
+
+		started := time.Now()
+		prefixes := prefixStats(store)
+		res, err := runPolicy(ctx, sc, p, started, notify.BatchNotifiersOf(notifiers), sinksFor(nil, prefixes))
+		if err != nil {
+			log.Printf("⚠️ Policy %q failed: %v\n", p.Name, err)
+			res = &scanner.Result{Policy: p.Name, Bucket: p.Bucket, Started: started, Finished: time.Now(), Errors: 1}
+		}
+		metrics.observe(res, err)
+		if err == nil {
+			notify.All(ctx, notifiers, res)
+		}
+		if err := appendHistory(daemonHistoryFile, res, err); err != nil {
+			log.Printf("⚠️ Unable to record run history: %v\n", err)
+		}
+		recordHistory(ctx, store, res, err, prefixes)
+	}
+}
+
+func runPolicy(ctx context.Context, sc *scanner.Scanner, p policy.Policy, now time.Time, batchNotifiers []scanner.BatchNotifier, sinks []scanner.Sink) (*scanner.Result, error) {
+	opts, err := scanOptions(p, now)
+	if err != nil {
+		return nil, err
+	}
+	opts.BatchNotifiers = batchNotifiers
+	opts.Sinks = sinks
+	opts.PreDeleteHooks = preDeleteHooks()
+	opts.Out = progressWriter()
+	fmt.Fprintf(opts.Out, "\n▶️ Running policy %q\n", p.Name)
+	res, err := sc.Run(ctx, opts)
+	printRunSummary(os.Stdout, res)
+	return res, err
+}
+
+// historyRecord is one line of the daemon's run history file.
+type historyRecord struct {
+	*scanner.Result
+	Error string `json:"error,omitempty"`
+}
+
+func appendHistory(path string, res *scanner.Result, runErr error) error {
+	if path == "" {
+		return nil
+	}
+
+	rec := historyRecord{Result: res}
+	if runErr != nil {
+		rec.Error = runErr.Error()
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/aslinger/s3-tidy/pkg/cost"
+	"github.com/aslinger/s3-tidy/pkg/notify"
+	"github.com/aslinger/s3-tidy/pkg/policy"
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+// Delete Flags
+var keysFile string
+
+func newDeleteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete exactly the keys listed in a file or on stdin",
+		Long: `Deletes the objects named in --keys-file ('-' for stdin), one key per line,
+optionally followed by a tab and a version ID, instead of scanning for stale
+objects. Each key is re-read before it is deleted and goes through the same
+dry run, batching, exclusions, --max-delete cap, pre-delete hooks, output files,
+notifications and history as a scan. Missing keys are reported and skipped.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := resolvePricing(cmd, cost.Pricing{}); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			if err := runDelete(context.Background()); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+		},
+	}
+	cmd.Flags().StringVarP(&bucketName, "bucket", "b", "", "Target S3 bucket name (required)")
+	cmd.Flags().StringVar(&bucketName, "container", "", "Target Azure container, the --bucket of --provider azure")
+	cmd.MarkFlagsMutuallyExclusive("bucket", "container")
+	cmd.Flags().StringVar(&keysFile, "keys-file", "", "File of keys to delete, one per line with an optional tab and version ID; '-' reads stdin (required)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", true, "Simulate deletion without taking action")
+	cmd.Flags().StringVar(&excludeFile, "exclude-file", "", "File of exact keys and glob patterns never to delete, one per line")
+	cmd.Flags().IntVar(&maxDelete, "max-delete", 0, "Delete at most this many of the listed objects per run")
+	cmd.Flags().StringVar(&verifyDelete, "verify-before-delete", "etag", "Guard against objects rewritten since they were read: etag (conditional delete), head (HeadObject re-check) or none")
+	cmd.Flags().StringVar(&sseCKeyFile, "sse-c-key-file", "", "File holding the 32-byte SSE-C key (raw or base64) for customer-key encrypted objects (env S3TIDY_SSE_C_KEY, base64)")
+	cmd.Flags().IntVar(&deleteBatchSize, "delete-batch-size", scanner.MaxDeleteBatch, "Keys per DeleteObjects request (1-1000)")
+
+	addOutputFlags(cmd)
+	addSinkFlags(cmd)
+	addHistoryFlags(cmd)
+	addPricingFlags(cmd)
+	addProviderFlags(cmd)
+	addNotifyFlags(cmd)
+	addTelemetryFlags(cmd)
+	cmd.MarkFlagsOneRequired("bucket", "container")
+	cmd.MarkFlagRequired("keys-file")
+	return cmd
+}
+
+func runDelete(ctx context.Context) error {
+	var keys io.Reader = os.Stdin
+	if keysFile != "-" {
+		f, err := os.Open(keysFile)
+		if err != nil {
+			return fmt.Errorf("unable to read key list: %w", err)
+		}
+		defer f.Close()
+		keys = f
+	}
+	opts := scanner.Options{
+		Bucket:         bucketName,
+		DryRun:         dryRun,
+		MaxDelete:      maxDelete,
+		Verify:         scanner.Verify(verifyDelete),
+		Pricing:        pricing,
+		RedactKeys:     redaction(),
+		Out:            progressWriter(),
+		BatchSize:      deleteBatchSize,
+		PreDeleteHooks: preDeleteHooks(),
+	}
+	if excludeFile != "" {
+		excludes, err := policy.LoadExcludeFile(excludeFile)
+		if err != nil {
+			return fmt.Errorf("unable to load exclude file: %w", err)
+		}
+		opts.Selection = policy.Selection{Excludes: excludes}
+	}
+	if sseCKeyFile != "" && provider != "" && provider != "s3" {
+		return fmt.Errorf("SSE-C keys are S3 only and can't be used with --provider %s", provider)
+	}
+	var err error
+	if opts.SSECustomerKey, err = loadSSECKey(sseCKeyFile); err != nil {
+		return err
+	}
+	return runDeletion(ctx, opts, func(ctx context.Context, sc *scanner.Scanner, opts scanner.Options) (*scanner.Result, error) {
+		return sc.DeleteKeys(ctx, opts, keys)
+	})
+}
+
+// runDeletion carries out a deletion that was decided beforehand, by a key
+// list or an approved plan: it sets up the scanner, notifications, output
+// files and history around run the way a scan does.
+func runDeletion(ctx context.Context, opts scanner.Options, run func(context.Context, *scanner.Scanner, scanner.Options) (*scanner.Result, error)) error {
+	signKey, err := loadManifestKey()
+	if err != nil {
+		return err
+	}
+
+	tel, err := startTelemetry(ctx)
+	if err != nil {
+		return err
+	}
+	defer tel.shutdown()
+	sc, err := newScanner(ctx)
+	if err != nil {
+		return err
+	}
+	notifiers, err := buildNotifiers(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to set up notifications: %w", err)
+	}
+	opts.BatchNotifiers = notify.BatchNotifiersOf(notifiers)
+	sinks, closeSinks, err := openSinks(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to open output file: %w", err)
+	}
+	store, err := openHistory()
+	if err != nil {
+		return err
+	}
+	prefixes := prefixStats(store)
+	opts.Sinks = sinksFor(sinks, prefixes)
+
+	res, err := run(ctx, sc, opts)
+	closeSinks()
+	if err != nil {
+		return err
+	}
+	// Objects may already be gone: notify and record the run before
+	// failing on an unsigned manifest.
+	var signErr error
+	if signKey != nil {
+		signErr = signManifest(signKey)
+	}
+	printRunSummary(os.Stdout, res)
+	notify.All(ctx, notifiers, res)
+	recordHistory(ctx, store, res, nil, prefixes)
+	return signErr
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aslinger/s3-tidy/pkg/doctor"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/spf13/cobra"
+)
+
+// Doctor Flags
+var (
+	doctorBucket  string
+	doctorPrefix  string
+	doctorArchive string
+)
+
+func newDoctorCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the current credentials have every permission a run needs",
+		Long: `Probes each S3 permission a scan uses against --bucket with requests that can't
+change data, and lists the ones that are missing, so a long run doesn't fail
+halfway. Exits non-zero when a permission is denied.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runDoctor(); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+		},
+	}
+	cmd.Flags().StringVarP(&doctorBucket, "bucket", "b", "", "Target S3 bucket name (required)")
+	cmd.Flags().StringVar(&doctorPrefix, "prefix", "", "Key prefix the run is limited to; probe keys go under it")
+	cmd.Flags().StringVar(&doctorArchive, "archive-bucket", "", "Also check copying objects into this archive bucket")
+	cmd.MarkFlagRequired("bucket")
+	return cmd
+}
+
+func runDoctor() error {
+	ctx := context.Background()
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to load SDK config: %w", err)
+	}
+	// GetCallerIdentity needs no permissions and fails only without credentials.
+	id, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return fmt.Errorf("unable to resolve credentials: %w", err)
+	}
+
+	r := doctor.Run(ctx, s3.NewFromConfig(cfg), doctor.Options{
+		Bucket:        doctorBucket,
+		Prefix:        doctorPrefix,
+		ArchiveBucket: doctorArchive,
+		Identity:      aws.ToString(id.Arn),
+	})
+	if missing := r.Missing(); len(missing) > 0 {
+		perms := make([]string, len(missing))
+		for i, c := range missing {
+			perms[i] = c.Permission + " on " + c.Resource
+		}
+		return fmt.Errorf("%d permission(s) missing:\n   %s", len(missing), strings.Join(perms, "\n   "))
+	}
+	if !r.OK() {
+		return fmt.Errorf("some checks could not be completed; see above")
+	}
+	fmt.Println("✅ All checked permissions are in place")
+	return nil
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/aslinger/s3-tidy/internal/humanize"
+	"github.com/aslinger/s3-tidy/pkg/history"
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/spf13/cobra"
+)
+
+// History Flags (shared by scan and daemon)
+var (
+	historyDB       string
+	historyPrefixes bool
+)
+
+// History/Diff Command Flags
+var (
+	historyBucket string
+	historyPolicy string
+	historyLimit  int
+	historyJSON   bool
+	diffFrom      int64
+	diffTo        int64
+)
+
+func addHistoryFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&historyDB, "history-db", "", "Record each run's summary in this SQLite database (disabled when empty)")
+	cmd.Flags().BoolVar(&historyPrefixes, "history-prefixes", false, "Also record stale and deleted totals per top-level prefix in --history-db")
+}
+
+// openHistory opens --history-db, or returns nil when it isn't set.
+func openHistory() (*history.Store, error) {
+	if historyDB == "" {
+		return nil, nil
+	}
+	return history.Open(historyDB)
+}
+
+// prefixStats returns a fresh per-prefix aggregate for one run, or nil when
+// --history-prefixes is off.
+func prefixStats(store *history.Store) *history.PrefixStats {
+	if store == nil || !historyPrefixes {
+		return nil
+	}
+	return history.NewPrefixStats()
+}
+
+// sinksFor adds the prefix aggregate, if any, to a run's sinks.
+func sinksFor(sinks []scanner.Sink, prefixes *history.PrefixStats) []scanner.Sink {
+	if prefixes == nil {
+		return sinks
+	}
+	return append(sinks, prefixes)
+}
+
+// recordHistory stores one run. Like notifications, a failure is logged and
+// never fails the run itself.
+func recordHistory(ctx context.Context, store *history.Store, res *scanner.Result, runErr error, prefixes *history.PrefixStats) {
+	if store == nil {
+		return
+	}
+	var stats []history.PrefixStat
+	if prefixes != nil {
+		stats = prefixes.Stats()
+	}
+	if _, err := store.Record(ctx, res, runErr, stats); err != nil {
+		log.Printf("⚠️ Unable to record run history: %v\n", err)
+	}
+}
+
+func addHistoryQueryFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&historyDB, "history-db", "", "SQLite database written by --history-db (required)")
+	cmd.Flags().StringVarP(&historyBucket, "bucket", "b", "", "Only runs against this bucket")
+	cmd.Flags().StringVar(&historyPolicy, "policy", "", "Only runs of this policy")
+	cmd.Flags().BoolVar(&historyJSON, "json", false, "Print JSON instead of a table")
+	cmd.MarkFlagRequired("history-db")
+}
+
+func newHistoryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "List recorded runs with stale storage trends",
+		Long: `Lists the runs recorded in --history-db, newest first. The Δ STALE column is the
+change in stale storage since the previous run of the same policy and bucket.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runHistory(context.Background()); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+		},
+	}
+	addHistoryQueryFlags(cmd)
+	cmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Show at most this many runs (0 for all)")
+	return cmd
+}
+
+func newDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Compare stale counts, bytes and savings between two recorded runs",
+		Long: `Compares two runs from --history-db. --to defaults to the latest run matching
+--bucket/--policy and --from to the run of the same policy before it, so
+'diff --bucket X' shows what changed since the previous scan.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runDiff(context.Background()); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+		},
+	}
+	addHistoryQueryFlags(cmd)
+	cmd.Flags().Int64Var(&diffFrom, "from", 0, "Run ID to compare from (default: the run before --to)")
+	cmd.Flags().Int64Var(&diffTo, "to", 0, "Run ID to compare to (default: the latest run)")
+	return cmd
+}
+
+// openExistingHistory opens --history-db for reading. A mistyped path must not
+// silently create an empty database.
+func openExistingHistory() (*history.Store, error) {
+	if _, err := os.Stat(historyDB); err != nil {
+		return nil, err
+	}
+	return history.Open(historyDB)
+}
+
+func runHistory(ctx context.Context) error {
+	store, err := openExistingHistory()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	runs, err := store.Runs(ctx, history.Query{Bucket: historyBucket, Policy: historyPolicy, Limit: historyLimit})
+	if err != nil {
+		return err
+	}
+	if historyJSON {
+		return printJSON(runs)
+	}
+	if len(runs) == 0 {
+		fmt.Println("📜 No runs recorded yet.")
+		return nil
+	}
+
+	// Deltas need the run before each one, so walk oldest to newest.
+	deltas := make(map[int64]string, len(runs))
+	previous := make(map[string]int64)
+	for i := len(runs) - 1; i >= 0; i-- {
+		r := runs[i]
+		series := r.Policy + "\x00" + r.Bucket
+		if prev, ok := previous[series]; ok {
+			deltas[r.ID] = signedBytes(r.StaleBytes - prev)
+		} else {
+			deltas[r.ID] = "-"
+		}
+		previous[series] = r.StaleBytes
+	}
+
+	fmt.Printf("📜 %d recorded runs, newest first\n", len(runs))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTARTED\tPOLICY\tMODE\tSCANNED\tSTALE\tSTALE BYTES\tΔ STALE\tDELETED\tDELETED BYTES\tSAVINGS/MO\t")
+	var deleted int
+	var deletedBytes int64
+	for _, r := range runs {
+		mode := runMode(&r.Result)
+		if r.Error != "" {
+			mode += " (failed)"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\t%d\t%s\t%s\t%d\t%s\t$%.2f\t\n",
+			r.ID, r.Started.Local().Format("2006-01-02 15:04"), r.Policy, mode, r.Scanned, r.Stale, humanize.Bytes(r.StaleBytes), deltas[r.ID],
+			r.Deleted, humanize.Bytes(r.DeletedBytes), r.EstimatedSavings)
+		deleted += r.Deleted
+		deletedBytes += r.DeletedBytes
+	}
+	tw.Flush()
+	fmt.Println("------------------------------------------------")
+	fmt.Printf("   • Deleted across these runs: %d objects (%s)\n", deleted, humanize.Bytes(deletedBytes))
+	return nil
+}
+
+func runDiff(ctx context.Context) error {
+	store, err := openExistingHistory()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	from, to, err := diffRuns(ctx, store)
+	if err != nil {
+		return err
+	}
+	fromPrefixes, err := store.Prefixes(ctx, from.ID)
+	if err != nil {
+		return err
+	}
+	toPrefixes, err := store.Prefixes(ctx, to.ID)
+	if err != nil {
+		return err
+	}
+	changes := history.ComparePrefixes(fromPrefixes, toPrefixes)
+
+	if historyJSON {
+		return printJSON(struct {
+			From     *history.Run           `json:"from"`
+			To       *history.Run           `json:"to"`
+			Prefixes []history.PrefixChange `json:"prefixes"`
+		}{from, to, changes})
+	}
+
+	fmt.Printf("🔀 Run %d (%s, %s) → run %d (%s, %s)\n",
+		from.ID, from.Started.Local().Format("2006-01-02 15:04"), runMode(&from.Result),
+		to.ID, to.Started.Local().Format("2006-01-02 15:04"), runMode(&to.Result))
+	if from.Bucket != to.Bucket {
+		fmt.Printf("⚠️ Comparing different buckets: s3://%s → s3://%s\n", from.Bucket, to.Bucket)
+	} else {
+		fmt.Printf("   Bucket: s3://%s (policy %q → %q)\n", to.Bucket, from.Policy, to.Policy)
+	}
+	fmt.Println("------------------------------------------------")
+	fmt.Printf("   • Stale Objects: %d → %d (%+d%s)\n", from.Stale, to.Stale, to.Stale-from.Stale, percentChange(float64(from.Stale), float64(to.Stale)))
+	fmt.Printf("   • Stale Storage: %s → %s (%s%s)\n", humanize.Bytes(from.StaleBytes), humanize.Bytes(to.StaleBytes), signedBytes(to.StaleBytes-from.StaleBytes), percentChange(float64(from.StaleBytes), float64(to.StaleBytes)))
+	fmt.Printf("   • Est. Monthly Savings Available: $%.2f → $%.2f (%+.2f%s)\n", from.EstimatedSavings, to.EstimatedSavings, to.EstimatedSavings-from.EstimatedSavings, percentChange(from.EstimatedSavings, to.EstimatedSavings))
+	fmt.Printf("   • Objects Scanned: %d → %d (%+d)\n", from.Scanned, to.Scanned, to.Scanned-from.Scanned)
+	fmt.Printf("   • Deleted: %d (%s) → %d (%s)\n", from.Deleted, humanize.Bytes(from.DeletedBytes), to.Deleted, humanize.Bytes(to.DeletedBytes))
+
+	if len(fromPrefixes) == 0 || len(toPrefixes) == 0 {
+		fmt.Println("   (record runs with --history-prefixes for a per-prefix breakdown)")
+		return nil
+	}
+	if len(changes) == 0 {
+		fmt.Println("   No prefix changed.")
+		return nil
+	}
+	fmt.Println()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PREFIX\tSTALE\tSTALE BYTES\tΔ STALE\t")
+	for _, c := range changes {
+		prefix := c.Prefix
+		if prefix == "" {
+			prefix = "(bucket root)"
+		}
+		fmt.Fprintf(tw, "%s\t%d → %d\t%s → %s\t%s\t\n", prefix, c.From.Stale, c.To.Stale,
+			humanize.Bytes(c.From.StaleBytes), humanize.Bytes(c.To.StaleBytes), signedBytes(c.StaleBytesDelta()))
+	}
+	return tw.Flush()
+}
+
+// diffRuns resolves --from and --to, defaulting to the latest run and the run
+// of the same policy and bucket before it.
+func diffRuns(ctx context.Context, store *history.Store) (from, to *history.Run, err error) {
+	if diffTo != 0 {
+		if to, err = store.Get(ctx, diffTo); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		runs, err := store.Runs(ctx, history.Query{Bucket: historyBucket, Policy: historyPolicy, Limit: 1})
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(runs) == 0 {
+			return nil, nil, fmt.Errorf("no recorded runs match: %w", history.ErrNotFound)
+		}
+		to = &runs[0]
+	}
+
+	if diffFrom != 0 {
+		from, err = store.Get(ctx, diffFrom)
+		return from, to, err
+	}
+	runs, err := store.Runs(ctx, history.Query{Bucket: to.Bucket, Policy: to.Policy})
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, r := range runs {
+		if r.ID == to.ID && i+1 < len(runs) {
+			return &runs[i+1], to, nil
+		}
+	}
+	return nil, nil, errors.New("no earlier run of the same policy to compare with; pass --from")
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// signedBytes formats a change in size, e.g. "+1.2 GB" or "-300 MB".
+func signedBytes(n int64) string {
+	if n < 0 {
+		return "-" + humanize.Bytes(-n)
+	}
+	return "+" + humanize.Bytes(n)
+}
+
+// percentChange formats ", -12.5%", or nothing when there is no baseline.
+func percentChange(from, to float64) string {
+	if from == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", %+.1f%%", (to-from)/from*100)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aslinger/s3-tidy/pkg/iampolicy"
+	"github.com/spf13/cobra"
+)
+
+// IAM Policy Flags
+var (
+	iamBucket          string
+	iamPrefix          string
+	iamAction          string
+	iamArchive         string
+	iamVersion         bool
+	iamVersionIDs      bool
+	iamAccessLogBucket string
+	iamAccessLogPrefix string
+	iamTrailBucket     string
+	iamTrailPrefix     string
+	iamAthenaTable     string
+	iamAthenaWorkgroup string
+	iamAthenaOutput    string
+)
+
+func newIAMPolicyCmd() *cobra.Command {
+	actions := make([]string, len(iampolicy.Actions))
+	for i, a := range iampolicy.Actions {
+		actions[i] = string(a)
+	}
+	cmd := &cobra.Command{
+		Use:   "iam-policy",
+		Short: "Print the least-privilege IAM policy a mode needs",
+		Long: `Prints the IAM policy JSON with exactly the permissions s3-tidy uses for
+--action on --bucket, scoped to --prefix when given. Attach it to the role
+runs use; 's3-tidy doctor' checks the result.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			action, err := iampolicy.ParseAction(iamAction)
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			opts := iampolicy.Options{Bucket: iamBucket, Prefix: iamPrefix, ArchiveBucket: iamArchive, Versions: iamVersion, VersionIDs: iamVersionIDs}
+			opts.LogBucket, opts.LogPrefix = iamAccessLogBucket, iamAccessLogPrefix
+			if iamTrailBucket != "" {
+				opts.LogBucket, opts.LogPrefix = iamTrailBucket, iamTrailPrefix
+			}
+			if iamAthenaTable != "" {
+				opts.Athena = &iampolicy.Athena{Table: iamAthenaTable, Workgroup: iamAthenaWorkgroup, OutputLocation: iamAthenaOutput}
+			}
+			doc, err := iampolicy.For(action, opts)
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			raw, err := doc.JSON()
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			fmt.Println(string(raw))
+		},
+	}
+	cmd.Flags().StringVarP(&iamBucket, "bucket", "b", "", "Target S3 bucket name (required)")
+	cmd.Flags().StringVar(&iamPrefix, "prefix", "", "Only allow reading and deleting objects under this key prefix; listing still covers the bucket")
+	cmd.Flags().StringVar(&iamAction, "action", string(iampolicy.ActionDelete), "Mode to allow: "+strings.Join(actions, ", "))
+	cmd.Flags().StringVar(&iamArchive, "archive-bucket", "", "With --action delete, also allow archiving into this bucket")
+	cmd.Flags().BoolVar(&iamVersion, "versions", false, "Also allow listing object versions, for scans with --versions")
+	cmd.Flags().BoolVar(&iamVersionIDs, "version-ids", false, "Also allow reading and deleting given object versions, for 'delete --keys-file' and 'apply' with version IDs")
+	cmd.Flags().StringVar(&iamAccessLogBucket, "access-log-bucket", "", "Also allow reading the server access logs in this bucket (scan --access-log-bucket; with --athena-table, the table's location)")
+	cmd.Flags().StringVar(&iamAccessLogPrefix, "access-log-prefix", "", "Only allow reading access logs under this prefix")
+	cmd.Flags().StringVar(&iamTrailBucket, "cloudtrail-bucket", "", "Also allow reading the CloudTrail logs in this bucket (scan --cloudtrail-bucket)")
+	cmd.Flags().StringVar(&iamTrailPrefix, "cloudtrail-prefix", "", "Only allow reading CloudTrail logs under this prefix")
+	cmd.Flags().StringVar(&iamAthenaTable, "athena-table", "", "Also allow querying this Athena table (database.table) of access logs (scan --athena-table)")
+	cmd.Flags().StringVar(&iamAthenaWorkgroup, "athena-workgroup", "", "Athena workgroup the query runs in (default: primary)")
+	cmd.Flags().StringVar(&iamAthenaOutput, "athena-output", "", "S3 URI receiving Athena query results (required with --athena-table)")
+	cmd.MarkFlagsMutuallyExclusive("access-log-bucket", "cloudtrail-bucket")
+	cmd.MarkFlagsMutuallyExclusive("cloudtrail-bucket", "athena-table")
+	cmd.MarkFlagRequired("bucket")
+	return cmd
+}
@@ -0,0 +1,26 @@
+// Package humanize formats sizes and ages for human-facing output.
+package humanize
+
+import (
+	"fmt"
+	"time"
+)
+
+// Bytes renders a size with binary units.
+func Bytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// Age renders a duration as whole days, which is the granularity retention works in.
+func Age(d time.Duration) string {
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
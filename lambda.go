@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aslinger/s3-tidy/pkg/hook"
+	"github.com/aslinger/s3-tidy/pkg/notify"
+	"github.com/aslinger/s3-tidy/pkg/policy"
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/aws/aws-lambda-go/lambda"
+	"gopkg.in/yaml.v3"
+)
+
+// The Lambda runtime sets this for every function; its presence is how the
+// same binary knows to serve invocations instead of parsing a command line.
+const lambdaRuntimeEnv = "AWS_LAMBDA_RUNTIME_API"
+
+func runningInLambda() bool { return os.Getenv(lambdaRuntimeEnv) != "" }
+
+// lambdaResponse is returned to the invoker (and visible in CloudWatch Logs).
+type lambdaResponse struct {
+	Results []*scanner.Result `json:"results"`
+	Failed  []lambdaFailure   `json:"failed,omitempty"`
+}
+
+type lambdaFailure struct {
+	Policy string `json:"policy"`
+	Error  string `json:"error"`
+}
+
+// startLambda hands control to the Lambda runtime. Deploy the binary as
+// "bootstrap" on a provided.al2023 runtime and invoke it on an EventBridge schedule.
+func startLambda() {
+	var err error
+	if lambdaTelemetry, err = startTelemetry(context.Background()); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	lambda.Start(handleLambda)
+}
+
+// lambdaTelemetry exports over OTLP when $OTEL_EXPORTER_OTLP_ENDPOINT is set.
+// It is flushed after every invocation, since Lambda freezes the process in
+// between.
+var lambdaTelemetry *telemetry
+
+// handleLambda runs the policies carried by the event, or, when the event has
+// none (e.g. a plain scheduled event), those from S3TIDY_POLICIES (inline
+// YAML/JSON) or the file named by S3TIDY_CONFIG. Any failed policy fails the
+// invocation so it shows up in Lambda error metrics.
+func handleLambda(ctx context.Context, event json.RawMessage) (*lambdaResponse, error) {
+	defer lambdaTelemetry.flush(ctx)
+	pf, err := lambdaPolicies(event)
+	if err != nil {
+		return nil, err
+	}
+
+	sc, err := newScanner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	configureNotifyFromEnv()
+	notifiers, err := buildNotifiers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to set up notifications: %w", err)
+	}
+
+	pricing = pf.Pricing
+
+	resp := &lambdaResponse{}
+	for _, p := range pf.Policies {
+		res, err := runPolicy(ctx, sc, p, time.Now(), notify.BatchNotifiersOf(notifiers), nil)
+		if err != nil {
+			log.Printf("⚠️ Policy %q failed: %v\n", p.Name, err)
+			resp.Failed = append(resp.Failed, lambdaFailure{Policy: p.Name, Error: err.Error()})
+			continue
+		}
+		notify.All(ctx, notifiers, res)
+		resp.Results = append(resp.Results, res)
+	}
+
+	if len(resp.Failed) > 0 {
+		return resp, fmt.Errorf("%d of %d policies failed", len(resp.Failed), len(pf.Policies))
+	}
+	return resp, nil
+}
+
+// lambdaPolicies accepts either a policies file ({"policies": [...]}) or a single
+// policy ({"bucket": "...", "days": 30}) as the event payload.
+func lambdaPolicies(event json.RawMessage) (*policy.File, error) {
+	if len(event) > 0 && string(event) != "null" {
+		var probe struct {
+			Policies []any  `yaml:"policies"`
+			Bucket   string `yaml:"bucket"`
+		}
+		if err := yaml.Unmarshal(event, &probe); err == nil {
+			switch {
+			case len(probe.Policies) > 0:
+				return policy.ParseFile(event, "event")
+			case probe.Bucket != "":
+				var single policy.Policy
+				if err := yaml.Unmarshal(event, &single); err != nil {
+					return nil, fmt.Errorf("event: %w", err)
+				}
+				raw, err := yaml.Marshal(policy.File{Policies: []policy.Policy{single}})
+				if err != nil {
+					return nil, err
+				}
+				return policy.ParseFile(raw, "event")
+			}
+		}
+	}
+
+	if inline := os.Getenv("S3TIDY_POLICIES"); inline != "" {
+		return policy.ParseFile([]byte(inline), "S3TIDY_POLICIES")
+	}
+	if path := os.Getenv("S3TIDY_CONFIG"); path != "" {
+		return policy.LoadFile(path)
+	}
+	return nil, fmt.Errorf("no policies in the event and neither S3TIDY_POLICIES nor S3TIDY_CONFIG is set")
+}
+
+// configureNotifyFromEnv maps environment variables onto the notification and
+// key redaction settings, since there is no command line inside Lambda.
+func configureNotifyFromEnv() {
+	truthy := func(name string) bool {
+		v := strings.ToLower(os.Getenv(name))
+		return v == "1" || v == "true" || v == "yes"
+	}
+
+	publishCloudWatch = truthy("S3TIDY_PUBLISH_CLOUDWATCH")
+	cloudWatchNamespace = envOr("S3TIDY_CLOUDWATCH_NAMESPACE", "S3Tidy")
+	snsTopicARN = os.Getenv("S3TIDY_SNS_TOPIC")
+	emitEventBridge = truthy("S3TIDY_EMIT_EVENTBRIDGE")
+	eventBridgeBus = envOr("S3TIDY_EVENTBRIDGE_BUS", "default")
+	slackWebhook = os.Getenv("S3TIDY_SLACK_WEBHOOK")
+	preDeleteHook = os.Getenv("S3TIDY_PRE_DELETE_HOOK")
+	postRunHook = os.Getenv("S3TIDY_POST_RUN_HOOK")
+	redactKeys = os.Getenv("S3TIDY_REDACT_KEYS")
+	redactSecret = os.Getenv("S3TIDY_REDACT_SECRET")
+	hookTimeout = hook.DefaultTimeout
+}
+
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
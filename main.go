@@ -2,29 +2,83 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aslinger/s3-tidy/pkg/accesslog"
+	"github.com/aslinger/s3-tidy/pkg/cost"
+	"github.com/aslinger/s3-tidy/pkg/notify"
+	"github.com/aslinger/s3-tidy/pkg/policy"
+	"github.com/aslinger/s3-tidy/pkg/scanner"
 	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
 	"github.com/spf13/cobra"
 )
 
 // Global Flags
 var (
-	bucketName string
-	days       int
-	dryRun     bool
-	reportOnly bool
+	bucketName      string
+	days            int
+	dryRun          bool
+	reportOnly      bool
+	excludeFile     string
+	extensions      []string
+	listVersions    bool
+	beforeDate      string
+	timezone        string
+	minAge          string
+	maxAge          string
+	keyDateFmt      string
+	keyDateRe       string
+	keyDateLay      string
+	gfsDaily        int
+	gfsWeekly       int
+	gfsMonthly      int
+	gfsDepth        int
+	keepRels        int
+	relPattern      string
+	filterCommand   string
+	targetSavings   float64
+	accessLogBucket string
+	accessLogPrefix string
+	unreadDays      int
+	athenaTable     string
+	athenaQuery     string
+	athenaWorkgroup string
+	athenaOutput    string
+	trailBucket     string
+	trailPrefix     string
+	largestFirst    bool
+	maxDelete       int
+	maxObjects      int
+	maxDuration     string
+	startAfter      string
+	checkpointFile  string
+	archiveBucket   string
+	archiveClass    string
+	scanAction      string
+	expiryTag       string
+	graceDays       int
+	verifyDelete    string
+	sseCKeyFile     string
+	interactive     bool
+	confirmEach     bool
+	deleteBatchSize int
+	listConcurrency int
+	failStaleBytes  string
+	failStaleCount  int
 )
 
-// Constants for FinOps (Standard S3 Standard pricing approx $0.023/GB)
-const pricePerGB = 0.023
-
 func main() {
+	if runningInLambda() {
+		startLambda()
+		return
+	}
+
 	var rootCmd = &cobra.Command{
 		Use:   "s3-tidy",
 		Short: "Cloud governance tool for S3 cleanup",
@@ -38,111 +92,356 @@ func main() {
 		Use:   "scan",
 		Short: "Scan bucket for stale objects",
 		Run: func(cmd *cobra.Command, args []string) {
-			runScan(bucketName, days, dryRun, reportOnly)
+			if (interactive || confirmEach) && reportOnly {
+				log.Fatalf("❌ --interactive/--confirm-each-prefix select objects to delete and cannot be combined with --report")
+			}
+
+			if err := resolvePricing(cmd, cost.Pricing{}); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			opts, err := scanOptions(policyFromFlags(cmd), time.Now())
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			maxBytes, err := parseSize(failStaleBytes)
+			if err != nil {
+				log.Fatalf("❌ --fail-if-stale-bytes: %v", err)
+			}
+			budget := staleBudget{MaxBytes: maxBytes, MaxCount: failStaleCount}
+			// Load the signing key up front so a bad key doesn't surface after the run.
+			signKey, err := loadManifestKey()
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			if interactive {
+				opts.Review = func(ctx context.Context, stale []policy.Candidate) ([]policy.Candidate, error) {
+					return reviewInteractively(opts.Bucket, stale, time.Now())
+				}
+			}
+			if confirmEach {
+				opts.Confirm = func(act func(policy.Candidate), flush func()) scanner.Confirmer {
+					return newPrefixConfirmer(os.Stdin, os.Stdout, time.Now(), act, flush)
+				}
+			}
+
+			ctx := context.Background()
+			tel, err := startTelemetry(ctx)
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			sc, err := newScanner(ctx)
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			notifiers, err := buildNotifiers(ctx)
+			if err != nil {
+				log.Fatalf("❌ Unable to set up notifications: %v", err)
+			}
+
+			opts.Out = progressWriter()
+			opts.BatchSize = deleteBatchSize
+			opts.ListConcurrency = listConcurrency
+			opts.BatchNotifiers = notify.BatchNotifiersOf(notifiers)
+			opts.PreDeleteHooks = preDeleteHooks()
+			sinks, closeSinks, err := openSinks(ctx)
+			if err != nil {
+				log.Fatalf("❌ Unable to open output file: %v", err)
+			}
+			store, err := openHistory()
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			prefixes := prefixStats(store)
+			opts.Sinks = sinksFor(sinks, prefixes)
+
+			res, err := sc.Run(ctx, opts)
+			closeSinks()
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			// Objects may already be gone: notify and record the run before
+			// failing on an unsigned manifest.
+			var signErr error
+			if signKey != nil {
+				signErr = signManifest(signKey)
+			}
+			printRunSummary(os.Stdout, res)
+			notify.All(ctx, notifiers, res)
+			recordHistory(ctx, store, res, nil, prefixes)
+			tel.shutdown()
+			if signErr != nil {
+				log.Fatalf("❌ %v", signErr)
+			}
+
+			if violations := budget.Check(res); len(violations) > 0 {
+				for _, v := range violations {
+					fmt.Fprintf(os.Stderr, "🚨 Stale storage budget exceeded: %s\n", v)
+				}
+				os.Exit(exitStaleBudgetExceeded)
+			}
 		},
 	}
 
 	// Flag definition
-	scanCmd.Flags().StringVarP(&bucketName, "bucket", "b", "", "Target S3 bucket name (required)")
-	scanCmd.Flags().IntVarP(&days, "days", "d", 30, "Age threshold in days")
+	addSelectionFlags(scanCmd)
 	scanCmd.Flags().BoolVar(&dryRun, "dry-run", true, "Simulate deletion without taking action")
 	scanCmd.Flags().BoolVar(&reportOnly, "report", false, "Generate a cost-savings report without deleting")
+	scanCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Review stale objects grouped by prefix in a terminal UI before acting")
+	scanCmd.Flags().BoolVar(&confirmEach, "confirm-each-prefix", false, "Pause at each top-level prefix and ask y/N before acting on it")
+	scanCmd.Flags().StringVar(&failStaleBytes, "fail-if-stale-bytes", "", fmt.Sprintf("Exit with code %d when stale storage exceeds this size (e.g. 500GB, 1TiB)", exitStaleBudgetExceeded))
+	scanCmd.Flags().IntVar(&failStaleCount, "fail-if-stale-count", 0, fmt.Sprintf("Exit with code %d when more than this many stale objects are found", exitStaleBudgetExceeded))
+	scanCmd.Flags().StringVar(&scanAction, "action", string(scanner.ActionDelete), "What to do with stale objects: delete, tag (mark them for review with --expiry-tag) or delete-tagged (delete only those whose tag has expired)")
+	scanCmd.Flags().StringVar(&expiryTag, "expiry-tag", scanner.DefaultExpiryTag, "Tag key --action tag sets to the expiry date and --action delete-tagged checks")
+	scanCmd.Flags().IntVar(&graceDays, "grace-days", policy.DefaultGraceDays, "With --action tag, days owners have to remove the tag before the object may be deleted")
+	scanCmd.Flags().StringVar(&archiveBucket, "archive-bucket", "", "Copy each object here (same key, metadata and tags) and verify it before deleting the source")
+	scanCmd.Flags().StringVar(&archiveClass, "archive-storage-class", "", "Storage class for archived copies, e.g. GLACIER_IR or DEEP_ARCHIVE (default STANDARD)")
+	scanCmd.Flags().StringVar(&verifyDelete, "verify-before-delete", "etag", "Guard against objects rewritten since listing: etag (conditional delete), head (HeadObject re-check) or none")
+	scanCmd.Flags().StringVar(&sseCKeyFile, "sse-c-key-file", "", "File holding the 32-byte SSE-C key (raw or base64) for customer-key encrypted objects (env S3TIDY_SSE_C_KEY, base64)")
+	scanCmd.Flags().IntVar(&deleteBatchSize, "delete-batch-size", scanner.MaxDeleteBatch, "Keys per DeleteObjects request (1-1000)")
+	scanCmd.Flags().IntVar(&listConcurrency, "list-concurrency", scanner.DefaultListConcurrency, "Top-level prefixes listed in parallel (1 = a single ListObjectsV2 stream)")
+
+	addOutputFlags(scanCmd)
+	addSinkFlags(scanCmd)
+	addHistoryFlags(scanCmd)
+	addPricingFlags(scanCmd)
+	addProviderFlags(scanCmd)
+	addNotifyFlags(scanCmd)
+	addTelemetryFlags(scanCmd)
 
-	scanCmd.MarkFlagRequired("bucket")
+	scanCmd.MarkFlagsOneRequired("bucket", "container")
+	scanCmd.MarkFlagsMutuallyExclusive("interactive", "confirm-each-prefix")
+	// Reviewing objects one can't see defeats the point; redaction is for unattended runs.
+	scanCmd.MarkFlagsMutuallyExclusive("interactive", "redact-keys")
+	scanCmd.MarkFlagsMutuallyExclusive("confirm-each-prefix", "redact-keys")
+	// Savings-target and largest-first picks don't arrive one prefix at a time.
+	scanCmd.MarkFlagsMutuallyExclusive("target-savings", "confirm-each-prefix")
+	scanCmd.MarkFlagsMutuallyExclusive("largest-first", "confirm-each-prefix")
 
-	rootCmd.AddCommand(scanCmd)
+	rootCmd.AddCommand(scanCmd, newReportCmd(), newDaemonCmd(), newRestoreCmd(), newHistoryCmd(), newDiffCmd(), newDoctorCmd(), newIAMPolicyCmd(), newVerifyManifestCmd(), newCompareCmd(), newDeleteCmd(), newPlanCmd(), newApproveCmd(), newApplyCmd())
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
 
-func runScan(bucket string, days int, isDryRun bool, isReport bool) {
-	ctx := context.TODO()
-
-	// 1. Load AWS Config (Auto-detects SSO, Env Vars, or ~/.aws/credentials)
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		log.Fatalf("❌ Unable to load SDK config: %v", err)
-	}
-	client := s3.NewFromConfig(cfg)
+// addSelectionFlags registers the flags that decide which objects are stale.
+// They are shared by every command that scans a single bucket.
+func addSelectionFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&bucketName, "bucket", "b", "", "Target S3 bucket name (required)")
+	cmd.Flags().StringVar(&bucketName, "container", "", "Target Azure container, the --bucket of --provider azure")
+	cmd.MarkFlagsMutuallyExclusive("bucket", "container")
+	cmd.Flags().IntVarP(&days, "days", "d", 30, "Age threshold in days")
+	cmd.Flags().StringVar(&beforeDate, "before", "", "Explicit cutoff date (YYYY-MM-DD); alternative to --days")
+	cmd.Flags().StringVar(&timezone, "timezone", "Local", "IANA timezone used to interpret the cutoff (e.g. UTC, Europe/Berlin)")
+	cmd.Flags().StringVar(&minAge, "min-age", "", "Only select objects older than this age (e.g. 90d, 12w, 18m, 7y); alternative to --days")
+	cmd.Flags().StringVar(&maxAge, "max-age", "", "Skip objects older than this age, e.g. records under legal retention (e.g. 7y)")
+	cmd.Flags().StringVar(&keyDateFmt, "key-date-format", "", "Take each object's age from a date in its key, e.g. 'backups/%Y/%m/%d/'")
+	cmd.Flags().StringVar(&keyDateRe, "key-date-regex", "", "Regex with a (?P<date>...) group, or year/month/day groups, locating the date in the key")
+	cmd.Flags().StringVar(&keyDateLay, "key-date-layout", "2006-01-02", "Go time layout for the (?P<date>...) group of --key-date-regex")
+	cmd.Flags().IntVar(&gfsDaily, "gfs-daily", 0, "GFS: keep every backup from the last N days")
+	cmd.Flags().IntVar(&gfsWeekly, "gfs-weekly", 0, "GFS: keep the newest backup of each week for the last N weeks")
+	cmd.Flags().IntVar(&gfsMonthly, "gfs-monthly", 0, "GFS: keep the newest backup of each month for the last N months")
+	cmd.Flags().IntVar(&gfsDepth, "gfs-group-depth", 0, "GFS: number of leading key segments that identify a backup set (0 = parent prefix)")
+	cmd.Flags().IntVar(&keepRels, "keep-releases", 0, "Release retention: keep the newest N versions of each artifact, regardless of age")
+	cmd.Flags().StringVar(&relPattern, "release-pattern", "", "Regex with a (?P<version>...) and optional (?P<name>...) group (default: semver/dotted build numbers)")
+	cmd.Flags().StringVar(&excludeFile, "exclude-file", "", "File of exact keys and glob patterns to skip, one per line")
+	cmd.Flags().StringSliceVar(&extensions, "ext", nil, "Only consider keys ending in one of these extensions, case-insensitive (e.g. .log,.tmp,.bak)")
+	cmd.Flags().StringVar(&filterCommand, "filter-command", "", "Long-running command asked keep/delete for every selected object over a JSON-lines protocol")
+	cmd.Flags().StringVar(&accessLogBucket, "access-log-bucket", "", "Bucket receiving this bucket's server access logs; objects read within --unread-days are kept")
+	cmd.Flags().StringVar(&accessLogPrefix, "access-log-prefix", "", "Target prefix of the server access logs in --access-log-bucket")
+	cmd.Flags().IntVar(&unreadDays, "unread-days", 0, "With --access-log-bucket or --athena-table: only objects unread for this many days are stale")
+	cmd.Flags().StringVar(&athenaTable, "athena-table", "", "Athena table (database.table) of this bucket's access logs, queried instead of reading the log files")
+	cmd.Flags().StringVar(&athenaQuery, "athena-query-file", "", "Custom Athena SQL taking (bucket, since) parameters and returning (key, last_read) rows")
+	cmd.Flags().StringVar(&athenaWorkgroup, "athena-workgroup", "", "Athena workgroup to run the query in (default: primary)")
+	cmd.Flags().StringVar(&athenaOutput, "athena-output", "", "S3 URI for Athena query results (default: the workgroup's)")
+	cmd.Flags().StringVar(&trailBucket, "cloudtrail-bucket", "", "Bucket a CloudTrail trail delivers S3 data events to; objects read within --unread-days are kept")
+	cmd.Flags().StringVar(&trailPrefix, "cloudtrail-prefix", "", "Prefix of the trail's log files, e.g. AWSLogs/123456789012/CloudTrail/us-east-1/")
+	cmd.Flags().Float64Var(&targetSavings, "target-savings", 0, "Only act on the largest (then oldest) stale objects until they save this much per month, in --currency")
+	cmd.Flags().BoolVar(&largestFirst, "largest-first", false, "Hold stale objects until listing is done and act on the largest first")
+	cmd.Flags().IntVar(&maxDelete, "max-delete", 0, "Act on at most this many stale objects per run (the largest with --largest-first)")
+	cmd.Flags().IntVar(&maxObjects, "max-objects", 0, "Stop listing after examining this many objects, act on what was found and report where to resume")
+	cmd.Flags().StringVar(&maxDuration, "max-duration", "", "Stop listing after this long (e.g. 45m, 2h), act on what was found and report where to resume")
+	cmd.Flags().StringVar(&startAfter, "start-after", "", "Resume a capped scan after this key (printed when --max-objects or --max-duration stopped it)")
+	cmd.Flags().BoolVar(&listVersions, "versions", false, "Versioned buckets: list object versions, age each key by its newest version and flag keys whose current version is a delete marker")
+	cmd.Flags().StringVar(&checkpointFile, "checkpoint-file", "", "Resume after the key saved here by a capped run, save it again when stopping early, and remove it once the bucket is fully listed")
 
-	// 2. Define the cutoff
-	cutoff := time.Now().AddDate(0, 0, -days)
-	fmt.Printf("🔍 Scanning 's3://%s' for objects older than %s (%d days)...\n", bucket, cutoff.Format("2006-01-02"), days)
-
-	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
-		Bucket: aws.String(bucket),
-	})
+	cmd.MarkFlagsMutuallyExclusive("days", "before", "min-age")
+	cmd.MarkFlagsMutuallyExclusive("key-date-format", "key-date-regex")
+	cmd.MarkFlagsMutuallyExclusive("access-log-bucket", "athena-table", "cloudtrail-bucket")
+	cmd.MarkFlagsMutuallyExclusive("access-log-bucket", "athena-query-file", "cloudtrail-bucket")
+	cmd.MarkFlagsMutuallyExclusive("start-after", "checkpoint-file")
+}
 
-	var staleCount int
-	var totalSize int64
-	var deletedCount int
+// policyFromFlags maps the scan flags onto a policy. --days has a default, so
+// it only counts as a selection when nothing else selects objects.
+func policyFromFlags(cmd *cobra.Command) policy.Policy {
+	p := policy.Policy{
+		Name:                bucketName,
+		Bucket:              bucketName,
+		Days:                days,
+		Before:              beforeDate,
+		Timezone:            timezone,
+		MinAge:              minAge,
+		MaxAge:              maxAge,
+		ExcludeFile:         excludeFile,
+		Extensions:          extensions,
+		KeyDateFormat:       keyDateFmt,
+		KeyDateRegex:        keyDateRe,
+		KeyDateLayout:       keyDateLay,
+		GFS:                 policy.GFSConfig{Daily: gfsDaily, Weekly: gfsWeekly, Monthly: gfsMonthly, GroupDepth: gfsDepth},
+		KeepReleases:        keepRels,
+		ReleasePattern:      relPattern,
+		FilterCommand:       filterCommand,
+		TargetSavings:       targetSavings,
+		AccessLogBucket:     accessLogBucket,
+		AccessLogPrefix:     accessLogPrefix,
+		UnreadDays:          unreadDays,
+		Athena:              policy.AthenaConfig{Table: athenaTable, QueryFile: athenaQuery, Workgroup: athenaWorkgroup, OutputLocation: athenaOutput},
+		CloudTrailBucket:    trailBucket,
+		CloudTrailPrefix:    trailPrefix,
+		LargestFirst:        largestFirst,
+		MaxDelete:           maxDelete,
+		MaxObjects:          maxObjects,
+		MaxDuration:         maxDuration,
+		CheckpointFile:      checkpointFile,
+		Versions:            listVersions,
+		Action:              scanAction,
+		ExpiryTag:           expiryTag,
+		GraceDays:           graceDays,
+		ArchiveBucket:       archiveBucket,
+		ArchiveStorageClass: archiveClass,
+		VerifyBeforeDelete:  verifyDelete,
+		SSECKeyFile:         sseCKeyFile,
+		DryRun:              &dryRun,
+		Report:              reportOnly,
+	}
+	if !cmd.Flags().Changed("days") && (beforeDate != "" || minAge != "" || keepRels > 0 || p.GFS.Enabled()) {
+		p.Days = 0
+	}
+	return p
+}
 
-	// 3. Pagination Loop
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
-		if err != nil {
-			log.Fatalf("❌ Failed to list objects: %v", err)
+// scanOptions resolves p at now into the options for one scan of its bucket.
+func scanOptions(p policy.Policy, now time.Time) (scanner.Options, error) {
+	sel, err := p.Resolve(now)
+	if err != nil {
+		return scanner.Options{}, err
+	}
+	var logs accesslog.Source
+	switch {
+	case countTrue(p.AccessLogBucket != "", p.Athena.Enabled(), p.CloudTrailBucket != "") > 1:
+		return scanner.Options{}, fmt.Errorf("access logs, Athena and CloudTrail are alternatives; configure only one")
+	case p.AccessLogBucket != "":
+		logs = accesslog.Source{Bucket: p.AccessLogBucket, Prefix: p.AccessLogPrefix}
+	case p.CloudTrailBucket != "":
+		logs = accesslog.Source{Bucket: p.CloudTrailBucket, Prefix: p.CloudTrailPrefix, Format: accesslog.FormatCloudTrail}
+	case p.Athena.Enabled():
+		if logs.Query, err = newAthenaQuery(p.Athena); err != nil {
+			return scanner.Options{}, err
 		}
+	}
+	if logs.Enabled() {
+		if provider != "" && provider != "s3" {
+			return scanner.Options{}, fmt.Errorf("access logs, Athena and CloudTrail read sources are S3 only and can't be used with --provider %s", provider)
+		}
+		if p.UnreadDays <= 0 {
+			return scanner.Options{}, fmt.Errorf("access logs need unread days (how long an object must go unread)")
+		}
+		logs.Since = now.AddDate(0, 0, -p.UnreadDays)
+	}
+	if p.Versions && provider != "" && provider != "s3" {
+		return scanner.Options{}, fmt.Errorf("object versions are S3 only and can't be listed with --provider %s", provider)
+	}
+	if p.SSECKeyFile != "" && provider != "" && provider != "s3" {
+		return scanner.Options{}, fmt.Errorf("SSE-C keys are S3 only and can't be used with --provider %s", provider)
+	}
+	sseKey, err := loadSSECKey(p.SSECKeyFile)
+	if err != nil {
+		return scanner.Options{}, err
+	}
+	var maxDur time.Duration
+	if p.MaxDuration != "" {
+		if maxDur, err = time.ParseDuration(p.MaxDuration); err != nil {
+			return scanner.Options{}, fmt.Errorf("invalid max duration %q: %w", p.MaxDuration, err)
+		}
+	}
+	return scanner.Options{
+		Name:      p.Name,
+		Bucket:    p.Bucket,
+		DryRun:    p.DryRunEnabled(),
+		Report:    p.Report,
+		Selection: sel,
 
-		for _, obj := range page.Contents {
-			if obj.LastModified.Before(cutoff) {
-				staleCount++
-
-				// FIX: Dereference the pointer (*obj.Size)
-				if obj.Size != nil {
-					totalSize += *obj.Size
-				}
+		Action:      scanner.Action(p.Action),
+		ExpiryTag:   p.ExpiryTag,
+		ExpireAfter: now.AddDate(0, 0, p.Grace()),
 
-				if isReport {
-					continue
-				}
+		ArchiveBucket:       p.ArchiveBucket,
+		ArchiveStorageClass: p.ArchiveStorageClass,
+		Verify:              scanner.Verify(p.VerifyBeforeDelete),
+		Pricing:             pricing,
+		TargetSavings:       pricing.ToUSD(p.TargetSavings),
+		LargestFirst:        p.LargestFirst,
+		MaxDelete:           p.MaxDelete,
+		MaxObjects:          p.MaxObjects,
+		MaxDuration:         maxDur,
+		StartAfter:          startAfter,
+		CheckpointFile:      p.CheckpointFile,
+		Versions:            p.Versions,
+		AccessLog:           logs,
+		SSECustomerKey:      sseKey,
+		RedactKeys:          redaction(),
+	}, nil
+}
 
-				if isDryRun {
-					// FIX: Dereference here too
-					sizeMB := 0.0
-					if obj.Size != nil {
-						sizeMB = float64(*obj.Size) / 1024 / 1024
-					}
-					fmt.Printf("[DRY RUN] Would delete: %s (%s, %.2f MB)\n", *obj.Key, obj.LastModified.Format(time.RFC3339), sizeMB)
-				} else {
-					// Actual Deletion Logic
-					_, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{
-						Bucket: aws.String(bucket),
-						Key:    obj.Key,
-					})
-					if err != nil {
-						log.Printf("⚠️ Failed to delete %s: %v\n", *obj.Key, err)
-					} else {
-						fmt.Printf("🗑️ DELETED: %s\n", *obj.Key)
-						deletedCount++
-					}
-				}
-			}
+// loadSSECKey reads the SSE-C key from path, falling back to the base64 key in
+// $S3TIDY_SSE_C_KEY. A file may hold the 32 raw bytes or their base64.
+func loadSSECKey(path string) ([]byte, error) {
+	if path == "" {
+		v := os.Getenv("S3TIDY_SSE_C_KEY")
+		if v == "" {
+			return nil, nil
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("S3TIDY_SSE_C_KEY is not base64: %w", err)
 		}
+		return key, nil
 	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read SSE-C key: %w", err)
+	}
+	if len(raw) == 32 {
+		return raw, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("%s holds neither a raw 32-byte key nor base64: %w", path, err)
+	}
+	return key, nil
+}
 
-	// 4. FinOps Report / Summary
-	fmt.Println("------------------------------------------------")
-
-	// Calculate Savings
-	sizeInGB := float64(totalSize) / 1024 / 1024 / 1024
-	estimatedSavings := sizeInGB * pricePerGB
-
-	if isReport {
-		fmt.Println("📊 FINOPS COST REPORT")
-		fmt.Printf("   • Stale Objects Found: %d\n", staleCount)
-		fmt.Printf("   • Total Storage Reclaimable: %.4f GB\n", sizeInGB)
-		fmt.Printf("   • Estimated Monthly Savings: $%.4f\n", estimatedSavings)
-		fmt.Println("   (Based on S3 Standard pricing of ~$0.023/GB)")
-		return
+func countTrue(conds ...bool) int {
+	n := 0
+	for _, c := range conds {
+		if c {
+			n++
+		}
 	}
+	return n
+}
 
-	if isDryRun {
-		fmt.Printf("✅ Dry run complete. Found %d stale objects (%.2f GB).\n", staleCount, sizeInGB)
-		fmt.Println("   Run with --dry-run=false to execute cleanup.")
-	} else {
-		fmt.Printf("✅ Cleanup complete. Deleted %d objects.\n", deletedCount)
+// newAthenaQuery builds an Athena access log query on the default credential chain.
+func newAthenaQuery(c policy.AthenaConfig) (*accesslog.Athena, error) {
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("unable to load SDK config: %w", err)
 	}
+	return accesslog.NewAthena(athena.NewFromConfig(cfg), accesslog.AthenaOptions{
+		Table:          c.Table,
+		QueryFile:      c.QueryFile,
+		Workgroup:      c.Workgroup,
+		OutputLocation: c.OutputLocation,
+	})
 }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// runMetrics exposes daemon run results for Prometheus. Counters accumulate
+// across runs; gauges describe the most recent run of each policy.
+type runMetrics struct {
+	scanned     *prometheus.CounterVec
+	deleted     *prometheus.CounterVec
+	reclaimed   *prometheus.CounterVec
+	errors      *prometheus.CounterVec
+	stale       *prometheus.GaugeVec
+	staleBytes  *prometheus.GaugeVec
+	savings     *prometheus.GaugeVec
+	duration    *prometheus.GaugeVec
+	lastRun     *prometheus.GaugeVec
+	lastSuccess *prometheus.GaugeVec
+	registry    *prometheus.Registry
+}
+
+func newRunMetrics() *runMetrics {
+	labels := []string{"bucket", "policy"}
+	counter := func(name, help string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: "s3tidy", Name: name, Help: help}, labels)
+	}
+	gauge := func(name, help string) *prometheus.GaugeVec {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: "s3tidy", Name: name, Help: help}, labels)
+	}
+
+	m := &runMetrics{
+		scanned:     counter("objects_scanned_total", "Objects listed across all runs."),
+		deleted:     counter("objects_deleted_total", "Objects deleted across all runs."),
+		reclaimed:   counter("bytes_reclaimed_total", "Bytes freed by deletions across all runs."),
+		errors:      counter("errors_total", "Failed deletions and failed runs."),
+		stale:       gauge("stale_objects", "Stale objects found by the last run."),
+		staleBytes:  gauge("stale_bytes", "Reclaimable bytes found by the last run."),
+		savings:     gauge("estimated_monthly_savings_usd", "Estimated monthly savings of the last run's stale set."),
+		duration:    gauge("last_run_duration_seconds", "Wall-clock duration of the last run."),
+		lastRun:     gauge("last_run_timestamp_seconds", "Unix time the last run finished, successful or not."),
+		lastSuccess: gauge("last_success_timestamp_seconds", "Unix time the last successful run finished."),
+		registry:    prometheus.NewRegistry(),
+	}
+	m.registry.MustRegister(m.scanned, m.deleted, m.reclaimed, m.errors, m.stale, m.staleBytes,
+		m.savings, m.duration, m.lastRun, m.lastSuccess)
+	return m
+}
+
+// observe records one policy run. A nil receiver is a no-op so callers don't
+// need to care whether --metrics-addr was set.
+func (m *runMetrics) observe(res *scanner.Result, runErr error) {
+	if m == nil || res == nil {
+		return
+	}
+	l := prometheus.Labels{"bucket": res.Bucket, "policy": res.Policy}
+
+	m.lastRun.With(l).Set(float64(res.Finished.Unix()))
+	m.errors.With(l).Add(float64(res.Errors))
+	if runErr != nil {
+		return
+	}
+
+	m.scanned.With(l).Add(float64(res.Scanned))
+	m.deleted.With(l).Add(float64(res.Deleted))
+	m.reclaimed.With(l).Add(float64(res.DeletedBytes))
+	m.stale.With(l).Set(float64(res.Stale))
+	m.staleBytes.With(l).Set(float64(res.StaleBytes))
+	m.savings.With(l).Set(res.EstimatedSavings)
+	m.duration.With(l).Set(res.Finished.Sub(res.Started).Seconds())
+	m.lastSuccess.With(l).Set(float64(res.Finished.Unix()))
+}
+
+// serve starts the /metrics listener in the background.
+func (m *runMetrics) serve(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok\n")) })
+
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("⚠️ Metrics listener stopped: %v\n", err)
+		}
+	}()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/aslinger/s3-tidy/pkg/hook"
+	"github.com/aslinger/s3-tidy/pkg/notify"
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/spf13/cobra"
+)
+
+// Notification Flags (shared by scan and daemon)
+var (
+	publishCloudWatch   bool
+	cloudWatchNamespace string
+	slackWebhook        string
+	snsTopicARN         string
+	emitEventBridge     bool
+	eventBridgeBus      string
+	preDeleteHook       string
+	postRunHook         string
+	hookTimeout         time.Duration
+)
+
+func addNotifyFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&publishCloudWatch, "publish-cloudwatch", false, "Publish run results as custom CloudWatch metrics per bucket")
+	cmd.Flags().StringVar(&cloudWatchNamespace, "cloudwatch-namespace", "S3Tidy", "CloudWatch namespace for --publish-cloudwatch")
+	cmd.Flags().StringVar(&snsTopicARN, "notify-sns-topic", "", "SNS topic ARN receiving a JSON summary of each run")
+	cmd.Flags().BoolVar(&emitEventBridge, "emit-eventbridge", false, "Emit EventBridge events after each deletion batch and at run completion")
+	cmd.Flags().StringVar(&eventBridgeBus, "eventbridge-bus", "default", "Event bus name or ARN for --emit-eventbridge")
+	// Webhook URLs are credentials; the env var keeps them out of process listings.
+	cmd.Flags().StringVar(&slackWebhook, "notify-slack-webhook", os.Getenv("S3TIDY_SLACK_WEBHOOK"), "Slack incoming webhook URL for run summaries (env S3TIDY_SLACK_WEBHOOK)")
+	cmd.Flags().StringVar(&preDeleteHook, "pre-delete-hook", "", "Shell command or http(s) URL given each deletion batch's JSON manifest first; a failure skips the batch")
+	cmd.Flags().StringVar(&postRunHook, "post-run-hook", "", "Shell command or http(s) URL given the JSON run summary after each run")
+	cmd.Flags().DurationVar(&hookTimeout, "hook-timeout", hook.DefaultTimeout, "Kill a hook that runs longer than this")
+}
+
+// buildNotifiers turns the notification flags into notifiers. AWS-backed ones
+// share the default credential chain with the scan itself.
+func buildNotifiers(ctx context.Context) ([]notify.Notifier, error) {
+	var cfg *aws.Config
+	awsConfig := func() (aws.Config, error) {
+		if cfg == nil {
+			loaded, err := config.LoadDefaultConfig(ctx)
+			if err != nil {
+				return aws.Config{}, err
+			}
+			cfg = &loaded
+		}
+		return *cfg, nil
+	}
+
+	var notifiers []notify.Notifier
+	if publishCloudWatch {
+		c, err := awsConfig()
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, notify.NewCloudWatchPublisher(c, cloudWatchNamespace))
+	}
+	if snsTopicARN != "" {
+		c, err := awsConfig()
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, notify.NewSNSNotifier(c, snsTopicARN))
+	}
+	if emitEventBridge {
+		c, err := awsConfig()
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, notify.NewEventBridgeNotifier(c, eventBridgeBus))
+	}
+	if slackWebhook != "" {
+		notifiers = append(notifiers, notify.NewSlackNotifier(slackWebhook))
+	}
+	if postRunHook != "" {
+		notifiers = append(notifiers, hook.PostRun{Hook: hook.New(postRunHook, hookTimeout)})
+	}
+	return notifiers, nil
+}
+
+// preDeleteHooks returns the hooks to run before each deletion batch.
+func preDeleteHooks() []scanner.PreDeleteHook {
+	if preDeleteHook == "" {
+		return nil
+	}
+	return []scanner.PreDeleteHook{hook.PreDelete{Hook: hook.New(preDeleteHook, hookTimeout)}}
+}
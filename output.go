@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/aslinger/s3-tidy/pkg/signing"
+	"github.com/spf13/cobra"
+)
+
+// Output Flags (shared by scan and daemon)
+var (
+	quietOutput  bool
+	summaryOnly  bool
+	redactKeys   string // also registered by report
+	redactSecret string
+)
+
+// Sink Flags (scan only)
+var (
+	csvPath      string
+	manifestPath string
+	manifestKey  string
+	parquetPath  string
+)
+
+func addOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&quietOutput, "quiet", "q", false, "Print errors only")
+	cmd.Flags().BoolVar(&summaryOnly, "summary-only", false, "Suppress per-object lines and print one parseable key=value summary line per run")
+	cmd.MarkFlagsMutuallyExclusive("quiet", "summary-only")
+	addRedactFlag(cmd)
+}
+
+func addRedactFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&redactKeys, "redact-keys", "", "Hide object keys from progress lines, warnings, --csv, reports and notifications: hash or truncate (--manifest keeps full keys)")
+	// The secret is what stops anyone hashing guessed keys; the env var keeps it out of process listings.
+	cmd.Flags().StringVar(&redactSecret, "redact-secret", os.Getenv("S3TIDY_REDACT_SECRET"), "HMAC secret for --redact-keys hash, at least 16 bytes (env S3TIDY_REDACT_SECRET)")
+}
+
+// redaction is the key redaction the flags ask for.
+func redaction() scanner.Redaction {
+	return scanner.Redaction{Mode: scanner.RedactMode(redactKeys), Secret: []byte(redactSecret)}
+}
+
+func addSinkFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&csvPath, "csv", "", "Stream every stale object and its outcome to this CSV file as the scan runs")
+	cmd.Flags().StringVar(&manifestPath, "manifest", "", "Stream every stale object and its outcome to this file as JSON lines, local or s3://bucket/key")
+	cmd.Flags().StringVar(&parquetPath, "parquet", "", "Write every stale object and its outcome to this Parquet file, local or s3://bucket/key")
+	cmd.Flags().StringVar(&manifestKey, "manifest-signing-key", "", "Sign the finished --manifest with this HMAC secret or Ed25519 private key (PEM), writing <manifest>.sig")
+	cmd.MarkFlagsRequiredTogether("manifest-signing-key", "manifest")
+}
+
+// loadManifestKey loads --manifest-signing-key, if set, before a run starts:
+// a bad key, or a manifest that can't be signed, must not surface only after
+// objects were deleted. Signing reads the finished manifest back from disk,
+// so an s3:// manifest can't be signed.
+func loadManifestKey() (*signing.Key, error) {
+	if manifestKey == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(manifestPath, "s3://") {
+		return nil, fmt.Errorf("--manifest-signing-key needs a local --manifest; upload the manifest and its %s file afterwards", signing.Suffix)
+	}
+	key, err := signing.LoadKey(manifestKey)
+	if err != nil {
+		return nil, err
+	}
+	if !key.CanSign() {
+		return nil, fmt.Errorf("--manifest-signing-key is a public key; sign with the private key")
+	}
+	return key, nil
+}
+
+// signManifest writes the detached signature of the finished --manifest.
+func signManifest(key *signing.Key) error {
+	sig, err := key.SignFile(manifestPath, "", time.Now())
+	if err != nil {
+		return fmt.Errorf("unable to sign manifest: %w", err)
+	}
+	fmt.Fprintf(progressWriter(), "🔏 Manifest signed (%s, key %s): %s\n", sig.Algorithm, sig.KeyID, manifestPath+signing.Suffix)
+	return nil
+}
+
+// openSinks creates the files behind --csv, --manifest and --parquet. The
+// returned close function must run after the scan, once the scanner has
+// flushed the sinks.
+func openSinks(ctx context.Context) ([]scanner.Sink, func(), error) {
+	var sinks []scanner.Sink
+	var files []output
+	closeAll := func() {
+		for _, f := range files {
+			if err := f.Close(); err != nil {
+				log.Printf("⚠️ Failed to close %s: %v\n", f.Name(), err)
+			}
+		}
+	}
+	for _, s := range []struct {
+		path string
+		sink func(io.Writer) scanner.Sink
+	}{
+		{csvPath, func(w io.Writer) scanner.Sink { return redaction().Sink(scanner.NewCSVSink(w)) }},
+		{manifestPath, func(w io.Writer) scanner.Sink { return scanner.NewManifestSink(w) }},
+		{parquetPath, func(w io.Writer) scanner.Sink { return redaction().Sink(scanner.NewParquetSink(w)) }},
+	} {
+		if s.path == "" {
+			continue
+		}
+		f, err := createOutput(ctx, s.path)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		files = append(files, f)
+		sinks = append(sinks, s.sink(f))
+	}
+	return sinks, closeAll, nil
+}
+
+// progressWriter is where the scanner's banner, per-object lines and human summary go.
+// Warnings and errors use the log package (stderr) and are never suppressed.
+func progressWriter() io.Writer {
+	if quietOutput || summaryOnly {
+		return io.Discard
+	}
+	return os.Stdout
+}
+
+// runMode names what a run was allowed to do: report, dry-run or cleanup.
+func runMode(res *scanner.Result) string {
+	switch {
+	case res.Report:
+		return "report"
+	case res.DryRun:
+		return "dry-run"
+	}
+	return "cleanup"
+}
+
+// printRunSummary writes the --summary-only line. The field set and order are
+// part of the CLI contract: add new fields at the end, never rename or reorder.
+func printRunSummary(w io.Writer, res *scanner.Result) {
+	if !summaryOnly || res == nil {
+		return
+	}
+
+	fields := []struct {
+		k string
+		v string
+	}{
+		{"policy", res.Policy},
+		{"bucket", res.Bucket},
+		{"mode", runMode(res)},
+		{"scanned", strconv.Itoa(res.Scanned)},
+		{"stale", strconv.Itoa(res.Stale)},
+		{"stale_bytes", strconv.FormatInt(res.StaleBytes, 10)},
+		{"deleted", strconv.Itoa(res.Deleted)},
+		{"deleted_bytes", strconv.FormatInt(res.DeletedBytes, 10)},
+		{"excluded", strconv.Itoa(res.Excluded)},
+		{"retained", strconv.Itoa(res.Retained)},
+		{"kept", strconv.Itoa(res.Kept)},
+		{"errors", strconv.Itoa(res.Errors)},
+		{"estimated_savings_usd", strconv.FormatFloat(res.EstimatedSavings, 'f', 4, 64)},
+		{"duration_seconds", strconv.FormatFloat(res.Finished.Sub(res.Started).Seconds(), 'f', 1, 64)},
+		{"filtered", strconv.Itoa(res.Filtered)},
+		{"archived", strconv.Itoa(res.Archived)},
+		{"modified_since_scan", strconv.Itoa(res.ModifiedSinceScan)},
+		{"currency", res.Currency},
+		{"estimated_savings", strconv.FormatFloat(res.EstimatedSavingsLocal, 'f', 4, 64)},
+		{"list_requests", strconv.FormatInt(res.Requests.List, 10)},
+		{"get_requests", strconv.FormatInt(res.Requests.Get, 10)},
+		{"put_requests", strconv.FormatInt(res.Requests.Put, 10)},
+		{"delete_requests", strconv.FormatInt(res.Requests.Delete, 10)},
+		{"request_cost_usd", strconv.FormatFloat(res.RequestCost, 'f', 4, 64)},
+		{"deferred", strconv.Itoa(res.Deferred)},
+		{"recently_read", strconv.Itoa(res.RecentlyRead)},
+		{"unreplicated", strconv.Itoa(res.Unreplicated)},
+		{"encrypted", strconv.Itoa(res.Encrypted)},
+		{"missing", strconv.Itoa(res.Missing)},
+		{"truncated", strconv.FormatBool(res.Truncated)},
+		{"resume_after", res.ResumeAfter},
+		{"delete_markers", strconv.Itoa(res.DeleteMarkers)},
+		{"tagged", strconv.Itoa(res.Tagged)},
+	}
+
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		v := f.v
+		if v == "" || strings.ContainsAny(v, " \t\"=") {
+			v = strconv.Quote(v)
+		}
+		parts[i] = f.k + "=" + v
+	}
+	fmt.Fprintln(w, "s3tidy_summary "+strings.Join(parts, " "))
+}
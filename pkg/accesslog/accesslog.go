@@ -0,0 +1,287 @@
+// Package accesslog reads S3 server access logs to find out when objects were
+// last read, so data that is old but still in use isn't mistaken for stale.
+package accesslog
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// API is the subset of the S3 client reading logs needs.
+type API interface {
+	ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	GetObject(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// Source says where a bucket's server access logs are delivered and how far
+// back to read them. The zero value means access logs aren't used.
+type Source struct {
+	Bucket string // logging bucket
+	Prefix string // target prefix logs are written under
+	Format Format
+	// Query, when set, is asked instead of reading the log files.
+	Query Query
+	Since time.Time
+}
+
+// Format is the kind of log files a Source holds.
+type Format string
+
+const (
+	// FormatServerAccess is S3 server access logging; it is the default.
+	FormatServerAccess Format = ""
+	// FormatCloudTrail is CloudTrail S3 data events delivered by a trail.
+	FormatCloudTrail Format = "cloudtrail"
+)
+
+// Enabled reports whether a logging bucket or query is configured.
+func (s Source) Enabled() bool { return s.Bucket != "" || s.Query != nil }
+
+// Describe names where reads come from, for the scan banner.
+func (s Source) Describe() string {
+	if s.Query != nil {
+		return s.Query.Describe()
+	}
+	if s.Format == FormatCloudTrail {
+		return fmt.Sprintf("CloudTrail data events in 's3://%s/%s'", s.Bucket, s.Prefix)
+	}
+	return fmt.Sprintf("access logs in 's3://%s/%s'", s.Bucket, s.Prefix)
+}
+
+// fetchConcurrency is how many log files are downloaded at once. S3 delivers
+// many small files, so fetching them one by one would dominate the run.
+const fetchConcurrency = 16
+
+// timeLayout is the bracketed request time, e.g. [06/Feb/2019:00:00:38 +0000].
+const timeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// readOperations are the operations that count as reading an object. HEAD is
+// included because existence checks usually precede a read; erring towards
+// "read" keeps data rather than deleting it.
+var readOperations = map[string]bool{
+	"REST.GET.OBJECT":      true,
+	"REST.HEAD.OBJECT":     true,
+	"REST.COPY.OBJECT_GET": true,
+	"REST.COPY.PART_GET":   true,
+}
+
+// Entry is the part of one access log record that matters here.
+type Entry struct {
+	Bucket    string
+	Time      time.Time
+	Operation string
+	Key       string
+	Status    int
+}
+
+// IsRead reports whether the record is a successful read of an object.
+func (e Entry) IsRead() bool {
+	return readOperations[e.Operation] && e.Key != "" && e.Status > 0 && e.Status < 400
+}
+
+// ParseLine parses one record of the S3 server access log format. Only the
+// leading fields up to the HTTP status are interpreted; later fields have been
+// added over the years and are ignored.
+func ParseLine(line string) (Entry, error) {
+	fields := splitFields(line, 10)
+	if len(fields) < 10 {
+		return Entry{}, fmt.Errorf("expected at least 10 fields, got %d", len(fields))
+	}
+	t, err := time.Parse(timeLayout, strings.Trim(fields[2], "[]"))
+	if err != nil {
+		return Entry{}, fmt.Errorf("bad time %q: %w", fields[2], err)
+	}
+	e := Entry{Bucket: fields[1], Time: t, Operation: fields[6]}
+	if key := fields[7]; key != "-" {
+		// Keys are logged URL-encoded.
+		if k, err := url.PathUnescape(key); err == nil {
+			key = k
+		}
+		e.Key = key
+	}
+	if fields[9] != "-" {
+		if e.Status, err = strconv.Atoi(fields[9]); err != nil {
+			return Entry{}, fmt.Errorf("bad status %q: %w", fields[9], err)
+		}
+	}
+	return e, nil
+}
+
+// splitFields splits a log line on spaces, keeping [bracketed] and "quoted"
+// fields whole, and stops after n fields.
+func splitFields(line string, n int) []string {
+	var fields []string
+	for len(line) > 0 && len(fields) < n {
+		line = strings.TrimLeft(line, " ")
+		if line == "" {
+			break
+		}
+		end := strings.IndexByte(line, ' ')
+		switch line[0] {
+		case '[':
+			end = closing(line, ']')
+		case '"':
+			end = closing(line, '"')
+		}
+		if end < 0 {
+			end = len(line)
+		}
+		fields = append(fields, line[:end])
+		line = line[end:]
+	}
+	return fields
+}
+
+// closing returns the index just past the first close after line[0], or -1.
+func closing(line string, close byte) int {
+	i := strings.IndexByte(line[1:], close)
+	if i < 0 {
+		return -1
+	}
+	return i + 2
+}
+
+// Reads maps keys to the last time they were read within the log window.
+type Reads struct {
+	last map[string]time.Time
+	// Files and Lines count what was read (log files and their lines, or
+	// query result rows); Malformed counts skipped lines.
+	Files, Lines, Malformed int
+}
+
+// LastRead returns when key was last read, if it was within the window.
+func (r *Reads) LastRead(key string) (time.Time, bool) {
+	t, ok := r.last[key]
+	return t, ok
+}
+
+// Len is the number of distinct keys read within the window.
+func (r *Reads) Len() int { return len(r.last) }
+
+func (r *Reads) add(e Entry) {
+	if t, ok := r.last[e.Key]; !ok || e.Time.After(t) {
+		r.last[e.Key] = e.Time
+	}
+}
+
+// Load reads every log file delivered to src since src.Since and returns the
+// reads of objects in bucket from that time on. Log files are picked by their
+// LastModified, which S3 sets within hours of the requests they hold. Only keys
+// actually read are kept in memory.
+func Load(ctx context.Context, client API, src Source, bucket string) (*Reads, error) {
+	if src.Query != nil {
+		return src.Query.Reads(ctx, bucket, src.Since)
+	}
+	reads := &Reads{last: make(map[string]time.Time)}
+
+	var files []string
+	p := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{Bucket: aws.String(src.Bucket), Prefix: aws.String(src.Prefix)})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list access logs in 's3://%s/%s': %w", src.Bucket, src.Prefix, err)
+		}
+		for _, obj := range page.Contents {
+			if obj.LastModified != nil && obj.LastModified.Before(src.Since) {
+				continue
+			}
+			files = append(files, aws.ToString(obj.Key))
+		}
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	sem := make(chan struct{}, fetchConcurrency)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for _, key := range files {
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			// A fetch failed or the caller gave up: start no more.
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func(key string) {
+			defer func() { <-sem; wg.Done() }()
+			entries, malformed, err := fetch(ctx, client, src, key)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("read access log %s: %w", key, err)
+					cancel()
+				}
+				return
+			}
+			reads.Files++
+			reads.Lines += len(entries) + malformed
+			reads.Malformed += malformed
+			for _, e := range entries {
+				if e.Bucket == bucket && e.IsRead() && !e.Time.Before(src.Since) {
+					reads.add(e)
+				}
+			}
+		}(key)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	// Reads from only some of the files would make used objects look stale.
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("read access logs: %w", err)
+	}
+	return reads, nil
+}
+
+// fetch downloads and parses one log file.
+func fetch(ctx context.Context, client API, src Source, key string) ([]Entry, int, error) {
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(src.Bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer out.Body.Close()
+
+	if src.Format == FormatCloudTrail {
+		return parseCloudTrail(out.Body, strings.HasSuffix(key, ".gz"))
+	}
+	return parseServerAccess(out.Body)
+}
+
+// parseServerAccess parses a server access log file, one record per line.
+func parseServerAccess(r io.Reader) ([]Entry, int, error) {
+	var entries []Entry
+	malformed := 0
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		e, err := ParseLine(sc.Text())
+		if err != nil {
+			malformed++
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, 0, err
+	}
+	return entries, malformed, nil
+}
@@ -0,0 +1,201 @@
+package accesslog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// logLine is a server access log record in the documented field order, with
+// requester and request IDs shortened.
+func logLine(bucket, at, op, key, uri, status, agent string) string {
+	return "79a59df900b949e55d96a1e698fbaced " + bucket + " [" + at + "] 192.0.2.3 arn:aws:iam::123456789012:user/ci 3E57427F3EXAMPLE " +
+		op + " " + key + ` "` + uri + `" ` + status + ` - 2662992 3462992 70 10 "-" "` + agent + `" - s9lzHYrFp76ZVxRcpX9+5cjAnEH2ROuNkd2BHfIa6UkFVdtjf5mKR3/eTPFvsiP/XV/VLi31234= SigV4 ECDHE-RSA-AES128-GCM-SHA256 AuthHeader ` +
+		bucket + ".s3.us-west-1.amazonaws.com TLSv1.2 - -"
+}
+
+func TestParseLine(t *testing.T) {
+	at := time.Date(2019, 2, 6, 0, 0, 38, 0, time.UTC)
+	for _, tc := range []struct {
+		name string
+		line string
+		want Entry
+		read bool
+	}{
+		{
+			name: "read with a quoted user agent",
+			line: logLine("b", "06/Feb/2019:00:00:38 +0000", "REST.GET.OBJECT", "data/report.csv", "GET /b/data/report.csv HTTP/1.1", "200", "aws-cli/2.15.0 Python/3.11.6 Linux/6.1 exe/x86_64 command/s3.cp"),
+			want: Entry{Bucket: "b", Time: at, Operation: "REST.GET.OBJECT", Key: "data/report.csv", Status: 200},
+			read: true,
+		},
+		{
+			name: "encoded key",
+			line: logLine("b", "06/Feb/2019:00:00:38 +0000", "REST.GET.OBJECT", "photos/summer%20trip/img%2B1%5B2%5D.jpg", "GET /b/photos/summer%20trip/img%2B1%5B2%5D.jpg HTTP/1.1", "206", "Mozilla/5.0 (X11; Linux x86_64)"),
+			want: Entry{Bucket: "b", Time: at, Operation: "REST.GET.OBJECT", Key: "photos/summer trip/img+1[2].jpg", Status: 206},
+			read: true,
+		},
+		{
+			name: "bucket operation without a key",
+			line: logLine("b", "06/Feb/2019:00:00:38 +0000", "REST.GET.VERSIONING", "-", "GET /b?versioning HTTP/1.1", "200", "S3Console/0.4"),
+			want: Entry{Bucket: "b", Time: at, Operation: "REST.GET.VERSIONING", Status: 200},
+		},
+		{
+			name: "not modified",
+			line: logLine("b", "06/Feb/2019:00:00:38 +0000", "REST.HEAD.OBJECT", "data/report.csv", "HEAD /b/data/report.csv HTTP/1.1", "304", "curl/8.5.0"),
+			want: Entry{Bucket: "b", Time: at, Operation: "REST.HEAD.OBJECT", Key: "data/report.csv", Status: 304},
+			read: true,
+		},
+		{
+			name: "denied",
+			line: logLine("b", "06/Feb/2019:00:00:38 +0000", "REST.GET.OBJECT", "data/report.csv", "GET /b/data/report.csv HTTP/1.1", "403", "curl/8.5.0"),
+			want: Entry{Bucket: "b", Time: at, Operation: "REST.GET.OBJECT", Key: "data/report.csv", Status: 403},
+		},
+		{
+			name: "missing",
+			line: logLine("b", "06/Feb/2019:00:00:38 +0000", "REST.GET.OBJECT", "data/gone.csv", "GET /b/data/gone.csv HTTP/1.1", "404", "curl/8.5.0"),
+			want: Entry{Bucket: "b", Time: at, Operation: "REST.GET.OBJECT", Key: "data/gone.csv", Status: 404},
+		},
+		{
+			name: "other time zone",
+			line: logLine("b", "05/Feb/2019:16:00:38 -0800", "REST.COPY.OBJECT_GET", "data/report.csv", "PUT /b/copy.csv HTTP/1.1", "200", "aws-sdk-go-v2/1.24.0"),
+			want: Entry{Bucket: "b", Time: at, Operation: "REST.COPY.OBJECT_GET", Key: "data/report.csv", Status: 200},
+			read: true,
+		},
+		{
+			name: "write",
+			line: logLine("b", "06/Feb/2019:00:00:38 +0000", "REST.PUT.OBJECT", "data/report.csv", "PUT /b/data/report.csv HTTP/1.1", "200", "aws-cli/2.15.0"),
+			want: Entry{Bucket: "b", Time: at, Operation: "REST.PUT.OBJECT", Key: "data/report.csv", Status: 200},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := ParseLine(tc.line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !e.Time.Equal(tc.want.Time) {
+				t.Errorf("time = %s, want %s", e.Time, tc.want.Time)
+			}
+			e.Time = tc.want.Time
+			if e != tc.want {
+				t.Errorf("entry = %+v, want %+v", e, tc.want)
+			}
+			if e.IsRead() != tc.read {
+				t.Errorf("IsRead = %v, want %v", e.IsRead(), tc.read)
+			}
+		})
+	}
+}
+
+func TestParseLineRejectsMalformed(t *testing.T) {
+	for _, line := range []string{
+		"79a59df9 b [06/Feb/2019:00:00:38 +0000] 192.0.2.3",
+		logLine("b", "2019-02-06T00:00:38Z", "REST.GET.OBJECT", "k", "GET /b/k HTTP/1.1", "200", "curl/8.5.0"),
+		logLine("b", "06/Feb/2019:00:00:38 +0000", "REST.GET.OBJECT", "k", "GET /b/k HTTP/1.1", "OK", "curl/8.5.0"),
+		// An unterminated quote swallows the rest of the line.
+		`79a59df9 b [06/Feb/2019:00:00:38 +0000] 192.0.2.3 - 3E57 REST.GET.OBJECT k "GET /b/k HTTP/1.1 200 - 1 1 1 1`,
+	} {
+		if e, err := ParseLine(line); err == nil {
+			t.Errorf("%q parsed as %+v", line, e)
+		}
+	}
+}
+
+// logClient serves log files from memory. GetObject of a key in fail fails;
+// every other GetObject waits for block, if set, or ctx.
+type logClient struct {
+	files map[string]string
+	fail  string
+	block bool
+	gets  atomic.Int32
+}
+
+func (c *logClient) ListObjectsV2(_ context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	out := &s3.ListObjectsV2Output{}
+	for k := range c.files {
+		if strings.HasPrefix(k, aws.ToString(in.Prefix)) {
+			out.Contents = append(out.Contents, types.Object{Key: aws.String(k), LastModified: aws.Time(time.Date(2019, 2, 6, 1, 0, 0, 0, time.UTC))})
+		}
+	}
+	sort.Slice(out.Contents, func(i, j int) bool { return *out.Contents[i].Key < *out.Contents[j].Key })
+	return out, nil
+}
+
+func (c *logClient) GetObject(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	c.gets.Add(1)
+	if aws.ToString(in.Key) == c.fail {
+		return nil, errors.New("connection reset")
+	}
+	if c.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(c.files[aws.ToString(in.Key)]))}, nil
+}
+
+func TestLoad(t *testing.T) {
+	get := func(bucket, at, key, status string) string {
+		return logLine(bucket, at, "REST.GET.OBJECT", key, "GET /"+bucket+"/"+key+" HTTP/1.1", status, "curl/8.5.0")
+	}
+	c := &logClient{files: map[string]string{
+		"logs/2019-02-06-00-00-00-A": strings.Join([]string{
+			get("b", "06/Feb/2019:00:00:38 +0000", "a", "200"),
+			get("b", "06/Feb/2019:00:10:00 +0000", "a", "200"),
+			get("b", "06/Feb/2019:00:20:00 +0000", "denied", "403"),
+			get("other", "06/Feb/2019:00:20:00 +0000", "elsewhere", "200"),
+			get("b", "01/Feb/2019:00:00:00 +0000", "before-window", "200"),
+			"garbage",
+		}, "\n"),
+		"logs/2019-02-06-00-00-00-B": get("b", "06/Feb/2019:00:05:00 +0000", "a%20b", "200") + "\n",
+		"unrelated/file":             get("b", "06/Feb/2019:00:05:00 +0000", "not-a-log", "200") + "\n",
+	}}
+	reads, err := Load(context.Background(), c, Source{Bucket: "logs-bucket", Prefix: "logs/", Since: time.Date(2019, 2, 5, 0, 0, 0, 0, time.UTC)}, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reads.Files != 2 || reads.Lines != 7 || reads.Malformed != 1 || reads.Len() != 2 {
+		t.Errorf("files %d, lines %d, malformed %d, keys %d; want 2, 7, 1, 2", reads.Files, reads.Lines, reads.Malformed, reads.Len())
+	}
+	if got, _ := reads.LastRead("a"); !got.Equal(time.Date(2019, 2, 6, 0, 10, 0, 0, time.UTC)) {
+		t.Errorf("a last read %s, want the later read", got)
+	}
+	if _, ok := reads.LastRead("a b"); !ok {
+		t.Error("encoded key not decoded")
+	}
+	for _, key := range []string{"denied", "elsewhere", "before-window", "not-a-log"} {
+		if _, ok := reads.LastRead(key); ok {
+			t.Errorf("%s counted as read", key)
+		}
+	}
+}
+
+func TestLoadStopsAfterFailure(t *testing.T) {
+	c := &logClient{files: map[string]string{}, fail: "logs/000", block: true}
+	for i := range 200 {
+		c.files[fmt.Sprintf("logs/%03d", i)] = ""
+	}
+	if _, err := Load(context.Background(), c, Source{Bucket: "logs-bucket", Prefix: "logs/"}, "b"); err == nil {
+		t.Fatal("no error")
+	}
+	if n := c.gets.Load(); n > fetchConcurrency {
+		t.Errorf("%d log files fetched after the first failed, want at most %d in flight", n, fetchConcurrency)
+	}
+}
+
+func TestLoadFailsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c := &logClient{files: map[string]string{"logs/a": ""}}
+	if _, err := Load(ctx, c, Source{Bucket: "logs-bucket", Prefix: "logs/"}, "b"); err == nil {
+		t.Error("a cancelled load returned reads")
+	}
+}
@@ -0,0 +1,172 @@
+package accesslog
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+)
+
+// Query answers which objects of a bucket were read since a time from
+// somewhere other than the raw log files, e.g. a data lake already holding them.
+type Query interface {
+	Reads(ctx context.Context, bucket string, since time.Time) (*Reads, error)
+	Describe() string
+}
+
+// AthenaAPI is the subset of the Athena client queries need.
+type AthenaAPI interface {
+	StartQueryExecution(ctx context.Context, in *athena.StartQueryExecutionInput, optFns ...func(*athena.Options)) (*athena.StartQueryExecutionOutput, error)
+	GetQueryExecution(ctx context.Context, in *athena.GetQueryExecutionInput, optFns ...func(*athena.Options)) (*athena.GetQueryExecutionOutput, error)
+	GetQueryResults(ctx context.Context, in *athena.GetQueryResultsInput, optFns ...func(*athena.Options)) (*athena.GetQueryResultsOutput, error)
+}
+
+// DefaultAthenaQuery reads the table layout AWS documents for server access
+// logs. {{table}} is replaced with AthenaOptions.Table; the parameters are the
+// bucket name and the start of the window as an ISO 8601 timestamp.
+const DefaultAthenaQuery = `SELECT key, to_iso8601(max(parse_datetime(requestdatetime, 'dd/MMM/yyyy:HH:mm:ss Z'))) AS last_read
+FROM {{table}}
+WHERE bucket_name = ?
+  AND operation IN ('REST.GET.OBJECT', 'REST.HEAD.OBJECT', 'REST.COPY.OBJECT_GET', 'REST.COPY.PART_GET')
+  AND regexp_like(httpstatus, '^[23]')
+  AND parse_datetime(requestdatetime, 'dd/MMM/yyyy:HH:mm:ss Z') >= from_iso8601_timestamp(?)
+GROUP BY key`
+
+// athenaPollInterval is how often a running query's state is checked.
+const athenaPollInterval = 2 * time.Second
+
+// AthenaOptions configures an Athena query. A custom query must take the same
+// two parameters as DefaultAthenaQuery and return (key, last_read) rows with
+// last_read in ISO 8601; keys are URL-decoded like raw log keys.
+type AthenaOptions struct {
+	Table          string // database.table holding the access logs
+	QueryFile      string // custom SQL; empty means DefaultAthenaQuery
+	Workgroup      string // empty means the default workgroup
+	OutputLocation string // s3:// URI for results; empty means the workgroup's
+}
+
+// Athena is a Query run through Amazon Athena.
+type Athena struct {
+	client AthenaAPI
+	opts   AthenaOptions
+	query  string
+}
+
+// NewAthena reads the custom query, if any, and returns a Query for opts.
+func NewAthena(client AthenaAPI, opts AthenaOptions) (*Athena, error) {
+	sql := DefaultAthenaQuery
+	if opts.QueryFile != "" {
+		raw, err := os.ReadFile(opts.QueryFile)
+		if err != nil {
+			return nil, fmt.Errorf("read Athena query: %w", err)
+		}
+		sql = string(raw)
+	} else if opts.Table == "" {
+		return nil, fmt.Errorf("an Athena query needs a table or a query file")
+	}
+	return &Athena{client: client, opts: opts, query: strings.ReplaceAll(sql, "{{table}}", opts.Table)}, nil
+}
+
+// Describe names the query's source for the scan banner.
+func (a *Athena) Describe() string {
+	if a.opts.QueryFile != "" {
+		return "Athena query " + a.opts.QueryFile
+	}
+	return "Athena table " + a.opts.Table
+}
+
+// Reads runs the query and collects its rows.
+func (a *Athena) Reads(ctx context.Context, bucket string, since time.Time) (*Reads, error) {
+	in := &athena.StartQueryExecutionInput{
+		QueryString:         aws.String(a.query),
+		ExecutionParameters: []string{quote(bucket), quote(since.UTC().Format(time.RFC3339))},
+	}
+	if a.opts.Workgroup != "" {
+		in.WorkGroup = aws.String(a.opts.Workgroup)
+	}
+	if a.opts.OutputLocation != "" {
+		in.ResultConfiguration = &types.ResultConfiguration{OutputLocation: aws.String(a.opts.OutputLocation)}
+	}
+	start, err := a.client.StartQueryExecution(ctx, in)
+	if err != nil {
+		return nil, fmt.Errorf("start Athena query: %w", err)
+	}
+	id := start.QueryExecutionId
+
+	if err := a.wait(ctx, id); err != nil {
+		return nil, err
+	}
+
+	reads := &Reads{last: make(map[string]time.Time)}
+	p := athena.NewGetQueryResultsPaginator(a.client, &athena.GetQueryResultsInput{QueryExecutionId: id})
+	header := true
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("read Athena results: %w", err)
+		}
+		if page.ResultSet == nil {
+			continue
+		}
+		for _, row := range page.ResultSet.Rows {
+			// The first row of the first page holds the column names.
+			if header {
+				header = false
+				continue
+			}
+			reads.Lines++
+			if len(row.Data) < 2 {
+				reads.Malformed++
+				continue
+			}
+			key := aws.ToString(row.Data[0].VarCharValue)
+			t, err := time.Parse(time.RFC3339Nano, aws.ToString(row.Data[1].VarCharValue))
+			if key == "" || err != nil {
+				reads.Malformed++
+				continue
+			}
+			if k, err := url.PathUnescape(key); err == nil {
+				key = k
+			}
+			reads.add(Entry{Key: key, Time: t})
+		}
+	}
+	return reads, nil
+}
+
+// wait polls until the query finishes, returning its failure reason if any.
+func (a *Athena) wait(ctx context.Context, id *string) error {
+	for {
+		out, err := a.client.GetQueryExecution(ctx, &athena.GetQueryExecutionInput{QueryExecutionId: id})
+		if err != nil {
+			return fmt.Errorf("check Athena query %s: %w", aws.ToString(id), err)
+		}
+		status := &types.QueryExecutionStatus{}
+		if out.QueryExecution != nil && out.QueryExecution.Status != nil {
+			status = out.QueryExecution.Status
+		}
+		switch status.State {
+		case types.QueryExecutionStateSucceeded:
+			return nil
+		case types.QueryExecutionStateFailed, types.QueryExecutionStateCancelled:
+			return fmt.Errorf("athena query %s %s: %s", aws.ToString(id), strings.ToLower(string(status.State)), aws.ToString(status.StateChangeReason))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(athenaPollInterval):
+		}
+	}
+}
+
+// quote renders a string as an Athena execution parameter, which is spliced
+// into the query as a SQL literal.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
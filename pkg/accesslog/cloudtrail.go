@@ -0,0 +1,65 @@
+package accesslog
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// cloudTrailOperations maps CloudTrail S3 data event names onto the server
+// access log operations IsRead knows.
+var cloudTrailOperations = map[string]string{
+	"GetObject":  "REST.GET.OBJECT",
+	"HeadObject": "REST.HEAD.OBJECT",
+}
+
+// cloudTrailFile is the part of a CloudTrail log file that matters here.
+type cloudTrailFile struct {
+	Records []struct {
+		EventTime         time.Time `json:"eventTime"`
+		EventSource       string    `json:"eventSource"`
+		EventName         string    `json:"eventName"`
+		ErrorCode         string    `json:"errorCode"`
+		RequestParameters struct {
+			BucketName string `json:"bucketName"`
+			Key        string `json:"key"`
+		} `json:"requestParameters"`
+	} `json:"Records"`
+}
+
+// parseCloudTrail parses one CloudTrail log file, which trails deliver
+// gzipped. Only S3 data events become entries; failed calls are kept with no
+// status, so they never count as reads.
+func parseCloudTrail(r io.Reader, gzipped bool) ([]Entry, int, error) {
+	if gzipped {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	var file cloudTrailFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, 0, err
+	}
+
+	entries := make([]Entry, 0, len(file.Records))
+	for _, rec := range file.Records {
+		if rec.EventSource != "s3.amazonaws.com" {
+			continue
+		}
+		e := Entry{
+			Bucket:    rec.RequestParameters.BucketName,
+			Time:      rec.EventTime,
+			Operation: cloudTrailOperations[rec.EventName],
+			Key:       rec.RequestParameters.Key,
+		}
+		if rec.ErrorCode == "" {
+			e.Status = 200
+		}
+		entries = append(entries, e)
+	}
+	return entries, 0, nil
+}
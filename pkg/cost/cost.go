@@ -0,0 +1,231 @@
+// Package cost estimates what stale S3 storage costs and what removing it saves.
+package cost
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// StandardPricePerGB is the approximate S3 Standard price in USD per GB-month.
+const StandardPricePerGB = 0.023
+
+// ListPrices are approximate public us-east-1 storage prices in USD per
+// GB-month, used for storage classes a Pricing doesn't override.
+var ListPrices = map[string]float64{
+	string(types.ObjectStorageClassStandard):           StandardPricePerGB,
+	string(types.ObjectStorageClassReducedRedundancy):  0.024,
+	string(types.ObjectStorageClassStandardIa):         0.0125,
+	string(types.ObjectStorageClassOnezoneIa):          0.01,
+	string(types.ObjectStorageClassIntelligentTiering): 0.023,
+	string(types.ObjectStorageClassGlacierIr):          0.004,
+	string(types.ObjectStorageClassGlacier):            0.0036,
+	string(types.ObjectStorageClassDeepArchive):        0.00099,
+	string(types.ObjectStorageClassExpressOnezone):     0.11,
+
+	// Google Cloud Storage classes (Standard shares S3's entry; set
+	// --price-per-gb to its 0.020 for a closer estimate).
+	"NEARLINE": 0.010,
+	"COLDLINE": 0.004,
+	"ARCHIVE":  0.0012,
+
+	// Azure Blob Storage access tiers (LRS), named as Azure reports them.
+	"Hot":     0.018,
+	"Cool":    0.01,
+	"Cold":    0.0036,
+	"Archive": 0.002,
+}
+
+// List prices in USD per 1,000 requests. DELETE requests are free.
+const (
+	ListRequestPricePer1000 = 0.005  // LIST, and PUT/COPY/POST
+	GetRequestPricePer1000  = 0.0004 // GET, HEAD
+)
+
+// S3 Express One Zone (directory bucket) request prices in USD per 1,000.
+const (
+	ExpressPutRequestPricePer1000 = 0.00113 // PUT, COPY, POST, LIST
+	ExpressGetRequestPricePer1000 = 0.00003 // GET, HEAD
+)
+
+// S3 Intelligent-Tiering moves objects not accessed for 30 days to its
+// Infrequent Access tier and after 90 days to Archive Instant Access, charging
+// a monitoring fee per object. Objects under 128 KiB are never moved.
+const (
+	IntelligentTieringInfrequentPerGB     = 0.0125
+	IntelligentTieringArchiveInstantPerGB = 0.004
+	IntelligentTieringMonitoringPer1000   = 0.0025
+	IntelligentTieringMinObjectSize       = 128 * 1024
+)
+
+// GB converts bytes to (binary) gigabytes, the unit S3 bills storage in.
+func GB(bytes int64) float64 {
+	return float64(bytes) / 1024 / 1024 / 1024
+}
+
+// Pricing overrides storage rates (e.g. negotiated discounts) and picks the
+// currency amounts are shown in. Rates are in USD, what S3 bills in; the zero
+// value means list prices shown in USD.
+type Pricing struct {
+	// PricePerGB replaces the S3 Standard rate; other classes keep their list
+	// price unless StorageClasses sets one.
+	PricePerGB float64 `yaml:"price_per_gb"`
+	// StorageClasses sets rates per storage class, e.g. STANDARD_IA: 0.01.
+	StorageClasses map[string]float64 `yaml:"storage_classes"`
+	// Currency is the ISO 4217 code amounts are displayed in; empty means USD.
+	Currency string `yaml:"currency"`
+	// Rate converts USD to Currency (units of Currency per USD).
+	Rate float64 `yaml:"rate"`
+
+	// Request rates in USD per 1,000 requests; zero means the list price.
+	ListRequestsPer1000 float64 `yaml:"list_requests_per_1000"`
+	GetRequestsPer1000  float64 `yaml:"get_requests_per_1000"`
+	PutRequestsPer1000  float64 `yaml:"put_requests_per_1000"`
+}
+
+// KnownClass reports whether class is an S3 storage class or one of the other
+// providers' classes in ListPrices.
+func KnownClass(class string) bool {
+	if _, listed := ListPrices[class]; listed {
+		return true
+	}
+	return slices.Contains(types.ObjectStorageClass("").Values(), types.ObjectStorageClass(class))
+}
+
+// Validate rejects negative rates, unknown storage classes and a currency
+// without a conversion rate.
+func (p Pricing) Validate() error {
+	if p.PricePerGB < 0 {
+		return fmt.Errorf("price per GB must not be negative (got %g)", p.PricePerGB)
+	}
+	for class, price := range p.StorageClasses {
+		if !KnownClass(class) {
+			return fmt.Errorf("unknown storage class %q in pricing", class)
+		}
+		if price < 0 {
+			return fmt.Errorf("price for %s must not be negative (got %g)", class, price)
+		}
+	}
+	if p.Currency != "" && len(p.Currency) != 3 {
+		return fmt.Errorf("currency %q is not a 3-letter ISO 4217 code", p.Currency)
+	}
+	if p.ListRequestsPer1000 < 0 || p.GetRequestsPer1000 < 0 || p.PutRequestsPer1000 < 0 {
+		return fmt.Errorf("request prices must not be negative")
+	}
+	if p.Rate < 0 {
+		return fmt.Errorf("currency rate must not be negative (got %g)", p.Rate)
+	}
+	if p.CurrencyCode() != "USD" && p.Rate == 0 {
+		return fmt.Errorf("currency %s needs a conversion rate from USD", p.CurrencyCode())
+	}
+	return nil
+}
+
+// PerGB is the USD rate per GB-month for a storage class; empty means STANDARD.
+func (p Pricing) PerGB(class string) float64 {
+	if class == "" {
+		class = string(types.ObjectStorageClassStandard)
+	}
+	if price, ok := p.StorageClasses[class]; ok {
+		return price
+	}
+	if class == string(types.ObjectStorageClassStandard) && p.PricePerGB > 0 {
+		return p.PricePerGB
+	}
+	if price, ok := ListPrices[class]; ok {
+		return price
+	}
+	// Classes without a list price (e.g. OUTPOSTS) are priced like Standard.
+	return p.PerGB("")
+}
+
+// MonthlySavings estimates, in USD, the monthly storage cost deleting bytes of
+// the given storage class saves.
+func (p Pricing) MonthlySavings(bytes int64, class string) float64 {
+	return GB(bytes) * p.PerGB(class)
+}
+
+// IntelligentTieringCost projects the monthly USD cost of one object in
+// Intelligent-Tiering once it has gone idle without access, monitoring fee
+// included. The frequent tier is priced like the INTELLIGENT_TIERING class.
+func (p Pricing) IntelligentTieringCost(bytes int64, idle time.Duration) float64 {
+	if bytes < IntelligentTieringMinObjectSize {
+		return p.MonthlySavings(bytes, string(types.ObjectStorageClassIntelligentTiering))
+	}
+	rate := p.PerGB(string(types.ObjectStorageClassIntelligentTiering))
+	switch days := idle.Hours() / 24; {
+	case days >= 90:
+		rate = IntelligentTieringArchiveInstantPerGB
+	case days >= 30:
+		rate = IntelligentTieringInfrequentPerGB
+	}
+	return GB(bytes)*rate + IntelligentTieringMonitoringPer1000/1000
+}
+
+// RequestCost is what the given numbers of LIST, GET/HEAD and PUT/COPY
+// requests cost in USD. DELETE requests are free, so they don't appear.
+func (p Pricing) RequestCost(list, get, put int64) float64 {
+	rate := func(override, list float64) float64 {
+		if override > 0 {
+			return override
+		}
+		return list
+	}
+	return float64(list)/1000*rate(p.ListRequestsPer1000, ListRequestPricePer1000) +
+		float64(get)/1000*rate(p.GetRequestsPer1000, GetRequestPricePer1000) +
+		float64(put)/1000*rate(p.PutRequestsPer1000, ListRequestPricePer1000)
+}
+
+// ExpressRequestCost is RequestCost for a directory bucket, at S3 Express One
+// Zone list prices; the request overrides apply to general purpose buckets only.
+func (p Pricing) ExpressRequestCost(list, get, put int64) float64 {
+	return float64(list+put)/1000*ExpressPutRequestPricePer1000 + float64(get)/1000*ExpressGetRequestPricePer1000
+}
+
+// CurrencyCode is the display currency.
+func (p Pricing) CurrencyCode() string {
+	if p.Currency == "" {
+		return "USD"
+	}
+	return strings.ToUpper(p.Currency)
+}
+
+// Convert turns a USD amount into the display currency.
+func (p Pricing) Convert(usd float64) float64 {
+	if p.CurrencyCode() == "USD" && p.Rate == 0 {
+		return usd
+	}
+	return usd * p.Rate
+}
+
+// ToUSD turns an amount in the display currency back into USD.
+func (p Pricing) ToUSD(amount float64) float64 {
+	if p.CurrencyCode() == "USD" && p.Rate == 0 {
+		return amount
+	}
+	return amount / p.Rate
+}
+
+// Format renders a USD amount in the display currency, e.g. "€12.34".
+func (p Pricing) Format(usd float64, decimals int) string {
+	return FormatAmount(p.Convert(usd), p.CurrencyCode(), decimals)
+}
+
+// FormatAmount renders an amount already in currency, e.g. "$1.50" or
+// "12.00 CHF" for currencies without a well-known symbol.
+func FormatAmount(amount float64, currency string, decimals int) string {
+	switch strings.ToUpper(currency) {
+	case "", "USD":
+		return fmt.Sprintf("$%.*f", decimals, amount)
+	case "EUR":
+		return fmt.Sprintf("€%.*f", decimals, amount)
+	case "GBP":
+		return fmt.Sprintf("£%.*f", decimals, amount)
+	case "JPY":
+		return fmt.Sprintf("¥%.*f", decimals, amount)
+	}
+	return fmt.Sprintf("%.*f %s", decimals, amount, strings.ToUpper(currency))
+}
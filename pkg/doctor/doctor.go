@@ -0,0 +1,217 @@
+// Package doctor checks, before a long run, that the credentials in use may
+// call every S3 operation the run needs. Each permission is probed with a
+// request that can't change data: operations on a key that doesn't exist, a
+// conditional delete that can't match, and lifecycle writes only where there
+// is no configuration to lose.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// API is the subset of the S3 client the probes need.
+type API interface {
+	ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	HeadObject(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	GetObjectTagging(ctx context.Context, in *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error)
+	DeleteObject(ctx context.Context, in *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	CopyObject(ctx context.Context, in *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	GetBucketLifecycleConfiguration(ctx context.Context, in *s3.GetBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error)
+	DeleteBucketLifecycle(ctx context.Context, in *s3.DeleteBucketLifecycleInput, optFns ...func(*s3.Options)) (*s3.DeleteBucketLifecycleOutput, error)
+	GetBucketReplication(ctx context.Context, in *s3.GetBucketReplicationInput, optFns ...func(*s3.Options)) (*s3.GetBucketReplicationOutput, error)
+}
+
+// Options says which bucket a run will touch.
+type Options struct {
+	Bucket        string
+	Prefix        string // probe keys are created under this prefix
+	ArchiveBucket string // also check copying into this bucket; empty skips it
+	Identity      string // who the checks run as, for the banner; may be empty
+	Out           io.Writer
+}
+
+// Status is the outcome of one check.
+type Status string
+
+const (
+	Allowed Status = "allowed"
+	Denied  Status = "denied"
+	Skipped Status = "skipped" // not safely testable here
+	Failed  Status = "failed"  // the probe failed for another reason
+)
+
+// Check is one permission and what probing it showed.
+type Check struct {
+	Permission string
+	Resource   string
+	Needed     string // what a run uses it for
+	Status     Status
+	Detail     string
+}
+
+// Report is every check of one Run.
+type Report struct {
+	Checks []Check
+}
+
+// Missing returns the checks that were denied.
+func (r *Report) Missing() []Check {
+	var out []Check
+	for _, c := range r.Checks {
+		if c.Status == Denied {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// OK reports whether nothing was denied or failed.
+func (r *Report) OK() bool {
+	for _, c := range r.Checks {
+		if c.Status == Denied || c.Status == Failed {
+			return false
+		}
+	}
+	return true
+}
+
+// notThere are the error codes a permitted request on a missing key, version
+// or configuration answers with.
+var notThere = map[string]bool{
+	"NoSuchKey":                             true,
+	"NotFound":                              true,
+	"NoSuchVersion":                         true,
+	"NoSuchLifecycleConfiguration":          true,
+	"ReplicationConfigurationNotFoundError": true,
+	"PreconditionFailed":                    true,
+	"InvalidArgument":                       true, // e.g. a version ID that can't exist
+}
+
+// classify turns a probe's error into a Status. AccessDenied (or a bare 403
+// for HEAD) is the only answer that means the permission is missing.
+func classify(err error) (Status, string) {
+	if err == nil {
+		return Allowed, ""
+	}
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusForbidden {
+		return Denied, "access denied"
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch code := apiErr.ErrorCode(); {
+		case code == "AccessDenied" || code == "Forbidden" || code == "AllAccessDisabled":
+			return Denied, "access denied"
+		case notThere[code]:
+			return Allowed, ""
+		}
+	}
+	return Failed, err.Error()
+}
+
+// Run probes every permission a scan of opts.Bucket needs and prints one line
+// per check to opts.Out.
+func Run(ctx context.Context, client API, opts Options) *Report {
+	out := opts.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	bucket := aws.String(opts.Bucket)
+	probe := aws.String(fmt.Sprintf("%ss3-tidy-doctor-%d", opts.Prefix, time.Now().UnixNano()))
+	objects := fmt.Sprintf("arn:aws:s3:::%s/%s*", opts.Bucket, opts.Prefix)
+	bucketARN := "arn:aws:s3:::" + opts.Bucket
+
+	if opts.Identity != "" {
+		fmt.Fprintf(out, "🩺 Checking permissions on 's3://%s' as %s...\n", opts.Bucket, opts.Identity)
+	} else {
+		fmt.Fprintf(out, "🩺 Checking permissions on 's3://%s'...\n", opts.Bucket)
+	}
+
+	r := &Report{}
+	check := func(c Check, err error) {
+		c.Status, c.Detail = classify(err)
+		r.add(out, c)
+	}
+
+	_, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: bucket, Prefix: aws.String(opts.Prefix), MaxKeys: aws.Int32(1)})
+	check(Check{Permission: "s3:ListBucket", Resource: bucketARN, Needed: "listing objects"}, err)
+
+	canList := r.Checks[0].Status == Allowed
+
+	_, err = client.GetBucketReplication(ctx, &s3.GetBucketReplicationInput{Bucket: bucket})
+	check(Check{Permission: "s3:GetReplicationConfiguration", Resource: bucketARN, Needed: "holding back unreplicated objects"}, err)
+
+	// Without s3:ListBucket, S3 answers reads of missing keys with 403 too.
+	read := func(c Check, err error) {
+		c.Status, c.Detail = classify(err)
+		if c.Status == Denied && !canList {
+			c.Status, c.Detail = Skipped, "inconclusive without s3:ListBucket"
+		}
+		r.add(out, c)
+	}
+	_, err = client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: bucket, Key: probe})
+	read(Check{Permission: "s3:GetObject", Resource: objects, Needed: "--verify-before-delete head and archive checks"}, err)
+
+	_, err = client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{Bucket: bucket, Key: probe})
+	read(Check{Permission: "s3:GetObjectTagging", Resource: objects, Needed: "archive copies"}, err)
+
+	// If-Match on a missing key can't delete anything, not even add a marker.
+	_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: bucket, Key: probe, IfMatch: aws.String(`"s3-tidy-doctor"`)})
+	check(Check{Permission: "s3:DeleteObject", Resource: objects, Needed: "deleting stale objects"}, err)
+
+	// Deleting the null version of a missing key is a no-op.
+	_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: bucket, Key: probe, VersionId: aws.String("null")})
+	check(Check{Permission: "s3:DeleteObjectVersion", Resource: objects, Needed: "deleting object versions"}, err)
+
+	_, err = client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: bucket})
+	check(Check{Permission: "s3:GetLifecycleConfiguration", Resource: bucketARN, Needed: "reading lifecycle rules"}, err)
+	hasLifecycle := err == nil
+
+	// The only write that can't change anything is removing a configuration
+	// that doesn't exist; with rules in place, leave them alone.
+	write := Check{Permission: "s3:PutLifecycleConfiguration", Resource: bucketARN, Needed: "writing lifecycle rules"}
+	switch {
+	case hasLifecycle:
+		write.Status, write.Detail = Skipped, "the bucket has lifecycle rules, which the probe would remove"
+		r.add(out, write)
+	case r.Checks[len(r.Checks)-1].Status != Allowed:
+		write.Status, write.Detail = Skipped, "lifecycle rules can't be read, so their absence can't be confirmed"
+		r.add(out, write)
+	default:
+		_, err = client.DeleteBucketLifecycle(ctx, &s3.DeleteBucketLifecycleInput{Bucket: bucket})
+		check(write, err)
+	}
+
+	if opts.ArchiveBucket != "" {
+		// Copying a missing source needs s3:GetObject on it and s3:PutObject on
+		// the target before S3 finds there is nothing to copy.
+		_, err = client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:     aws.String(opts.ArchiveBucket),
+			Key:        probe,
+			CopySource: aws.String(opts.Bucket + "/" + aws.ToString(probe)),
+		})
+		check(Check{Permission: "s3:PutObject", Resource: fmt.Sprintf("arn:aws:s3:::%s/%s*", opts.ArchiveBucket, opts.Prefix), Needed: "archive copies"}, err)
+	}
+	return r
+}
+
+func (r *Report) add(out io.Writer, c Check) {
+	r.Checks = append(r.Checks, c)
+	icon := map[Status]string{Allowed: "✅", Denied: "❌", Skipped: "⏭️ ", Failed: "⚠️ "}[c.Status]
+	line := fmt.Sprintf("   %s %-32s %s", icon, c.Permission, c.Needed)
+	if c.Detail != "" {
+		line += " (" + c.Detail + ")"
+	}
+	fmt.Fprintln(out, line)
+}
@@ -0,0 +1,323 @@
+// Package gcs lets the scanner run against Google Cloud Storage. Client speaks
+// the subset of the S3 API the scanner uses (scanner.API) on top of the GCS
+// client library, so policies, filters, sinks and reports work unchanged.
+package gcs
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+var (
+	_ scanner.API         = (*Client)(nil)
+	_ scanner.LargeCopier = (*Client)(nil)
+)
+
+// deleteConcurrency is how many objects a DeleteObjects call removes at once;
+// GCS has no batch delete, so each key is its own request.
+const deleteConcurrency = 16
+
+// errNotImplemented answers the S3 calls GCS has no counterpart for.
+var errNotImplemented = &smithy.GenericAPIError{Code: "NotImplemented", Message: "not supported on Google Cloud Storage"}
+
+// errNoVersions answers calls naming an S3 version ID. Dropping the ID would
+// act on the current object instead.
+var errNoVersions = &smithy.GenericAPIError{Code: "NotImplemented", Message: "object versions are not supported on Google Cloud Storage"}
+
+// Client adapts a GCS client to scanner.API. Object ETags are the quoted hex
+// MD5, like single-part S3 objects, so archive verification carries over;
+// composite objects have no MD5 and get a non-comparable "<crc32c>-<n>" ETag.
+type Client struct {
+	gcs *storage.Client
+}
+
+// New connects with Application Default Credentials, or whatever opts say.
+func New(ctx context.Context, opts ...option.ClientOption) (*Client, error) {
+	c, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create GCS client: %w", err)
+	}
+	return &Client{gcs: c}, nil
+}
+
+// Close releases the underlying client.
+func (c *Client) Close() error { return c.gcs.Close() }
+
+// CopiesLargeObjects reports that CopyObject handles any size: GCS rewrites
+// large objects in several calls by itself, so no multipart copy is needed.
+func (c *Client) CopiesLargeObjects() bool { return true }
+
+func etag(a *storage.ObjectAttrs) string {
+	if len(a.MD5) > 0 {
+		return `"` + hex.EncodeToString(a.MD5) + `"`
+	}
+	return fmt.Sprintf(`"%08x-%d"`, a.CRC32C, max(a.ComponentCount, 1))
+}
+
+// notFound reports whether err means the object or bucket doesn't exist.
+func notFound(err error) bool {
+	var gerr *googleapi.Error
+	return errors.Is(err, storage.ErrObjectNotExist) || (errors.As(err, &gerr) && gerr.Code == http.StatusNotFound)
+}
+
+func preconditionFailed(err error) bool {
+	var gerr *googleapi.Error
+	return errors.As(err, &gerr) && gerr.Code == http.StatusPreconditionFailed
+}
+
+// ListObjectsV2 pages through a bucket with the GCS page token standing in
+// for the continuation token.
+func (c *Client) ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	q := &storage.Query{Prefix: aws.ToString(in.Prefix), Delimiter: aws.ToString(in.Delimiter), StartOffset: aws.ToString(in.StartAfter)}
+	size := int(aws.ToInt32(in.MaxKeys))
+	if size <= 0 {
+		size = 1000
+	}
+
+	var attrs []*storage.ObjectAttrs
+	next, err := iterator.NewPager(c.gcs.Bucket(aws.ToString(in.Bucket)).Objects(ctx, q), size, aws.ToString(in.ContinuationToken)).NextPage(&attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &s3.ListObjectsV2Output{Name: in.Bucket, Prefix: in.Prefix, Delimiter: in.Delimiter}
+	for _, a := range attrs {
+		switch {
+		case a.Prefix != "":
+			out.CommonPrefixes = append(out.CommonPrefixes, types.CommonPrefix{Prefix: aws.String(a.Prefix)})
+		case a.Name == aws.ToString(in.StartAfter):
+			// StartOffset is inclusive, StartAfter isn't.
+		default:
+			out.Contents = append(out.Contents, types.Object{
+				Key:          aws.String(a.Name),
+				Size:         aws.Int64(a.Size),
+				LastModified: aws.Time(a.Updated),
+				ETag:         aws.String(etag(a)),
+				StorageClass: types.ObjectStorageClass(a.StorageClass),
+			})
+		}
+	}
+	out.KeyCount = aws.Int32(int32(len(out.Contents) + len(out.CommonPrefixes)))
+	out.IsTruncated = aws.Bool(next != "")
+	if next != "" {
+		out.NextContinuationToken = aws.String(next)
+	}
+	return out, nil
+}
+
+// HeadObject returns an object's attributes, or a NotFound error. A version ID
+// is refused, since GCS has no S3 object versions.
+func (c *Client) HeadObject(ctx context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	if aws.ToString(in.VersionId) != "" {
+		return nil, errNoVersions
+	}
+	a, err := c.gcs.Bucket(aws.ToString(in.Bucket)).Object(aws.ToString(in.Key)).Attrs(ctx)
+	if notFound(err) {
+		return nil, &types.NotFound{Message: aws.String(err.Error())}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s3.HeadObjectOutput{
+		ContentLength:      aws.Int64(a.Size),
+		LastModified:       aws.Time(a.Updated),
+		ETag:               aws.String(etag(a)),
+		StorageClass:       types.StorageClass(a.StorageClass),
+		Metadata:           a.Metadata,
+		ContentType:        optional(a.ContentType),
+		ContentEncoding:    optional(a.ContentEncoding),
+		ContentDisposition: optional(a.ContentDisposition),
+		ContentLanguage:    optional(a.ContentLanguage),
+		CacheControl:       optional(a.CacheControl),
+	}, nil
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+// GetObject streams an object's content.
+func (c *Client) GetObject(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	r, err := c.gcs.Bucket(aws.ToString(in.Bucket)).Object(aws.ToString(in.Key)).NewReader(ctx)
+	if notFound(err) {
+		return nil, &types.NoSuchKey{Message: aws.String(err.Error())}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s3.GetObjectOutput{Body: r, ContentLength: aws.Int64(r.Attrs.Size), LastModified: aws.Time(r.Attrs.LastModified)}, nil
+}
+
+// CopyObject rewrites an object into another bucket, keeping its metadata and
+// optionally changing its storage class. GCS has no tags, so TaggingDirective
+// has nothing to copy.
+func (c *Client) CopyObject(ctx context.Context, in *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	srcBucket, srcKey, err := splitCopySource(aws.ToString(in.CopySource))
+	if err != nil {
+		return nil, err
+	}
+	src := c.gcs.Bucket(srcBucket).Object(srcKey)
+	a, err := src.Attrs(ctx)
+	if notFound(err) {
+		return nil, &types.NoSuchKey{Message: aws.String(err.Error())}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	copier := c.gcs.Bucket(aws.ToString(in.Bucket)).Object(aws.ToString(in.Key)).CopierFrom(src.If(storage.Conditions{GenerationMatch: a.Generation}))
+	copier.ContentType = a.ContentType
+	copier.ContentEncoding = a.ContentEncoding
+	copier.ContentDisposition = a.ContentDisposition
+	copier.ContentLanguage = a.ContentLanguage
+	copier.CacheControl = a.CacheControl
+	copier.Metadata = a.Metadata
+	copier.StorageClass = string(in.StorageClass)
+	dst, err := copier.Run(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &s3.CopyObjectOutput{CopyObjectResult: &types.CopyObjectResult{ETag: aws.String(etag(dst)), LastModified: aws.Time(dst.Updated)}}, nil
+}
+
+// splitCopySource undoes the "bucket/url-escaped-key" encoding of CopySource.
+func splitCopySource(s string) (string, string, error) {
+	bucket, key, ok := strings.Cut(s, "/")
+	if !ok {
+		return "", "", &smithy.GenericAPIError{Code: "InvalidArgument", Message: "Invalid copy source"}
+	}
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		if u, err := url.PathUnescape(seg); err == nil {
+			segments[i] = u
+		}
+	}
+	return bucket, strings.Join(segments, "/"), nil
+}
+
+// DeleteObject removes one key. An IfMatch ETag makes the delete conditional,
+// enforced with the object's generation so nothing written in between goes.
+// A version ID is refused rather than dropped.
+func (c *Client) DeleteObject(ctx context.Context, in *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	if err := c.delete(ctx, aws.ToString(in.Bucket), aws.ToString(in.Key), aws.ToString(in.VersionId), aws.ToString(in.IfMatch)); err != nil {
+		return nil, err
+	}
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (c *Client) delete(ctx context.Context, bucket, key, version, ifMatch string) error {
+	if version != "" {
+		return errNoVersions
+	}
+	obj := c.gcs.Bucket(bucket).Object(key)
+	if ifMatch != "" {
+		a, err := obj.Attrs(ctx)
+		if notFound(err) {
+			return &smithy.GenericAPIError{Code: "NoSuchKey", Message: "The specified key does not exist."}
+		}
+		if err != nil {
+			return err
+		}
+		if etag(a) != ifMatch {
+			return &smithy.GenericAPIError{Code: "PreconditionFailed", Message: "At least one of the pre-conditions you specified did not hold"}
+		}
+		obj = obj.If(storage.Conditions{GenerationMatch: a.Generation})
+	}
+	err := obj.Delete(ctx)
+	switch {
+	case err == nil:
+		return nil
+	case preconditionFailed(err):
+		return &smithy.GenericAPIError{Code: "PreconditionFailed", Message: err.Error()}
+	case notFound(err) && ifMatch == "":
+		// Like S3, deleting a missing key succeeds.
+		return nil
+	case notFound(err):
+		return &smithy.GenericAPIError{Code: "NoSuchKey", Message: err.Error()}
+	}
+	return err
+}
+
+// DeleteObjects deletes each key separately, a few at a time, and reports
+// per-key failures the way S3 does.
+func (c *Client) DeleteObjects(ctx context.Context, in *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		out = &s3.DeleteObjectsOutput{}
+	)
+	sem := make(chan struct{}, deleteConcurrency)
+	quiet := in.Delete != nil && aws.ToBool(in.Delete.Quiet)
+	for _, id := range in.Delete.Objects {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(id types.ObjectIdentifier) {
+			defer func() { <-sem; wg.Done() }()
+			err := c.delete(ctx, aws.ToString(in.Bucket), aws.ToString(id.Key), aws.ToString(id.VersionId), aws.ToString(id.ETag))
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				if !quiet {
+					out.Deleted = append(out.Deleted, types.DeletedObject{Key: id.Key})
+				}
+				return
+			}
+			e := types.Error{Key: id.Key, VersionId: id.VersionId, Code: aws.String("InternalError"), Message: aws.String(err.Error())}
+			var apiErr smithy.APIError
+			if errors.As(err, &apiErr) {
+				e.Code, e.Message = aws.String(apiErr.ErrorCode()), aws.String(apiErr.ErrorMessage())
+			}
+			out.Errors = append(out.Errors, e)
+		}(id)
+	}
+	wg.Wait()
+	return out, nil
+}
+
+// GetObjectTagging returns no tags; GCS objects only have metadata.
+func (c *Client) GetObjectTagging(ctx context.Context, in *s3.GetObjectTaggingInput, _ ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	return &s3.GetObjectTaggingOutput{}, nil
+}
+
+// GetBucketReplication reports no configuration: dual- and multi-region
+// buckets replicate without a per-object status to wait on.
+func (c *Client) GetBucketReplication(context.Context, *s3.GetBucketReplicationInput, ...func(*s3.Options)) (*s3.GetBucketReplicationOutput, error) {
+	return nil, &smithy.GenericAPIError{Code: "ReplicationConfigurationNotFoundError", Message: "The replication configuration was not found"}
+}
+
+// The multipart copy calls are never made, since CopiesLargeObjects is true.
+
+func (c *Client) CreateMultipartUpload(context.Context, *s3.CreateMultipartUploadInput, ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	return nil, errNotImplemented
+}
+
+func (c *Client) UploadPartCopy(context.Context, *s3.UploadPartCopyInput, ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error) {
+	return nil, errNotImplemented
+}
+
+func (c *Client) CompleteMultipartUpload(context.Context, *s3.CompleteMultipartUploadInput, ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	return nil, errNotImplemented
+}
+
+func (c *Client) AbortMultipartUpload(context.Context, *s3.AbortMultipartUploadInput, ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	return nil, errNotImplemented
+}
@@ -0,0 +1,43 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+func notImplemented(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "NotImplemented"
+}
+
+// A version ID must be refused before anything reaches the backend: dropping
+// it would act on the live object. The zero Client has no backend, so a call
+// that got that far would panic.
+func TestVersionIDsAreRefused(t *testing.T) {
+	ctx := context.Background()
+	c := &Client{}
+	b, k, v := aws.String("b"), aws.String("k"), aws.String("v1")
+
+	if _, err := c.HeadObject(ctx, &s3.HeadObjectInput{Bucket: b, Key: k, VersionId: v}); !notImplemented(err) {
+		t.Errorf("HeadObject: %v, want NotImplemented", err)
+	}
+	if _, err := c.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: b, Key: k, VersionId: v, IfMatch: aws.String(`"etag"`)}); !notImplemented(err) {
+		t.Errorf("DeleteObject: %v, want NotImplemented", err)
+	}
+	out, err := c.DeleteObjects(ctx, &s3.DeleteObjectsInput{Bucket: b, Delete: &types.Delete{Objects: []types.ObjectIdentifier{{Key: k, VersionId: v}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Deleted) != 0 || len(out.Errors) != 1 {
+		t.Fatalf("DeleteObjects: deleted %v, errors %v; want one error", out.Deleted, out.Errors)
+	}
+	if e := out.Errors[0]; aws.ToString(e.Code) != "NotImplemented" || aws.ToString(e.VersionId) != "v1" {
+		t.Errorf("DeleteObjects error = %s for version %s, want NotImplemented for v1", aws.ToString(e.Code), aws.ToString(e.VersionId))
+	}
+}
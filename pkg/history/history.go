@@ -0,0 +1,179 @@
+// Package history keeps a local SQLite database of run summaries, so repeated
+// scans of the same buckets build a record that can be trended and diffed.
+package history
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/aslinger/s3-tidy/pkg/policy"
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+
+	// Pure-Go driver, so the binary stays static and cross-compiles.
+	_ "modernc.org/sqlite"
+)
+
+// timeLayout is fixed-width, so timestamps sort correctly as text.
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+const schema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id                  INTEGER PRIMARY KEY AUTOINCREMENT,
+	policy              TEXT    NOT NULL,
+	bucket              TEXT    NOT NULL,
+	started             TEXT    NOT NULL,
+	finished            TEXT    NOT NULL,
+	dry_run             INTEGER NOT NULL,
+	report              INTEGER NOT NULL,
+	scanned             INTEGER NOT NULL,
+	stale               INTEGER NOT NULL,
+	deleted             INTEGER NOT NULL,
+	excluded            INTEGER NOT NULL,
+	retained            INTEGER NOT NULL,
+	undated             INTEGER NOT NULL,
+	kept                INTEGER NOT NULL,
+	filtered            INTEGER NOT NULL,
+	archived            INTEGER NOT NULL,
+	modified_since_scan INTEGER NOT NULL,
+	errors              INTEGER NOT NULL,
+	stale_bytes         INTEGER NOT NULL,
+	deleted_bytes       INTEGER NOT NULL,
+	estimated_savings   REAL    NOT NULL,
+	error               TEXT    NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS runs_bucket_started ON runs (bucket, started);
+
+CREATE TABLE IF NOT EXISTS prefix_stats (
+	run_id        INTEGER NOT NULL REFERENCES runs (id) ON DELETE CASCADE,
+	prefix        TEXT    NOT NULL,
+	stale         INTEGER NOT NULL,
+	stale_bytes   INTEGER NOT NULL,
+	deleted       INTEGER NOT NULL,
+	deleted_bytes INTEGER NOT NULL,
+	PRIMARY KEY (run_id, prefix)
+);
+`
+
+// Store is a history database. It is safe for use by one process at a time;
+// SQLite's locking serialises writers from others.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens (creating if needed) the database at path.
+func Open(path string) (*Store, error) {
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() + "?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open history %s: %w", path, err)
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open history %s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// PrefixStat aggregates one top-level prefix of a run.
+type PrefixStat struct {
+	Prefix       string `json:"prefix"`
+	Stale        int    `json:"stale"`
+	StaleBytes   int64  `json:"stale_bytes"`
+	Deleted      int    `json:"deleted"`
+	DeletedBytes int64  `json:"deleted_bytes"`
+}
+
+// Record stores one run with its optional prefix aggregates and returns the
+// new run ID. runErr, when set, is kept alongside the (partial) result.
+func (s *Store) Record(ctx context.Context, res *scanner.Result, runErr error, prefixes []PrefixStat) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	errText := ""
+	if runErr != nil {
+		errText = runErr.Error()
+	}
+	r, err := tx.ExecContext(ctx, `INSERT INTO runs (
+		policy, bucket, started, finished, dry_run, report,
+		scanned, stale, deleted, excluded, retained, undated, kept, filtered, archived, modified_since_scan, errors,
+		stale_bytes, deleted_bytes, estimated_savings, error
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		res.Policy, res.Bucket, res.Started.UTC().Format(timeLayout), res.Finished.UTC().Format(timeLayout), res.DryRun, res.Report,
+		res.Scanned, res.Stale, res.Deleted, res.Excluded, res.Retained, res.Undated, res.Kept, res.Filtered, res.Archived, res.ModifiedSinceScan, res.Errors,
+		res.StaleBytes, res.DeletedBytes, res.EstimatedSavings, errText,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("record run: %w", err)
+	}
+	id, err := r.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, p := range prefixes {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO prefix_stats (run_id, prefix, stale, stale_bytes, deleted, deleted_bytes) VALUES (?, ?, ?, ?, ?, ?)`,
+			id, p.Prefix, p.Stale, p.StaleBytes, p.Deleted, p.DeletedBytes); err != nil {
+			return 0, fmt.Errorf("record prefix %q: %w", p.Prefix, err)
+		}
+	}
+	return id, tx.Commit()
+}
+
+// PrefixStats is a scanner.Sink that totals a run's findings per top-level
+// prefix. It holds one entry per prefix, never per object.
+type PrefixStats struct {
+	byPrefix map[string]*PrefixStat
+}
+
+// NewPrefixStats returns an empty aggregate for one run.
+func NewPrefixStats() *PrefixStats {
+	return &PrefixStats{byPrefix: make(map[string]*PrefixStat)}
+}
+
+// Write implements scanner.Sink.
+func (p *PrefixStats) Write(f scanner.Finding) error {
+	switch f.Outcome {
+	case scanner.OutcomeUnreplicated, scanner.OutcomeEncrypted, scanner.OutcomeMissing, scanner.OutcomeDeleteMarker:
+		// Findings the run kept, couldn't find or only flagged aren't stale;
+		// counting them would drift from the run's own stale totals.
+		return nil
+	}
+	prefix := policy.GroupKey(f.Key, 1)
+	st, ok := p.byPrefix[prefix]
+	if !ok {
+		st = &PrefixStat{Prefix: prefix}
+		p.byPrefix[prefix] = st
+	}
+	st.Stale++
+	st.StaleBytes += f.Size
+	if f.Outcome == scanner.OutcomeDeleted {
+		st.Deleted++
+		st.DeletedBytes += f.Size
+	}
+	return nil
+}
+
+// Flush implements scanner.Sink.
+func (p *PrefixStats) Flush() error { return nil }
+
+// Stats returns the aggregates sorted by prefix.
+func (p *PrefixStats) Stats() []PrefixStat {
+	stats := make([]PrefixStat, 0, len(p.byPrefix))
+	for _, st := range p.byPrefix {
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Prefix < stats[j].Prefix })
+	return stats
+}
@@ -0,0 +1,251 @@
+package history_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aslinger/s3-tidy/pkg/history"
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/aslinger/s3-tidy/pkg/scanner/s3fake"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+var now = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func openStore(t *testing.T) *history.Store {
+	t.Helper()
+	store, err := history.Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// old stores a 100-day-old object of size bytes in bucket "b".
+func old(c *s3fake.Client, key string, size int64, replication types.ReplicationStatus) {
+	c.Put("b", s3fake.Object{Key: key, Size: size, LastModified: now.AddDate(0, 0, -100), ReplicationStatus: replication})
+}
+
+// scan dry-runs bucket "b" of c with a PrefixStats sink, records the run in
+// store and returns it with the prefix totals read back.
+func scan(t *testing.T, store *history.Store, c *s3fake.Client, opts scanner.Options) (*scanner.Result, []history.PrefixStat) {
+	t.Helper()
+	stats := history.NewPrefixStats()
+	opts.Bucket, opts.Out, opts.DryRun = "b", io.Discard, true
+	opts.Cutoff = now.AddDate(0, 0, -30)
+	opts.Sinks = append(opts.Sinks, stats)
+	res, err := scanner.New(c).Run(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	id, err := store.Record(context.Background(), res, nil, stats.Stats())
+	if err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	prefixes, err := store.Prefixes(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Prefixes: %v", err)
+	}
+	return res, prefixes
+}
+
+// checkSums fails unless the prefix totals add up to the run's.
+func checkSums(t *testing.T, res *scanner.Result, prefixes []history.PrefixStat) {
+	t.Helper()
+	var stale int
+	var bytes int64
+	for _, p := range prefixes {
+		stale += p.Stale
+		bytes += p.StaleBytes
+	}
+	if stale != res.Stale || bytes != res.StaleBytes {
+		t.Errorf("prefixes total %d stale (%d bytes), run has %d (%d bytes): %+v", stale, bytes, res.Stale, res.StaleBytes, prefixes)
+	}
+}
+
+func TestPrefixStatsSkipUnreplicated(t *testing.T) {
+	c := &s3fake.Client{}
+	c.AddBucket("b")
+	c.SetReplication("b", true)
+	old(c, "logs/a.log", 100, types.ReplicationStatusCompleted)
+	old(c, "logs/b.log", 200, types.ReplicationStatusPending)
+	old(c, "tmp/c", 300, types.ReplicationStatusFailed)
+	old(c, "tmp/d", 400, types.ReplicationStatusCompleted)
+
+	res, prefixes := scan(t, openStore(t), c, scanner.Options{})
+	if res.Unreplicated != 2 || res.Stale != 2 {
+		t.Fatalf("unreplicated %d, stale %d; want 2, 2", res.Unreplicated, res.Stale)
+	}
+	checkSums(t, res, prefixes)
+}
+
+func TestPrefixStatsSkipDeleteMarkers(t *testing.T) {
+	c := &s3fake.Client{}
+	c.AddBucket("b")
+	old(c, "logs/a.log", 100, "")
+	old(c, "logs/gone.log", 5000, "")
+	c.PutDeleteMarker("b", "logs/gone.log", now.AddDate(0, 0, -60))
+
+	res, prefixes := scan(t, openStore(t), c, scanner.Options{Versions: true})
+	if res.DeleteMarkers != 1 || res.DeleteMarkerBytes != 5000 || res.Stale != 1 {
+		t.Fatalf("delete markers %d (%d bytes), stale %d; want 1 (5000 bytes), 1", res.DeleteMarkers, res.DeleteMarkerBytes, res.Stale)
+	}
+	checkSums(t, res, prefixes)
+}
+
+// kmsDenied fails HEADs of keys under secure/ the way S3 does when the
+// caller may not use the object's KMS key.
+func kmsDenied(key string) error {
+	if strings.HasPrefix(key, "secure/") {
+		return &smithy.GenericAPIError{Code: "KMS.DisabledException", Message: "The KMS key is disabled"}
+	}
+	return nil
+}
+
+func TestPrefixStatsSkipEncrypted(t *testing.T) {
+	c := &s3fake.Client{FailHead: kmsDenied}
+	c.AddBucket("b")
+	c.SetReplication("b", true)
+	old(c, "logs/a.log", 100, types.ReplicationStatusCompleted)
+	old(c, "secure/b.bin", 2000, types.ReplicationStatusCompleted)
+	store := openStore(t)
+
+	t.Run("scan", func(t *testing.T) {
+		res, prefixes := scan(t, store, c, scanner.Options{})
+		if res.Encrypted != 1 || res.Stale != 1 {
+			t.Fatalf("encrypted %d, stale %d; want 1, 1", res.Encrypted, res.Stale)
+		}
+		checkSums(t, res, prefixes)
+	})
+
+	t.Run("key list", func(t *testing.T) {
+		stats := history.NewPrefixStats()
+		opts := scanner.Options{Bucket: "b", Out: io.Discard, DryRun: true, Sinks: []scanner.Sink{stats}}
+		res, err := scanner.New(c).DeleteKeys(context.Background(), opts, strings.NewReader("logs/a.log\nsecure/b.bin\nlogs/missing.log\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Encrypted != 1 || res.Missing != 1 || res.Stale != 1 {
+			t.Fatalf("encrypted %d, missing %d, stale %d; want 1, 1, 1", res.Encrypted, res.Missing, res.Stale)
+		}
+		checkSums(t, res, stats.Stats())
+	})
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store := openStore(t)
+	started := time.Date(2025, 3, 1, 9, 30, 0, 123456789, time.UTC)
+	res := &scanner.Result{
+		Policy: "logs", Bucket: "b", Started: started, Finished: started.Add(time.Minute),
+		Scanned: 10, Stale: 4, Deleted: 3, Excluded: 1, ModifiedSinceScan: 1, Errors: 1,
+		StaleBytes: 400, DeletedBytes: 300, EstimatedSavings: 0.25,
+	}
+	stats := history.NewPrefixStats()
+	for _, f := range []scanner.Finding{
+		{Key: "logs/a", Size: 100, Outcome: scanner.OutcomeDeleted},
+		{Key: "logs/b", Size: 100, Outcome: scanner.OutcomeDeleted},
+		{Key: "root", Size: 100, Outcome: scanner.OutcomeDeleted},
+		{Key: "tmp/c", Size: 100, Outcome: scanner.OutcomeSkipped},
+	} {
+		if err := stats.Write(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	id, err := store.Record(ctx, res, errors.New("partial listing"), stats.Stats())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.Get(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := history.Run{ID: id, Result: *res, Error: "partial listing"}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("Get = %+v, want %+v", *got, want)
+	}
+
+	prefixes, err := store.Prefixes(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPrefixes := []history.PrefixStat{
+		{Prefix: "", Stale: 1, StaleBytes: 100, Deleted: 1, DeletedBytes: 100},
+		{Prefix: "logs/", Stale: 2, StaleBytes: 200, Deleted: 2, DeletedBytes: 200},
+		{Prefix: "tmp/", Stale: 1, StaleBytes: 100},
+	}
+	if !reflect.DeepEqual(prefixes, wantPrefixes) {
+		t.Errorf("Prefixes = %+v, want %+v", prefixes, wantPrefixes)
+	}
+	checkSums(t, res, prefixes)
+
+	if _, err := store.Get(ctx, id+1); !errors.Is(err, history.ErrNotFound) {
+		t.Errorf("Get of an unknown run: %v, want ErrNotFound", err)
+	}
+}
+
+func TestRuns(t *testing.T) {
+	ctx := context.Background()
+	store := openStore(t)
+	for i, r := range []struct{ policy, bucket string }{{"a", "b1"}, {"a", "b2"}, {"c", "b1"}} {
+		started := now.Add(time.Duration(i) * time.Hour)
+		if _, err := store.Record(ctx, &scanner.Result{Policy: r.policy, Bucket: r.bucket, Started: started, Finished: started}, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		q    history.Query
+		want []string // policy/bucket, newest first
+	}{
+		{history.Query{}, []string{"c/b1", "a/b2", "a/b1"}},
+		{history.Query{Bucket: "b1"}, []string{"c/b1", "a/b1"}},
+		{history.Query{Policy: "a"}, []string{"a/b2", "a/b1"}},
+		{history.Query{Limit: 1}, []string{"c/b1"}},
+	} {
+		runs, err := store.Runs(ctx, tc.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range runs {
+			got = append(got, r.Policy+"/"+r.Bucket)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Runs(%+v) = %v, want %v", tc.q, got, tc.want)
+		}
+	}
+}
+
+func TestComparePrefixes(t *testing.T) {
+	from := []history.PrefixStat{
+		{Prefix: "gone/", Stale: 1, StaleBytes: 50},
+		{Prefix: "logs/", Stale: 2, StaleBytes: 200},
+		{Prefix: "same/", Stale: 1, StaleBytes: 10},
+	}
+	to := []history.PrefixStat{
+		{Prefix: "logs/", Stale: 5, StaleBytes: 500},
+		{Prefix: "new/", Stale: 1, StaleBytes: 100},
+		{Prefix: "same/", Stale: 1, StaleBytes: 10},
+	}
+	var got []string
+	var deltas []int64
+	for _, c := range history.ComparePrefixes(from, to) {
+		got = append(got, c.Prefix)
+		deltas = append(deltas, c.StaleBytesDelta())
+	}
+	if want := []string{"logs/", "new/", "gone/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("changed prefixes = %v, want %v", got, want)
+	}
+	if want := []int64{300, 100, -50}; !reflect.DeepEqual(deltas, want) {
+		t.Errorf("deltas = %v, want %v", deltas, want)
+	}
+}
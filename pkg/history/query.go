@@ -0,0 +1,166 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+)
+
+// ErrNotFound is returned for a run ID the database doesn't hold.
+var ErrNotFound = errors.New("run not found")
+
+// Run is one recorded run.
+type Run struct {
+	ID int64 `json:"id"`
+	scanner.Result
+	Error string `json:"error,omitempty"`
+}
+
+// Query selects runs; empty fields match everything.
+type Query struct {
+	Bucket string
+	Policy string
+	Limit  int // 0 means no limit
+}
+
+const runColumns = `id, policy, bucket, started, finished, dry_run, report,
+	scanned, stale, deleted, excluded, retained, undated, kept, filtered, archived, modified_since_scan, errors,
+	stale_bytes, deleted_bytes, estimated_savings, error`
+
+func scanRun(row interface{ Scan(...any) error }) (Run, error) {
+	var r Run
+	var started, finished string
+	err := row.Scan(&r.ID, &r.Policy, &r.Bucket, &started, &finished, &r.DryRun, &r.Report,
+		&r.Scanned, &r.Stale, &r.Deleted, &r.Excluded, &r.Retained, &r.Undated, &r.Kept, &r.Filtered, &r.Archived, &r.ModifiedSinceScan, &r.Errors,
+		&r.StaleBytes, &r.DeletedBytes, &r.EstimatedSavings, &r.Error)
+	if err != nil {
+		return Run{}, err
+	}
+	r.Started = parseTime(started)
+	r.Finished = parseTime(finished)
+	return r, nil
+}
+
+func parseTime(s string) time.Time {
+	t, _ := time.Parse(timeLayout, s)
+	return t
+}
+
+// Runs returns the matching runs, newest first.
+func (s *Store) Runs(ctx context.Context, q Query) ([]Run, error) {
+	query := `SELECT ` + runColumns + ` FROM runs WHERE (? = '' OR bucket = ?) AND (? = '' OR policy = ?) ORDER BY started DESC, id DESC`
+	args := []any{q.Bucket, q.Bucket, q.Policy, q.Policy}
+	if q.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, q.Limit)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		r, err := scanRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list runs: %w", err)
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// Get returns one run by ID.
+func (s *Store) Get(ctx context.Context, id int64) (*Run, error) {
+	r, err := scanRun(s.db.QueryRowContext(ctx, `SELECT `+runColumns+` FROM runs WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("run %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("run %d: %w", id, err)
+	}
+	return &r, nil
+}
+
+// Prefixes returns the per-prefix totals recorded with a run, sorted by
+// prefix. Runs recorded without --history-prefixes have none.
+func (s *Store) Prefixes(ctx context.Context, runID int64) ([]PrefixStat, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT prefix, stale, stale_bytes, deleted, deleted_bytes FROM prefix_stats WHERE run_id = ? ORDER BY prefix`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("run %d prefixes: %w", runID, err)
+	}
+	defer rows.Close()
+
+	var stats []PrefixStat
+	for rows.Next() {
+		var p PrefixStat
+		if err := rows.Scan(&p.Prefix, &p.Stale, &p.StaleBytes, &p.Deleted, &p.DeletedBytes); err != nil {
+			return nil, fmt.Errorf("run %d prefixes: %w", runID, err)
+		}
+		stats = append(stats, p)
+	}
+	return stats, rows.Err()
+}
+
+// PrefixChange pairs one prefix's totals in two runs. A prefix missing from a
+// run has zero totals there.
+type PrefixChange struct {
+	Prefix string     `json:"prefix"`
+	From   PrefixStat `json:"from"`
+	To     PrefixStat `json:"to"`
+}
+
+// StaleBytesDelta is how much the prefix's stale storage grew (or, negative,
+// shrank) between the runs.
+func (c PrefixChange) StaleBytesDelta() int64 {
+	return c.To.StaleBytes - c.From.StaleBytes
+}
+
+// ComparePrefixes joins two runs' prefix totals, largest change in stale
+// bytes first. Prefixes whose stale totals didn't change are left out.
+func ComparePrefixes(from, to []PrefixStat) []PrefixChange {
+	byPrefix := make(map[string]*PrefixChange)
+	get := func(prefix string) *PrefixChange {
+		c, ok := byPrefix[prefix]
+		if !ok {
+			c = &PrefixChange{Prefix: prefix}
+			byPrefix[prefix] = c
+		}
+		return c
+	}
+	for _, p := range from {
+		get(p.Prefix).From = p
+	}
+	for _, p := range to {
+		get(p.Prefix).To = p
+	}
+
+	var changes []PrefixChange
+	for _, c := range byPrefix {
+		if c.From.Stale == c.To.Stale && c.From.StaleBytes == c.To.StaleBytes {
+			continue
+		}
+		changes = append(changes, *c)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		di, dj := abs(changes[i].StaleBytesDelta()), abs(changes[j].StaleBytesDelta())
+		if di != dj {
+			return di > dj
+		}
+		return changes[i].Prefix < changes[j].Prefix
+	})
+	return changes
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
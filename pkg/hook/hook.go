@@ -0,0 +1,118 @@
+// Package hook runs operator-supplied commands or HTTP endpoints around
+// destructive steps, handing them a JSON manifest of what is happening.
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/aslinger/s3-tidy/pkg/notify"
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+)
+
+// Events passed to hooks in S3TIDY_HOOK_EVENT (commands) or the
+// X-S3Tidy-Event header (HTTP).
+const (
+	EventPreDelete = "pre-delete"
+	EventPostRun   = "post-run"
+)
+
+// DefaultTimeout bounds a hook that doesn't finish on its own.
+const DefaultTimeout = 2 * time.Minute
+
+// Hook is a shell command, run with the payload on stdin, or an http(s) URL
+// the payload is POSTed to. A non-zero exit or non-2xx response is an error.
+type Hook struct {
+	target  string
+	timeout time.Duration
+	client  *http.Client
+}
+
+// New returns a hook for target; timeout <= 0 means DefaultTimeout.
+func New(target string, timeout time.Duration) *Hook {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Hook{target: target, timeout: timeout, client: &http.Client{}}
+}
+
+func (h *Hook) isHTTP() bool {
+	return strings.HasPrefix(h.target, "http://") || strings.HasPrefix(h.target, "https://")
+}
+
+// Run delivers payload as JSON for event.
+func (h *Hook) Run(ctx context.Context, event string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	if h.isHTTP() {
+		return h.post(ctx, event, body)
+	}
+	return h.exec(ctx, event, body)
+}
+
+func (h *Hook) exec(ctx context.Context, event string, body []byte) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.target)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(), "S3TIDY_HOOK_EVENT="+event)
+	// Hook output is diagnostics, so it must not mix into --summary-only stdout.
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s hook %q: %w", event, h.target, err)
+	}
+	return nil
+}
+
+func (h *Hook) post(ctx context.Context, event string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-S3Tidy-Event", event)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s hook: %w", event, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s hook returned %s: %s", event, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// PreDelete runs a hook with the manifest of every deletion batch before it is
+// sent. A failing hook keeps that batch from being deleted.
+type PreDelete struct{ *Hook }
+
+// BeforeBatch implements scanner.PreDeleteHook.
+func (p PreDelete) BeforeBatch(ctx context.Context, m scanner.BatchManifest) error {
+	return p.Run(ctx, EventPreDelete, m)
+}
+
+// PostRun runs a hook with the run summary once a run completes. It is a
+// notify.Notifier, so failures are logged like any other notification.
+type PostRun struct{ *Hook }
+
+// Name implements notify.Notifier.
+func (p PostRun) Name() string { return "Post-run hook" }
+
+// Notify implements notify.Notifier.
+func (p PostRun) Notify(ctx context.Context, res *scanner.Result) error {
+	return p.Run(ctx, EventPostRun, notify.NewRunSummaryMessage(res))
+}
@@ -0,0 +1,281 @@
+// Package iampolicy writes the least-privilege IAM policy a mode of s3-tidy
+// needs, so the role it runs as can be reviewed against an exact list.
+package iampolicy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+)
+
+// Action is a mode of operation a policy is generated for.
+type Action string
+
+const (
+	// ActionReport lists objects and reads nothing but bucket configuration
+	// (scan --report, report); on a replicating bucket it also HEADs stale
+	// objects.
+	ActionReport Action = "report"
+	// ActionDelete also deletes, re-checks objects before deleting them and,
+	// with an archive bucket, copies them there first.
+	ActionDelete Action = "delete"
+	// ActionLifecycle reads and writes the bucket's lifecycle rules.
+	ActionLifecycle Action = "lifecycle"
+	// ActionTag tags stale objects for review instead of deleting them
+	// (scan --action tag).
+	ActionTag Action = "tag"
+	// ActionDeleteTagged is ActionDelete that also reads object tags, to
+	// delete only objects whose expiry tag has passed.
+	ActionDeleteTagged Action = "delete-tagged"
+)
+
+// Actions are the valid actions, for flag help and errors.
+var Actions = []Action{ActionReport, ActionDelete, ActionLifecycle, ActionTag, ActionDeleteTagged}
+
+// Options parameterize a policy.
+type Options struct {
+	Bucket        string
+	Prefix        string // limit object permissions (not listing) to keys under it
+	ArchiveBucket string // with ActionDelete, also allow copying into it
+	Versions      bool   // also allow listing object versions (scan --versions)
+	// VersionIDs also allows reading and deleting given object versions, for
+	// key lists (delete --keys-file) and plans that carry version IDs.
+	VersionIDs bool
+	LogBucket  string  // also allow reading server access logs or CloudTrail logs from it
+	LogPrefix  string  // limit LogBucket reads to keys under it
+	Athena     *Athena // also allow querying access logs through Athena
+}
+
+// Athena is the access log query a scan runs (--athena-table). Athena reads
+// the table's data with the caller's credentials, so the table's location
+// goes in Options.LogBucket.
+type Athena struct {
+	Table          string // database.table
+	Workgroup      string // empty means primary
+	OutputLocation string // s3://bucket/prefix/ receiving query results
+}
+
+// Document is an IAM policy document.
+type Document struct {
+	Version   string      `json:"Version"`
+	Statement []Statement `json:"Statement"`
+}
+
+// Statement is one statement of a Document.
+type Statement struct {
+	Sid       string                    `json:"Sid"`
+	Effect    string                    `json:"Effect"`
+	Action    []string                  `json:"Action"`
+	Resource  []string                  `json:"Resource"`
+	Condition map[string]map[string]any `json:"Condition,omitempty"`
+}
+
+// ParseAction validates an --action value.
+func ParseAction(s string) (Action, error) {
+	for _, a := range Actions {
+		if string(a) == s {
+			return a, nil
+		}
+	}
+	names := make([]string, len(Actions))
+	for i, a := range Actions {
+		names[i] = string(a)
+	}
+	return "", fmt.Errorf("unknown action %q (use %s)", s, strings.Join(names, ", "))
+}
+
+// For returns the policy a needs on opts.Bucket.
+func For(a Action, opts Options) (Document, error) {
+	if opts.Bucket == "" {
+		return Document{}, fmt.Errorf("a bucket is required")
+	}
+	if opts.ArchiveBucket != "" && a != ActionDelete && a != ActionDeleteTagged {
+		return Document{}, fmt.Errorf("an archive bucket only applies to the %s and %s actions", ActionDelete, ActionDeleteTagged)
+	}
+	if opts.VersionIDs && a != ActionDelete && a != ActionDeleteTagged {
+		return Document{}, fmt.Errorf("version IDs only apply to the %s and %s actions", ActionDelete, ActionDeleteTagged)
+	}
+	reads, err := readStatements(opts)
+	if err != nil {
+		return Document{}, err
+	}
+	if len(reads) > 0 && a == ActionLifecycle {
+		return Document{}, fmt.Errorf("access logs, Athena and CloudTrail don't apply to the %s action", ActionLifecycle)
+	}
+	if scanner.IsDirectoryBucket(opts.Bucket) {
+		if opts.Versions || opts.VersionIDs {
+			return Document{}, fmt.Errorf("directory buckets have no object versions")
+		}
+		doc, err := directory(a, opts)
+		if err != nil {
+			return Document{}, err
+		}
+		doc.Statement = append(doc.Statement, reads...)
+		return doc, nil
+	}
+	bucket := "arn:aws:s3:::" + opts.Bucket
+	objects := bucket + "/" + opts.Prefix + "*"
+
+	// Not narrowed by an s3:prefix condition: scans list the whole bucket,
+	// starting with a delimited listing of its root. Every scan also checks
+	// whether the bucket replicates.
+	list := Statement{Sid: "ListBucket", Effect: "Allow", Action: []string{"s3:ListBucket", "s3:GetReplicationConfiguration"}, Resource: []string{bucket}}
+	if opts.Versions && a != ActionLifecycle {
+		list.Action = append(list.Action, "s3:ListBucketVersions")
+	}
+
+	doc := Document{Version: "2012-10-17"}
+	switch a {
+	case ActionReport:
+		doc.Statement = []Statement{list, {
+			Sid:      "ReplicationStatus",
+			Effect:   "Allow",
+			Action:   []string{"s3:GetObject"},
+			Resource: []string{objects},
+		}}
+	case ActionTag:
+		doc.Statement = []Statement{list, {
+			Sid:    "TagStaleObjects",
+			Effect: "Allow",
+			// Existing tags are read and kept; GetObject is replication status.
+			Action:   []string{"s3:GetObjectTagging", "s3:PutObjectTagging", "s3:GetObject"},
+			Resource: []string{objects},
+		}}
+	case ActionDelete, ActionDeleteTagged:
+		doc.Statement = []Statement{
+			list,
+			{
+				Sid:    "DeleteStaleObjects",
+				Effect: "Allow",
+				// GetObject covers HeadObject: --verify-before-delete head and
+				// replication status.
+				Action:   []string{"s3:DeleteObject", "s3:GetObject"},
+				Resource: []string{objects},
+			},
+		}
+		if opts.ArchiveBucket != "" || a == ActionDeleteTagged {
+			doc.Statement[1].Action = append(doc.Statement[1].Action, "s3:GetObjectTagging")
+		}
+		if opts.VersionIDs {
+			// GetObjectVersion covers re-reading a listed version.
+			doc.Statement[1].Action = append(doc.Statement[1].Action, "s3:DeleteObjectVersion", "s3:GetObjectVersion")
+		}
+		if opts.ArchiveBucket != "" {
+			doc.Statement = append(doc.Statement, Statement{
+				Sid:    "ArchiveCopies",
+				Effect: "Allow",
+				// GetObject verifies the copy; tags are copied along.
+				Action:   []string{"s3:PutObject", "s3:PutObjectTagging", "s3:GetObject", "s3:AbortMultipartUpload"},
+				Resource: []string{"arn:aws:s3:::" + opts.ArchiveBucket + "/" + opts.Prefix + "*"},
+			})
+		}
+	case ActionLifecycle:
+		doc.Statement = []Statement{{
+			Sid:      "LifecycleRules",
+			Effect:   "Allow",
+			Action:   []string{"s3:GetLifecycleConfiguration", "s3:PutLifecycleConfiguration"},
+			Resource: []string{bucket},
+		}}
+	default:
+		return Document{}, fmt.Errorf("unknown action %q", a)
+	}
+	doc.Statement = append(doc.Statement, reads...)
+	return doc, nil
+}
+
+// readStatements allow reading the log bucket and running the Athena query
+// of opts, if any.
+func readStatements(opts Options) ([]Statement, error) {
+	var st []Statement
+	if opts.LogBucket != "" {
+		list := Statement{Sid: "ListLogs", Effect: "Allow", Action: []string{"s3:ListBucket"}, Resource: []string{"arn:aws:s3:::" + opts.LogBucket}}
+		if opts.LogPrefix != "" {
+			list.Condition = map[string]map[string]any{"StringLike": {"s3:prefix": opts.LogPrefix + "*"}}
+		}
+		st = append(st, list, Statement{
+			Sid:      "ReadLogs",
+			Effect:   "Allow",
+			Action:   []string{"s3:GetObject"},
+			Resource: []string{"arn:aws:s3:::" + opts.LogBucket + "/" + opts.LogPrefix + "*"},
+		})
+	}
+	if opts.Athena == nil {
+		return st, nil
+	}
+	db, table, ok := strings.Cut(opts.Athena.Table, ".")
+	if !ok || db == "" || table == "" {
+		return nil, fmt.Errorf("Athena table %q must be database.table", opts.Athena.Table)
+	}
+	// The workgroup's default result location isn't known here.
+	out, ok := strings.CutPrefix(opts.Athena.OutputLocation, "s3://")
+	if !ok || out == "" {
+		return nil, fmt.Errorf("an Athena policy needs the s3:// location query results are written to")
+	}
+	outBucket, outPrefix, _ := strings.Cut(out, "/")
+	workgroup := opts.Athena.Workgroup
+	if workgroup == "" {
+		workgroup = "primary"
+	}
+	return append(st,
+		Statement{
+			Sid:      "AthenaQuery",
+			Effect:   "Allow",
+			Action:   []string{"athena:StartQueryExecution", "athena:GetQueryExecution", "athena:GetQueryResults"},
+			Resource: []string{"arn:aws:athena:*:*:workgroup/" + workgroup},
+		},
+		Statement{
+			Sid:      "AthenaTable",
+			Effect:   "Allow",
+			Action:   []string{"glue:GetDatabase", "glue:GetTable", "glue:GetPartitions"},
+			Resource: []string{"arn:aws:glue:*:*:catalog", "arn:aws:glue:*:*:database/" + db, "arn:aws:glue:*:*:table/" + db + "/" + table},
+		},
+		Statement{
+			Sid:      "AthenaResultsBucket",
+			Effect:   "Allow",
+			Action:   []string{"s3:GetBucketLocation", "s3:ListBucket"},
+			Resource: []string{"arn:aws:s3:::" + outBucket},
+		},
+		Statement{
+			Sid:      "AthenaResults",
+			Effect:   "Allow",
+			Action:   []string{"s3:PutObject", "s3:GetObject", "s3:AbortMultipartUpload"},
+			Resource: []string{"arn:aws:s3:::" + outBucket + "/" + outPrefix + "*"},
+		},
+	), nil
+}
+
+// directory is For on a directory bucket, whose object and listing calls are
+// all authorized by the session s3express:CreateSession hands out. Sessions
+// can't be narrowed to a prefix.
+func directory(a Action, opts Options) (Document, error) {
+	if opts.ArchiveBucket != "" {
+		return Document{}, fmt.Errorf("archive policies aren't generated for directory buckets")
+	}
+	bucket := "arn:aws:s3express:*:*:bucket/" + opts.Bucket
+	doc := Document{Version: "2012-10-17"}
+	switch a {
+	case ActionReport:
+		doc.Statement = []Statement{{
+			Sid: "ReadOnlySession", Effect: "Allow", Action: []string{"s3express:CreateSession"}, Resource: []string{bucket},
+			Condition: map[string]map[string]any{"StringEquals": {"s3express:SessionMode": "ReadOnly"}},
+		}}
+	case ActionDelete:
+		doc.Statement = []Statement{{Sid: "Session", Effect: "Allow", Action: []string{"s3express:CreateSession"}, Resource: []string{bucket}}}
+	case ActionTag, ActionDeleteTagged:
+		return Document{}, fmt.Errorf("directory buckets don't support object tags")
+	case ActionLifecycle:
+		doc.Statement = []Statement{{
+			Sid: "LifecycleRules", Effect: "Allow", Action: []string{"s3express:GetLifecycleConfiguration", "s3express:PutLifecycleConfiguration"}, Resource: []string{bucket},
+		}}
+	default:
+		return Document{}, fmt.Errorf("unknown action %q", a)
+	}
+	return doc, nil
+}
+
+// JSON renders doc indented, ready to paste into a role.
+func (d Document) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
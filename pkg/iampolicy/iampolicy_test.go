@@ -0,0 +1,66 @@
+package iampolicy
+
+import (
+	"slices"
+	"testing"
+)
+
+// actions returns the actions of the statement with sid, or nil.
+func actions(doc Document, sid string) []string {
+	for _, st := range doc.Statement {
+		if st.Sid == sid {
+			return st.Action
+		}
+	}
+	return nil
+}
+
+func TestVersionIDs(t *testing.T) {
+	doc, err := For(ActionDelete, Options{Bucket: "b", VersionIDs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"s3:DeleteObjectVersion", "s3:GetObjectVersion"} {
+		if !slices.Contains(actions(doc, "DeleteStaleObjects"), want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+	if _, err := For(ActionReport, Options{Bucket: "b", VersionIDs: true}); err == nil {
+		t.Error("version IDs accepted for report")
+	}
+}
+
+func TestReadSources(t *testing.T) {
+	doc, err := For(ActionReport, Options{
+		Bucket:    "b",
+		LogBucket: "logs",
+		LogPrefix: "b/",
+		Athena:    &Athena{Table: "db.access", OutputLocation: "s3://results/tidy/"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for sid, want := range map[string]string{
+		"ListLogs":      "s3:ListBucket",
+		"ReadLogs":      "s3:GetObject",
+		"AthenaQuery":   "athena:StartQueryExecution",
+		"AthenaTable":   "glue:GetTable",
+		"AthenaResults": "s3:PutObject",
+	} {
+		if !slices.Contains(actions(doc, sid), want) {
+			t.Errorf("statement %s lacks %s", sid, want)
+		}
+	}
+
+	for name, opts := range map[string]Options{
+		"table without database": {Bucket: "b", Athena: &Athena{Table: "access", OutputLocation: "s3://results/"}},
+		"no output location":     {Bucket: "b", Athena: &Athena{Table: "db.access"}},
+	} {
+		if _, err := For(ActionReport, opts); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+	if _, err := For(ActionLifecycle, Options{Bucket: "b", LogBucket: "logs"}); err == nil {
+		t.Error("log bucket accepted for lifecycle")
+	}
+}
@@ -0,0 +1,54 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// CloudWatchPublisher pushes each run's totals as custom metrics, dimensioned
+// by bucket and policy, so storage-waste trends can be dashboarded directly.
+type CloudWatchPublisher struct {
+	client    *cloudwatch.Client
+	namespace string
+}
+
+// NewCloudWatchPublisher publishes into namespace using cfg's credentials.
+func NewCloudWatchPublisher(cfg aws.Config, namespace string) *CloudWatchPublisher {
+	return &CloudWatchPublisher{client: cloudwatch.NewFromConfig(cfg), namespace: namespace}
+}
+
+func (p *CloudWatchPublisher) Name() string { return "CloudWatch" }
+
+func (p *CloudWatchPublisher) Notify(ctx context.Context, res *scanner.Result) error {
+	dims := []types.Dimension{
+		{Name: aws.String("Bucket"), Value: aws.String(res.Bucket)},
+		{Name: aws.String("Policy"), Value: aws.String(res.Policy)},
+	}
+	datum := func(name string, value float64, unit types.StandardUnit) types.MetricDatum {
+		return types.MetricDatum{
+			MetricName: aws.String(name),
+			Dimensions: dims,
+			Timestamp:  aws.Time(res.Finished),
+			Value:      aws.Float64(value),
+			Unit:       unit,
+		}
+	}
+
+	_, err := p.client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+		Namespace: aws.String(p.namespace),
+		MetricData: []types.MetricDatum{
+			datum("ObjectsScanned", float64(res.Scanned), types.StandardUnitCount),
+			datum("StaleObjects", float64(res.Stale), types.StandardUnitCount),
+			datum("ReclaimableBytes", float64(res.StaleBytes), types.StandardUnitBytes),
+			datum("DeletedObjects", float64(res.Deleted), types.StandardUnitCount),
+			datum("DeletedBytes", float64(res.DeletedBytes), types.StandardUnitBytes),
+			datum("EstimatedMonthlySavings", res.EstimatedSavings, types.StandardUnitNone),
+			datum("Errors", float64(res.Errors), types.StandardUnitCount),
+		},
+	})
+	return err
+}
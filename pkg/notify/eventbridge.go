@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+)
+
+const (
+	eventSource           = "s3-tidy"
+	eventTypeBatch        = "S3 Tidy Deletion Batch"
+	eventTypeRunCompleted = "S3 Tidy Run Completed"
+)
+
+// EventBridgeNotifier emits custom events on a bus: one per deletion batch and
+// one when the run completes.
+type EventBridgeNotifier struct {
+	client *eventbridge.Client
+	bus    string
+}
+
+// NewEventBridgeNotifier puts events on bus using cfg's credentials.
+func NewEventBridgeNotifier(cfg aws.Config, bus string) *EventBridgeNotifier {
+	return &EventBridgeNotifier{client: eventbridge.NewFromConfig(cfg), bus: bus}
+}
+
+func (n *EventBridgeNotifier) Name() string { return "EventBridge" }
+
+type batchEventDetail struct {
+	Bucket string `json:"bucket"`
+	Policy string `json:"policy"`
+	scanner.DeletionBatch
+}
+
+func (n *EventBridgeNotifier) NotifyBatch(ctx context.Context, res *scanner.Result, b scanner.DeletionBatch) error {
+	return n.put(ctx, eventTypeBatch, batchEventDetail{Bucket: res.Bucket, Policy: res.Policy, DeletionBatch: b})
+}
+
+func (n *EventBridgeNotifier) Notify(ctx context.Context, res *scanner.Result) error {
+	return n.put(ctx, eventTypeRunCompleted, NewRunSummaryMessage(res))
+}
+
+func (n *EventBridgeNotifier) put(ctx context.Context, detailType string, detail any) error {
+	raw, err := json.Marshal(detail)
+	if err != nil {
+		return err
+	}
+
+	out, err := n.client.PutEvents(ctx, &eventbridge.PutEventsInput{
+		Entries: []types.PutEventsRequestEntry{{
+			EventBusName: aws.String(n.bus),
+			Source:       aws.String(eventSource),
+			DetailType:   aws.String(detailType),
+			Detail:       aws.String(string(raw)),
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	// PutEvents reports per-entry failures with a 200 response.
+	if out.FailedEntryCount > 0 && len(out.Entries) > 0 {
+		return fmt.Errorf("event rejected: %s", aws.ToString(out.Entries[0].ErrorMessage))
+	}
+	return nil
+}
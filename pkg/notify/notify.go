@@ -0,0 +1,37 @@
+// Package notify delivers finished runs (and deletion batches) to systems
+// outside the terminal: CloudWatch, SNS, EventBridge, Slack and SES.
+package notify
+
+import (
+	"context"
+	"log"
+
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+)
+
+// Notifier delivers a finished run's summary somewhere outside the terminal.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, res *scanner.Result) error
+}
+
+// BatchNotifiersOf picks out the notifiers that also listen to deletion batches.
+func BatchNotifiersOf(notifiers []Notifier) []scanner.BatchNotifier {
+	var out []scanner.BatchNotifier
+	for _, n := range notifiers {
+		if bn, ok := n.(scanner.BatchNotifier); ok {
+			out = append(out, bn)
+		}
+	}
+	return out
+}
+
+// All runs every notifier. Delivery problems are logged, never fatal: the
+// cleanup already happened and must still be reported as such.
+func All(ctx context.Context, notifiers []Notifier, res *scanner.Result) {
+	for _, n := range notifiers {
+		if err := n.Notify(ctx, res); err != nil {
+			log.Printf("⚠️ %s notification failed: %v\n", n.Name(), err)
+		}
+	}
+}
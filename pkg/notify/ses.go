@@ -0,0 +1,29 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+)
+
+// SendEmail sends a message through SES with an HTML body and a plain-text
+// alternative for clients that don't render HTML.
+func SendEmail(ctx context.Context, cfg aws.Config, from string, to []string, subject, html, text string) error {
+	charset := aws.String("UTF-8")
+	_, err := sesv2.NewFromConfig(cfg).SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(from),
+		Destination:      &types.Destination{ToAddresses: to},
+		Content: &types.EmailContent{
+			Simple: &types.Message{
+				Subject: &types.Content{Data: aws.String(subject), Charset: charset},
+				Body: &types.Body{
+					Html: &types.Content{Data: aws.String(html), Charset: charset},
+					Text: &types.Content{Data: aws.String(text), Charset: charset},
+				},
+			},
+		},
+	})
+	return err
+}
@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aslinger/s3-tidy/internal/humanize"
+	"github.com/aslinger/s3-tidy/pkg/cost"
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+)
+
+// SlackNotifier posts a run summary to a Slack incoming webhook.
+type SlackNotifier struct {
+	webhook string
+	client  *http.Client
+}
+
+// NewSlackNotifier posts to an incoming webhook URL.
+func NewSlackNotifier(webhook string) *SlackNotifier {
+	return &SlackNotifier{webhook: webhook, client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (n *SlackNotifier) Name() string { return "Slack" }
+
+func (n *SlackNotifier) Notify(ctx context.Context, res *scanner.Result) error {
+	body, err := json.Marshal(slackMessage(res))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// slackMessage renders the summary as Block Kit, with a plain-text fallback
+// for notifications and clients that don't render blocks.
+func slackMessage(res *scanner.Result) map[string]any {
+	mode := "Cleanup"
+	switch {
+	case res.Report:
+		mode = "Cost report"
+	case res.DryRun:
+		mode = "Dry run"
+	}
+	icon := "✅"
+	if res.Errors > 0 {
+		icon = "⚠️"
+	}
+
+	title := fmt.Sprintf("%s s3-tidy %s: s3://%s", icon, strings.ToLower(mode), res.Bucket)
+	fields := []string{
+		fmt.Sprintf("*Policy*\n%s", res.Policy),
+		fmt.Sprintf("*Stale objects*\n%d (%s)", res.Stale, humanize.Bytes(res.StaleBytes)),
+		fmt.Sprintf("*Objects deleted*\n%d", res.Deleted),
+		fmt.Sprintf("*GB reclaimed*\n%.2f GB", cost.GB(res.DeletedBytes)),
+		fmt.Sprintf("*Est. monthly savings*\n%s", cost.FormatAmount(res.EstimatedSavingsLocal, res.Currency, 2)),
+		fmt.Sprintf("*Errors*\n%d", res.Errors),
+	}
+
+	var blockFields []map[string]string
+	for _, f := range fields {
+		blockFields = append(blockFields, map[string]string{"type": "mrkdwn", "text": f})
+	}
+
+	return map[string]any{
+		"text": fmt.Sprintf("%s — %d deleted, %s/month saved, %d errors", title, res.Deleted, cost.FormatAmount(res.EstimatedSavingsLocal, res.Currency, 2), res.Errors),
+		"blocks": []map[string]any{
+			{"type": "header", "text": map[string]string{"type": "plain_text", "text": title}},
+			{"type": "section", "fields": blockFields},
+			{"type": "context", "elements": []map[string]string{{
+				"type": "mrkdwn",
+				"text": fmt.Sprintf("Scanned %d objects in %s", res.Scanned, res.Finished.Sub(res.Started).Round(time.Second)),
+			}}},
+		},
+	}
+}
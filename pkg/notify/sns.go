@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// RunSummaryVersion is bumped whenever a field in the published JSON changes
+// meaning, so subscribers can branch on it.
+const RunSummaryVersion = 1
+
+// RunSummaryMessage is the structured payload published for each run.
+type RunSummaryMessage struct {
+	Source  string `json:"source"`
+	Event   string `json:"event"`
+	Version int    `json:"version"`
+	*scanner.Result
+}
+
+// NewRunSummaryMessage wraps res in the versioned envelope.
+func NewRunSummaryMessage(res *scanner.Result) RunSummaryMessage {
+	return RunSummaryMessage{Source: "s3-tidy", Event: "run.completed", Version: RunSummaryVersion, Result: res}
+}
+
+// SNSNotifier publishes the run summary to an SNS topic. Bucket, policy and mode
+// are also sent as message attributes so subscriptions can filter on them.
+type SNSNotifier struct {
+	client   *sns.Client
+	topicARN string
+}
+
+// NewSNSNotifier publishes to topicARN using cfg's credentials.
+func NewSNSNotifier(cfg aws.Config, topicARN string) *SNSNotifier {
+	return &SNSNotifier{client: sns.NewFromConfig(cfg), topicARN: topicARN}
+}
+
+func (n *SNSNotifier) Name() string { return "SNS" }
+
+func (n *SNSNotifier) Notify(ctx context.Context, res *scanner.Result) error {
+	body, err := json.Marshal(NewRunSummaryMessage(res))
+	if err != nil {
+		return err
+	}
+
+	mode := "cleanup"
+	switch {
+	case res.Report:
+		mode = "report"
+	case res.DryRun:
+		mode = "dry-run"
+	}
+	attr := func(v string) types.MessageAttributeValue {
+		return types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(v)}
+	}
+
+	subject := fmt.Sprintf("s3-tidy %s: %s", mode, res.Bucket)
+	if len(subject) > 100 { // SNS subject limit
+		subject = subject[:100]
+	}
+
+	_, err = n.client.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(n.topicARN),
+		Subject:  aws.String(subject),
+		Message:  aws.String(string(body)),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"bucket": attr(res.Bucket),
+			"policy": attr(res.Policy),
+			"mode":   attr(mode),
+		},
+	})
+	return err
+}
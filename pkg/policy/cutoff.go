@@ -0,0 +1,112 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // static binaries in scratch containers have no zoneinfo
+)
+
+// DateLayout is the calendar-date format auditors hand us for --before.
+const DateLayout = "2006-01-02"
+
+// ResolveCutoff turns the age flags into an absolute instant.
+// An explicit --before date wins and means "modified before midnight at the start
+// of that day" in the requested zone. Otherwise the cutoff is the calendar date
+// N days ago in that zone; AddDate works on wall-clock dates, so 30 days back is
+// the same time of day even when a DST transition falls inside the window.
+func ResolveCutoff(now time.Time, days int, before string, timezone string) (time.Time, error) {
+	loc, err := LoadTimezone(timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if before != "" {
+		t, err := time.ParseInLocation(DateLayout, before, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid --before date %q (expected YYYY-MM-DD): %w", before, err)
+		}
+		return t, nil
+	}
+
+	if days < 0 {
+		return time.Time{}, fmt.Errorf("--days must not be negative (got %d)", days)
+	}
+	return now.In(loc).AddDate(0, 0, -days), nil
+}
+
+// LoadTimezone accepts an IANA zone name, or "Local"/"" for the host zone.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown --timezone %q: %w", name, err)
+	}
+	return loc, nil
+}
+
+// ParseAge reads ages like "90d", "12w", "18m" or "7y" (a bare number means days)
+// and returns the instant that far before now in loc. Months and years go through
+// AddDate so "7y" lands on the same calendar date rather than 7*365 days back.
+func ParseAge(now time.Time, age string, loc *time.Location) (time.Time, error) {
+	age = strings.TrimSpace(strings.ToLower(age))
+	if age == "" {
+		return time.Time{}, fmt.Errorf("empty age")
+	}
+
+	unit := age[len(age)-1]
+	digits := age[:len(age)-1]
+	if unit >= '0' && unit <= '9' {
+		unit, digits = 'd', age
+	}
+
+	n, err := strconv.Atoi(digits)
+	if err != nil || n < 0 {
+		return time.Time{}, fmt.Errorf("invalid age %q (expected e.g. 90d, 12w, 18m, 7y)", age)
+	}
+
+	local := now.In(loc)
+	switch unit {
+	case 'd':
+		return local.AddDate(0, 0, -n), nil
+	case 'w':
+		return local.AddDate(0, 0, -7*n), nil
+	case 'm':
+		return local.AddDate(0, -n, 0), nil
+	case 'y':
+		return local.AddDate(-n, 0, 0), nil
+	default:
+		return time.Time{}, fmt.Errorf("invalid age unit in %q (use d, w, m or y)", age)
+	}
+}
+
+// ResolveAgeWindow computes the selection band for --min-age/--max-age.
+// Objects qualify when floor <= LastModified < cutoff; a zero floor means the
+// band is open-ended. minAge may be empty when the cutoff comes from --days or
+// --before, in which case fallbackCutoff is used as the upper bound.
+func ResolveAgeWindow(now time.Time, minAge, maxAge, timezone string, fallbackCutoff time.Time) (cutoff, floor time.Time, err error) {
+	loc, err := LoadTimezone(timezone)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	cutoff = fallbackCutoff
+	if minAge != "" {
+		if cutoff, err = ParseAge(now, minAge, loc); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("--min-age: %w", err)
+		}
+	}
+
+	if maxAge != "" {
+		if floor, err = ParseAge(now, maxAge, loc); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("--max-age: %w", err)
+		}
+		if !floor.Before(cutoff) {
+			return time.Time{}, time.Time{}, fmt.Errorf("--max-age must be longer than the minimum age (cutoff %s)", cutoff.Format(DateLayout))
+		}
+	}
+	return cutoff, floor, nil
+}
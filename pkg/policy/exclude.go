@@ -0,0 +1,123 @@
+package policy
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ExcludeList holds keys and glob patterns that must never be touched by a scan.
+// Application teams own these files, so the format is deliberately simple:
+// one entry per line, blank lines and lines starting with '#' are ignored.
+// Lines are otherwise taken verbatim, apart from a trailing '\r', because
+// keys may begin or end with spaces.
+type ExcludeList struct {
+	keys     map[string]struct{}
+	patterns []*regexp.Regexp
+}
+
+// LoadExcludeFile parses a newline-delimited exclusion file.
+// Entries containing glob metacharacters (*, ?, [) are treated as patterns,
+// everything else is matched as an exact key.
+func LoadExcludeFile(path string) (*ExcludeList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	list := &ExcludeList{keys: make(map[string]struct{})}
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if !strings.ContainsAny(line, "*?[") {
+			list.keys[line] = struct{}{}
+			continue
+		}
+
+		re, err := globToRegexp(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid pattern %q: %w", path, lineNo, line, err)
+		}
+		list.patterns = append(list.patterns, re)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Matches reports whether the key is covered by the exclusion list.
+// A nil list matches nothing so callers don't need to guard it.
+func (l *ExcludeList) Matches(key string) bool {
+	if l == nil {
+		return false
+	}
+	if _, ok := l.keys[key]; ok {
+		return true
+	}
+	for _, re := range l.patterns {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of entries loaded, used for the scan banner.
+func (l *ExcludeList) Len() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.keys) + len(l.patterns)
+}
+
+// globToRegexp converts a shell-style glob into an anchored regexp.
+// '*' and '?' stop at '/', while '**' spans path segments so a pattern like
+// "logs/**/debug-*.log" works the way people expect. '**/' also matches no
+// segments at all, so that pattern covers logs/debug-1.log too.
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			switch {
+			case strings.HasPrefix(glob[i:], "**/"):
+				sb.WriteString("(?:.*/)?")
+				i += 2
+			case strings.HasPrefix(glob[i:], "**"):
+				sb.WriteString(".*")
+				i++
+			default:
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated character class")
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
@@ -0,0 +1,56 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGlobDoubleStar(t *testing.T) {
+	for _, tc := range []struct {
+		glob, key string
+		want      bool
+	}{
+		{"logs/**/debug-*.log", "logs/debug-1.log", true},
+		{"logs/**/debug-*.log", "logs/app/debug-1.log", true},
+		{"logs/**/debug-*.log", "logs/app/2025/debug-1.log", true},
+		{"logs/**/debug-*.log", "logs/app/debug-1.log.gz", false},
+		{"logs/**/debug-*.log", "logsdebug-1.log", false},
+		{"**/keep", "keep", true},
+		{"**/keep", "a/b/keep", true},
+		{"tmp/**", "tmp/a/b", true},
+		{"logs/*.log", "logs/app/a.log", false},
+	} {
+		re, err := globToRegexp(tc.glob)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.glob, err)
+		}
+		if got := re.MatchString(tc.key); got != tc.want {
+			t.Errorf("%s matches %s = %v, want %v", tc.glob, tc.key, got, tc.want)
+		}
+	}
+}
+
+func TestExcludeFileKeepsSpaces(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "exclude.txt")
+	if err := os.WriteFile(file, []byte("# comment\r\n\r\n trailing space.csv \r\nplain\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := LoadExcludeFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]bool{
+		" trailing space.csv ": true,
+		"trailing space.csv":   false,
+		"plain":                true,
+		"# comment":            false,
+	} {
+		if got := l.Matches(key); got != want {
+			t.Errorf("Matches(%q) = %v, want %v", key, got, want)
+		}
+	}
+	if l.Len() != 2 {
+		t.Errorf("Len = %d, want 2", l.Len())
+	}
+}
@@ -0,0 +1,50 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Extensions restricts a scan to keys ending in one of a set of suffixes,
+// e.g. ".log" or ".tar.gz", compared case-insensitively. It is the shorthand
+// application teams reach for before they need exclude files or regexes.
+//
+// An empty set matches every key.
+type Extensions []string
+
+// ParseExtensions normalizes a list of extensions, accepting them with or
+// without the leading dot ("log" and ".LOG" are the same extension).
+func ParseExtensions(list []string) (Extensions, error) {
+	var exts Extensions
+	for _, e := range list {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e == "" || e == "." {
+			return nil, fmt.Errorf("empty extension in %q", strings.Join(list, ","))
+		}
+		if strings.Contains(e, "/") {
+			return nil, fmt.Errorf("invalid extension %q: must not contain '/'", e)
+		}
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		exts = append(exts, e)
+	}
+	return exts, nil
+}
+
+// Matches reports whether key ends in one of the extensions.
+func (x Extensions) Matches(key string) bool {
+	if len(x) == 0 {
+		return true
+	}
+	key = strings.ToLower(key)
+	for _, e := range x {
+		if strings.HasSuffix(key, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// String lists the extensions for the scan banner.
+func (x Extensions) String() string { return strings.Join(x, ", ") }
@@ -0,0 +1,126 @@
+package policy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Filter has the last word on every object a policy would act on, for rules
+// that can't be expressed as ages or patterns (e.g. an asset database lookup).
+type Filter interface {
+	// Keep reports whether c must be left alone.
+	Keep(ctx context.Context, bucket string, c Candidate) (bool, error)
+}
+
+// ExecFilter speaks a line-based JSON protocol with a long-running command:
+// one request per object on its stdin, one response per request on its stdout.
+//
+//	→ {"bucket":"b","key":"k","size":123,"last_modified":"2024-01-02T03:04:05Z"}
+//	← {"action":"keep"}   or   {"action":"delete"}
+//
+// The command starts on first use and lives for the whole scan, so expensive
+// setup (connections, caches) is paid once rather than per key.
+type ExecFilter struct {
+	command string
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Scanner
+}
+
+type filterRequest struct {
+	Bucket       string    `json:"bucket"`
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+type filterResponse struct {
+	Action string `json:"action"`
+}
+
+// NewExecFilter returns a filter for a shell command. Nothing runs until the
+// first Keep call, so resolving a policy for validation is free.
+func NewExecFilter(command string) *ExecFilter {
+	return &ExecFilter{command: command}
+}
+
+func (f *ExecFilter) start(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", f.command)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("filter %q: %w", f.command, err)
+	}
+	f.cmd, f.stdin = cmd, stdin
+	f.stdout = bufio.NewScanner(stdout)
+	f.stdout.Buffer(make([]byte, 64*1024), 1024*1024)
+	return nil
+}
+
+// Keep implements Filter. Any protocol error is returned rather than guessed
+// at: an unreachable filter must never turn into a delete.
+func (f *ExecFilter) Keep(ctx context.Context, bucket string, c Candidate) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cmd == nil {
+		if err := f.start(ctx); err != nil {
+			return true, err
+		}
+	}
+
+	req, err := json.Marshal(filterRequest{Bucket: bucket, Key: c.Key, Size: c.Size, LastModified: c.ModTime})
+	if err != nil {
+		return true, err
+	}
+	if _, err := f.stdin.Write(append(req, '\n')); err != nil {
+		return true, fmt.Errorf("filter %q: %w", f.command, err)
+	}
+	if !f.stdout.Scan() {
+		if err := f.stdout.Err(); err != nil {
+			return true, fmt.Errorf("filter %q: %w", f.command, err)
+		}
+		return true, fmt.Errorf("filter %q exited before answering", f.command)
+	}
+
+	var resp filterResponse
+	if err := json.Unmarshal(f.stdout.Bytes(), &resp); err != nil {
+		return true, fmt.Errorf("filter %q: bad response: %w", f.command, err)
+	}
+	switch resp.Action {
+	case "keep":
+		return true, nil
+	case "delete":
+		return false, nil
+	default:
+		return true, fmt.Errorf("filter %q: unknown action %q", f.command, resp.Action)
+	}
+}
+
+// Close ends the filter's input and waits for it to exit.
+func (f *ExecFilter) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cmd == nil {
+		return nil
+	}
+	f.stdin.Close()
+	err := f.cmd.Wait()
+	f.cmd = nil
+	return err
+}
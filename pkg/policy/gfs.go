@@ -0,0 +1,119 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GFSPlanner implements grandfather-father-son backup retention per prefix group:
+// every backup from the last Daily days, the newest backup of each ISO week for
+// the last Weekly weeks, and the newest of each month for the last Monthly months.
+// The newest backup in a group is always kept, so a job that stopped running
+// months ago doesn't lose its last good copy.
+type GFSPlanner struct {
+	Daily      int
+	Weekly     int
+	Monthly    int
+	GroupDepth int // leading key segments forming a group; 0 = parent "directory"
+
+	now    time.Time
+	groups map[string][]Candidate
+	kept   int
+}
+
+func NewGFSPlanner(now time.Time, daily, weekly, monthly, groupDepth int) (*GFSPlanner, error) {
+	if daily < 0 || weekly < 0 || monthly < 0 {
+		return nil, fmt.Errorf("GFS retention counts must not be negative")
+	}
+	if groupDepth < 0 {
+		return nil, fmt.Errorf("--gfs-group-depth must not be negative")
+	}
+	return &GFSPlanner{
+		Daily:      daily,
+		Weekly:     weekly,
+		Monthly:    monthly,
+		GroupDepth: groupDepth,
+		now:        now,
+		groups:     make(map[string][]Candidate),
+	}, nil
+}
+
+func (p *GFSPlanner) Describe() string {
+	depth := "parent prefix"
+	if p.GroupDepth > 0 {
+		depth = fmt.Sprintf("first %d key segments", p.GroupDepth)
+	}
+	return fmt.Sprintf("GFS retention (%d daily, %d weekly, %d monthly; grouped by %s)", p.Daily, p.Weekly, p.Monthly, depth)
+}
+
+func (p *GFSPlanner) Add(c Candidate) {
+	g := GroupKey(c.Key, p.GroupDepth)
+	p.groups[g] = append(p.groups[g], c)
+}
+
+func (p *GFSPlanner) Kept() int { return p.kept }
+
+func (p *GFSPlanner) Expired() []Candidate {
+	loc := p.now.Location()
+	dailyFrom := p.now.AddDate(0, 0, -p.Daily)
+	weeklyFrom := p.now.AddDate(0, 0, -7*p.Weekly)
+	monthlyFrom := p.now.AddDate(0, -p.Monthly, 0)
+
+	var expired []Candidate
+	p.kept = 0
+	for _, objs := range p.groups {
+		// Newest first, so the first object seen in a week/month is the one kept.
+		sort.Slice(objs, func(i, j int) bool { return objs[i].ModTime.After(objs[j].ModTime) })
+
+		weeks := make(map[string]bool)
+		months := make(map[string]bool)
+		for i, c := range objs {
+			t := c.ModTime.In(loc)
+			year, week := t.ISOWeek()
+			weekID := fmt.Sprintf("%d-W%02d", year, week)
+			monthID := t.Format("2006-01")
+
+			keep := i == 0
+			if p.Daily > 0 && !t.Before(dailyFrom) {
+				keep = true
+			}
+			if p.Weekly > 0 && !t.Before(weeklyFrom) && !weeks[weekID] {
+				weeks[weekID] = true
+				keep = true
+			}
+			if p.Monthly > 0 && !t.Before(monthlyFrom) && !months[monthID] {
+				months[monthID] = true
+				keep = true
+			}
+
+			if keep {
+				p.kept++
+			} else {
+				expired = append(expired, c)
+			}
+		}
+	}
+
+	sort.Slice(expired, func(i, j int) bool { return expired[i].Key < expired[j].Key })
+	return expired
+}
+
+// GroupKey returns the prefix group a key belongs to. With depth 0 that's the
+// key's parent prefix (backups/db1/2024-01-01.tar -> backups/db1/); otherwise
+// the first depth segments (depth 1 on backups/2024/01/01/db.tar -> backups/).
+func GroupKey(key string, depth int) string {
+	if depth == 0 {
+		if i := strings.LastIndexByte(key, '/'); i >= 0 {
+			return key[:i+1]
+		}
+		return ""
+	}
+
+	parts := strings.SplitAfter(key, "/")
+	if depth >= len(parts) {
+		depth = len(parts) - 1
+	}
+	return strings.Join(parts[:depth], "")
+}
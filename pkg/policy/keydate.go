@@ -0,0 +1,125 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyDateExtractor pulls an object's logical date out of its key.
+// Backups that get restored or re-uploaded carry a fresh LastModified, but the
+// date baked into the key (backups/2023/01/15/...) still tells the truth.
+type KeyDateExtractor struct {
+	re     *regexp.Regexp
+	layout string // used for a single "date" capture group
+	loc    *time.Location
+}
+
+// strftimeTokens maps the supported directives to named capture groups.
+var strftimeTokens = map[byte]string{
+	'Y': `(?P<Y>\d{4})`,
+	'm': `(?P<m>\d{2})`,
+	'd': `(?P<d>\d{2})`,
+	'H': `(?P<H>\d{2})`,
+	'M': `(?P<M>\d{2})`,
+	'S': `(?P<S>\d{2})`,
+}
+
+// NewKeyDateFormat compiles a strftime-style pattern such as "backups/%Y/%m/%d/".
+// The pattern may match anywhere in the key; everything after it is ignored.
+func NewKeyDateFormat(format string, loc *time.Location) (*KeyDateExtractor, error) {
+	var sb strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			sb.WriteString(regexp.QuoteMeta(string(format[i])))
+			continue
+		}
+		i++
+		if format[i] == '%' {
+			sb.WriteString("%")
+			continue
+		}
+		tok, ok := strftimeTokens[format[i]]
+		if !ok {
+			return nil, fmt.Errorf("unsupported directive %%%c in %q (use %%Y %%m %%d %%H %%M %%S)", format[i], format)
+		}
+		sb.WriteString(tok)
+	}
+
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return nil, err
+	}
+	return newKeyDateExtractor(re, "", loc)
+}
+
+// NewKeyDateRegex compiles a user regexp. It must either expose a "date" group
+// (parsed with layout, default 2006-01-02) or Y/m/d groups (year/month/day also accepted).
+func NewKeyDateRegex(expr, layout string, loc *time.Location) (*KeyDateExtractor, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return newKeyDateExtractor(re, layout, loc)
+}
+
+func newKeyDateExtractor(re *regexp.Regexp, layout string, loc *time.Location) (*KeyDateExtractor, error) {
+	has := func(names ...string) bool {
+		for _, n := range names {
+			if re.SubexpIndex(n) >= 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !has("date") && !(has("Y", "year") && has("m", "month") && has("d", "day")) {
+		return nil, fmt.Errorf("pattern %q needs a (?P<date>...) group or year, month and day groups", re.String())
+	}
+	if layout == "" {
+		layout = "2006-01-02"
+	}
+	return &KeyDateExtractor{re: re, layout: layout, loc: loc}, nil
+}
+
+// Extract returns the date embedded in key, or false when the key doesn't match.
+func (e *KeyDateExtractor) Extract(key string) (time.Time, bool) {
+	m := e.re.FindStringSubmatch(key)
+	if m == nil {
+		return time.Time{}, false
+	}
+
+	group := func(names ...string) string {
+		for _, n := range names {
+			if i := e.re.SubexpIndex(n); i >= 0 && m[i] != "" {
+				return m[i]
+			}
+		}
+		return ""
+	}
+
+	if raw := group("date"); raw != "" {
+		t, err := time.ParseInLocation(e.layout, raw, e.loc)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	}
+
+	num := func(names ...string) int {
+		n, _ := strconv.Atoi(group(names...))
+		return n
+	}
+	year, month, day := num("Y", "year"), num("m", "month"), num("d", "day")
+	if year == 0 || month < 1 || month > 12 || day < 1 || day > 31 {
+		return time.Time{}, false
+	}
+	t := time.Date(year, time.Month(month), day, num("H", "hour"), num("M", "minute"), num("S", "second"), 0, e.loc)
+	// time.Date normalises Feb 30 into March; treat that as a non-date.
+	if t.Day() != day {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,31 @@
+package policy
+
+import "time"
+
+// Candidate is the slice of object metadata retention decisions are made on.
+type Candidate struct {
+	Key     string
+	Size    int64
+	ModTime time.Time // LastModified, or the key-embedded date when configured
+	// LastModified and ETag are as listed, so deletions can detect objects
+	// rewritten since.
+	LastModified time.Time
+	ETag         string
+	StorageClass string // as listed; empty means STANDARD
+	// VersionID names one version to delete, for explicit key lists; empty
+	// means the current version.
+	VersionID string
+}
+
+// Planner is a retention mode that can only decide once it has seen
+// every object (e.g. "keep the newest weekly backup"). Plain age cutoffs stream;
+// planners buffer candidates during listing and hand back the expired ones.
+type Planner interface {
+	// Describe is printed in the scan banner.
+	Describe() string
+	Add(c Candidate)
+	// Expired returns the candidates to act on, in a deterministic order.
+	Expired() []Candidate
+	// Kept is the number of candidates the policy chose to retain.
+	Kept() int
+}
@@ -0,0 +1,290 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/aslinger/s3-tidy/pkg/cost"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is one retention rule for one bucket. The scan flags and the daemon's
+// policies.yaml both end up here, so every mode is available in both places.
+type Policy struct {
+	Name     string `yaml:"name"`
+	Bucket   string `yaml:"bucket"`
+	Days     int    `yaml:"days"`
+	Before   string `yaml:"before"`
+	Timezone string `yaml:"timezone"`
+	MinAge   string `yaml:"min_age"`
+	MaxAge   string `yaml:"max_age"`
+
+	ExcludeFile   string `yaml:"exclude_file"`
+	KeyDateFormat string `yaml:"key_date_format"`
+	KeyDateRegex  string `yaml:"key_date_regex"`
+	KeyDateLayout string `yaml:"key_date_layout"`
+
+	// Extensions limits the policy to keys with these suffixes, e.g. [.log, .tmp].
+	Extensions []string `yaml:"extensions"`
+
+	GFS            GFSConfig `yaml:"gfs"`
+	KeepReleases   int       `yaml:"keep_releases"`
+	ReleasePattern string    `yaml:"release_pattern"`
+
+	// ArchiveBucket receives a verified copy of each object before deletion.
+	ArchiveBucket       string `yaml:"archive_bucket"`
+	ArchiveStorageClass string `yaml:"archive_storage_class"`
+
+	// Action is delete (default), tag or delete-tagged; see scanner.Action.
+	// Tagging marks stale objects with ExpiryTag set to GraceDays from now.
+	Action    string `yaml:"action"`
+	ExpiryTag string `yaml:"expiry_tag"`
+	GraceDays int    `yaml:"grace_days"`
+
+	// VerifyBeforeDelete is etag (default), head or none; see scanner.Verify.
+	VerifyBeforeDelete string `yaml:"verify_before_delete"`
+	// SSECKeyFile holds the SSE-C key of customer-key encrypted objects.
+	SSECKeyFile string `yaml:"sse_c_key_file"`
+
+	// AccessLogBucket and AccessLogPrefix locate the bucket's server access
+	// logs; objects read within the last UnreadDays are kept however old.
+	AccessLogBucket string `yaml:"access_log_bucket"`
+	AccessLogPrefix string `yaml:"access_log_prefix"`
+	UnreadDays      int    `yaml:"unread_days"`
+	// Athena queries access logs already in a data lake instead.
+	Athena AthenaConfig `yaml:"athena"`
+	// CloudTrailBucket and CloudTrailPrefix locate a trail's log files; its
+	// S3 data events are read like access logs.
+	CloudTrailBucket string `yaml:"cloudtrail_bucket"`
+	CloudTrailPrefix string `yaml:"cloudtrail_prefix"`
+
+	// FilterCommand is run as an ExecFilter with the final say on every object.
+	FilterCommand string `yaml:"filter_command"`
+
+	// TargetSavings, in the display currency per month, limits each run to the
+	// largest stale objects needed to save that much; see scanner.Options.
+	TargetSavings float64 `yaml:"target_savings"`
+	// LargestFirst acts on stale objects in descending size order, and
+	// MaxDelete caps how many a run acts on.
+	LargestFirst bool `yaml:"largest_first"`
+	MaxDelete    int  `yaml:"max_delete"`
+	// MaxObjects and MaxDuration (e.g. "45m") cap how much of the bucket one
+	// run examines; CheckpointFile carries the resume point between runs.
+	MaxObjects     int    `yaml:"max_objects"`
+	MaxDuration    string `yaml:"max_duration"`
+	CheckpointFile string `yaml:"checkpoint_file"`
+	// Versions ages keys by their newest version (ListObjectVersions) and
+	// flags keys whose current version is a delete marker.
+	Versions bool `yaml:"versions"`
+
+	// DryRun defaults to true when omitted, same as the CLI.
+	DryRun *bool `yaml:"dry_run"`
+	Report bool  `yaml:"report"`
+}
+
+// GFSConfig is the grandfather-father-son section of a policy.
+type GFSConfig struct {
+	Daily      int `yaml:"daily"`
+	Weekly     int `yaml:"weekly"`
+	Monthly    int `yaml:"monthly"`
+	GroupDepth int `yaml:"group_depth"`
+}
+
+// Enabled reports whether any GFS tier is configured.
+func (g GFSConfig) Enabled() bool { return g.Daily > 0 || g.Weekly > 0 || g.Monthly > 0 }
+
+// AthenaConfig is the athena section of a policy; see accesslog.AthenaOptions.
+type AthenaConfig struct {
+	Table          string `yaml:"table"`
+	QueryFile      string `yaml:"query_file"`
+	Workgroup      string `yaml:"workgroup"`
+	OutputLocation string `yaml:"output_location"`
+}
+
+// Enabled reports whether an Athena table or query is configured.
+func (a AthenaConfig) Enabled() bool { return a.Table != "" || a.QueryFile != "" }
+
+// File is the on-disk format of --config.
+type File struct {
+	// Timezone is the default for policies that don't set their own.
+	Timezone string `yaml:"timezone"`
+	// Pricing applies to every policy's savings estimates.
+	Pricing  cost.Pricing `yaml:"pricing"`
+	Policies []Policy     `yaml:"policies"`
+}
+
+// LoadFile reads and validates a policies.yaml.
+func LoadFile(path string) (*File, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFile(raw, path)
+}
+
+// ParseFile validates policies from YAML (or JSON, which YAML accepts).
+// source only labels error messages.
+func ParseFile(raw []byte, source string) (*File, error) {
+	var pf File
+	if err := yaml.Unmarshal(raw, &pf); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	if len(pf.Policies) == 0 {
+		return nil, fmt.Errorf("%s: no policies defined", source)
+	}
+	if err := pf.Pricing.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range pf.Policies {
+		p := &pf.Policies[i]
+		if p.Bucket == "" {
+			return nil, fmt.Errorf("%s: policy #%d has no bucket", source, i+1)
+		}
+		if p.Name == "" {
+			p.Name = p.Bucket
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("%s: duplicate policy name %q", source, p.Name)
+		}
+		seen[p.Name] = true
+		if p.Timezone == "" {
+			p.Timezone = pf.Timezone
+		}
+		// Validate everything up front so a typo fails at startup, not at 3am.
+		if _, err := p.Resolve(time.Now()); err != nil {
+			return nil, fmt.Errorf("%s: policy %q: %w", source, p.Name, err)
+		}
+	}
+	return &pf, nil
+}
+
+// Selection is a policy resolved against a point in time: everything a scan
+// needs to decide which objects are stale.
+type Selection struct {
+	Cutoff   time.Time
+	Floor    time.Time // zero means no lower bound
+	Days     int       // only used for the banner
+	Excludes *ExcludeList
+	Exts     Extensions // empty means every key
+	KeyDates *KeyDateExtractor
+	Planner  Planner // nil for plain age cutoffs
+	Filter   Filter  // consulted last, after every other rule; nil means none
+}
+
+// DefaultGraceDays is how long tagged objects wait by default before they may
+// be deleted.
+const DefaultGraceDays = 30
+
+// Grace returns the effective grace period in days, which defaults to
+// DefaultGraceDays.
+func (p Policy) Grace() int {
+	if p.GraceDays == 0 {
+		return DefaultGraceDays
+	}
+	return p.GraceDays
+}
+
+// DryRunEnabled reports the effective dry-run setting, which defaults to true.
+func (p Policy) DryRunEnabled() bool { return p.DryRun == nil || *p.DryRun }
+
+// Resolve evaluates the policy at now. Relative ages are computed from now, so
+// a long-running daemon calls it again on every run.
+func (p Policy) Resolve(now time.Time) (Selection, error) {
+	useGFS := p.GFS.Enabled()
+	useReleases := p.KeepReleases > 0
+	ageSelectors := 0
+	for _, set := range []bool{p.Days > 0, p.Before != "", p.MinAge != ""} {
+		if set {
+			ageSelectors++
+		}
+	}
+
+	switch {
+	case p.Days < 0:
+		return Selection{}, fmt.Errorf("--days must not be negative (got %d)", p.Days)
+	case useGFS && useReleases:
+		return Selection{}, fmt.Errorf("GFS retention and --keep-releases cannot be combined")
+	case (useGFS || useReleases) && ageSelectors > 0:
+		return Selection{}, fmt.Errorf("retention policies replace --days/--before/--min-age; use only one")
+	case !useGFS && !useReleases && ageSelectors == 0:
+		return Selection{}, fmt.Errorf("no selection configured (set days, before, min_age, gfs or keep_releases)")
+	case ageSelectors > 1:
+		return Selection{}, fmt.Errorf("days, before and min_age are mutually exclusive")
+	case p.KeyDateFormat != "" && p.KeyDateRegex != "":
+		return Selection{}, fmt.Errorf("key_date_format and key_date_regex are mutually exclusive")
+	case p.ArchiveStorageClass != "" && p.ArchiveBucket == "":
+		return Selection{}, fmt.Errorf("archive_storage_class needs archive_bucket")
+	case p.ArchiveBucket != "" && p.ArchiveBucket == p.Bucket:
+		return Selection{}, fmt.Errorf("archive_bucket must differ from bucket")
+	case !slices.Contains([]string{"", "delete", "tag", "delete-tagged"}, p.Action):
+		return Selection{}, fmt.Errorf("unknown action %q (use delete, tag or delete-tagged)", p.Action)
+	case p.GraceDays < 0:
+		return Selection{}, fmt.Errorf("grace_days must not be negative (got %d)", p.GraceDays)
+	case !slices.Contains([]string{"", "etag", "head", "none"}, p.VerifyBeforeDelete):
+		return Selection{}, fmt.Errorf("unknown verify_before_delete %q (use etag, head or none)", p.VerifyBeforeDelete)
+	case p.ArchiveStorageClass != "" && !cost.KnownClass(p.ArchiveStorageClass):
+		return Selection{}, fmt.Errorf("unknown archive storage class %q", p.ArchiveStorageClass)
+	}
+
+	loc, err := LoadTimezone(p.Timezone)
+	if err != nil {
+		return Selection{}, err
+	}
+
+	sel := Selection{Days: p.Days}
+	if !useGFS && !useReleases {
+		cutoff, err := ResolveCutoff(now, p.Days, p.Before, p.Timezone)
+		if err != nil {
+			return Selection{}, err
+		}
+		sel.Cutoff = cutoff
+	}
+	if sel.Cutoff, sel.Floor, err = ResolveAgeWindow(now, p.MinAge, p.MaxAge, p.Timezone, sel.Cutoff); err != nil {
+		return Selection{}, err
+	}
+
+	if p.ExcludeFile != "" {
+		if sel.Excludes, err = LoadExcludeFile(p.ExcludeFile); err != nil {
+			return Selection{}, fmt.Errorf("unable to load exclude file: %w", err)
+		}
+	}
+
+	if sel.Exts, err = ParseExtensions(p.Extensions); err != nil {
+		return Selection{}, err
+	}
+
+	if p.KeyDateFormat != "" {
+		sel.KeyDates, err = NewKeyDateFormat(p.KeyDateFormat, loc)
+	} else if p.KeyDateRegex != "" {
+		sel.KeyDates, err = NewKeyDateRegex(p.KeyDateRegex, p.KeyDateLayout, loc)
+	}
+	if err != nil {
+		return Selection{}, fmt.Errorf("invalid key date pattern: %w", err)
+	}
+
+	if p.FilterCommand != "" {
+		sel.Filter = NewExecFilter(p.FilterCommand)
+	}
+
+	// Planners are stateful, so every call builds fresh ones.
+	switch {
+	case useReleases:
+		rp, err := NewReleasePlanner(p.KeepReleases, p.ReleasePattern)
+		if err != nil {
+			return Selection{}, err
+		}
+		sel.Planner = rp
+	case useGFS:
+		gfs, err := NewGFSPlanner(now.In(loc), p.GFS.Daily, p.GFS.Weekly, p.GFS.Monthly, p.GFS.GroupDepth)
+		if err != nil {
+			return Selection{}, err
+		}
+		sel.Planner = gfs
+	}
+	return sel, nil
+}
@@ -0,0 +1,187 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultReleasePattern finds semver-ish versions (1.2.3, v2.0.0-rc.1) and
+// dotted build numbers anywhere in a key.
+// Only well-known pre-release tags are treated as part of the version so that
+// platform suffixes (app-1.2.3-linux.tar.gz) stay in the artifact name.
+const DefaultReleasePattern = `(?P<version>v?\d+(?:\.\d+)+(?:-(?i:alpha|beta|rc|pre|dev|snapshot)[0-9.]*)?)`
+
+// ReleasePlanner keeps the newest Keep versions of every artifact and expires
+// the rest regardless of age. The artifact name is the key with its version
+// blanked out, so "app/1.2.3/app-1.2.3.tar.gz" and "app/1.3.0/app-1.3.0.tar.gz"
+// are two releases of the same artifact. Keys without a version are never touched.
+type ReleasePlanner struct {
+	Keep int
+
+	re         *regexp.Regexp
+	artifacts  map[string]map[string][]Candidate // name -> version -> objects
+	unversion  int
+	keptObject int
+}
+
+func NewReleasePlanner(keep int, pattern string) (*ReleasePlanner, error) {
+	if keep < 1 {
+		return nil, fmt.Errorf("--keep-releases must be at least 1")
+	}
+	if pattern == "" {
+		pattern = DefaultReleasePattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --release-pattern: %w", err)
+	}
+	if re.SubexpIndex("version") < 0 {
+		return nil, fmt.Errorf("--release-pattern needs a (?P<version>...) group")
+	}
+	return &ReleasePlanner{Keep: keep, re: re, artifacts: make(map[string]map[string][]Candidate)}, nil
+}
+
+func (p *ReleasePlanner) Describe() string {
+	return fmt.Sprintf("release retention (newest %d versions per artifact)", p.Keep)
+}
+
+func (p *ReleasePlanner) Add(c Candidate) {
+	name, version, ok := p.parse(c.Key)
+	if !ok {
+		p.unversion++
+		return
+	}
+	if p.artifacts[name] == nil {
+		p.artifacts[name] = make(map[string][]Candidate)
+	}
+	p.artifacts[name][version] = append(p.artifacts[name][version], c)
+}
+
+func (p *ReleasePlanner) Kept() int { return p.keptObject + p.unversion }
+
+func (p *ReleasePlanner) Expired() []Candidate {
+	var expired []Candidate
+	p.keptObject = 0
+	for _, versions := range p.artifacts {
+		ordered := make([]string, 0, len(versions))
+		for v := range versions {
+			ordered = append(ordered, v)
+		}
+		sort.Slice(ordered, func(i, j int) bool { return CompareVersions(ordered[i], ordered[j]) > 0 })
+
+		for i, v := range ordered {
+			if i < p.Keep {
+				p.keptObject += len(versions[v])
+				continue
+			}
+			expired = append(expired, versions[v]...)
+		}
+	}
+
+	sort.Slice(expired, func(i, j int) bool { return expired[i].Key < expired[j].Key })
+	return expired
+}
+
+// parse extracts the artifact name and version from a key. A "name" group wins
+// when the pattern has one; otherwise every occurrence of the version is blanked.
+func (p *ReleasePlanner) parse(key string) (name, version string, ok bool) {
+	m := p.re.FindStringSubmatch(key)
+	if m == nil {
+		return "", "", false
+	}
+	version = m[p.re.SubexpIndex("version")]
+	if version == "" {
+		return "", "", false
+	}
+	if i := p.re.SubexpIndex("name"); i >= 0 && m[i] != "" {
+		return m[i], version, true
+	}
+	return strings.ReplaceAll(key, version, "{version}"), version, true
+}
+
+// CompareVersions orders versions numerically segment by segment, with a
+// pre-release suffix sorting before the matching release (1.0.0-rc.1 < 1.0.0).
+// Pre-release suffixes follow semver precedence, so rc.9 < rc.10.
+func CompareVersions(a, b string) int {
+	aCore, aPre, _ := strings.Cut(strings.TrimPrefix(a, "v"), "-")
+	bCore, bPre, _ := strings.Cut(strings.TrimPrefix(b, "v"), "-")
+
+	as, bs := strings.Split(aCore, "."), strings.Split(bCore, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	default:
+		return comparePrerelease(aPre, bPre)
+	}
+}
+
+// comparePrerelease compares dot-separated pre-release identifiers in turn:
+// numeric ones numerically and below alphanumeric ones, which compare as
+// text. A suffix that is a prefix of the other sorts first (rc < rc.1).
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, y := as[i], bs[i]
+		xNum, yNum := isNumeric(x), isNumeric(y)
+		switch {
+		case xNum && yNum:
+			// Compare by length first so long numbers can't overflow.
+			x, y = strings.TrimLeft(x, "0"), strings.TrimLeft(y, "0")
+			if len(x) != len(y) {
+				if len(x) < len(y) {
+					return -1
+				}
+				return 1
+			}
+		case xNum:
+			return -1
+		case yNum:
+			return 1
+		}
+		if c := strings.Compare(x, y); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,231 @@
+// Package restore brings archived objects (Glacier Flexible Retrieval, Deep
+// Archive) back online with RestoreObject and reports how far that has got.
+package restore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// API is the subset of the S3 client restores need.
+type API interface {
+	ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	HeadObject(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	RestoreObject(ctx context.Context, in *s3.RestoreObjectInput, optFns ...func(*s3.Options)) (*s3.RestoreObjectOutput, error)
+}
+
+// DefaultStorageClasses are the classes whose objects must be restored before
+// they can be read. Glacier Instant Retrieval is readable as-is.
+var DefaultStorageClasses = []types.ObjectStorageClass{
+	types.ObjectStorageClassGlacier,
+	types.ObjectStorageClassDeepArchive,
+}
+
+// Options selects the archived objects to act on.
+type Options struct {
+	Bucket         string
+	Prefix         string
+	StorageClasses []types.ObjectStorageClass // empty means DefaultStorageClasses
+	Days           int32                      // how long the restored copy stays readable
+	Tier           types.Tier                 // Standard, Bulk or Expedited; empty means Standard
+	DryRun         bool
+	Out            io.Writer // nil means stdout
+}
+
+// Result counts what Start did.
+type Result struct {
+	Matched    int   // archived objects under the prefix
+	Bytes      int64 // their total size
+	Requested  int   // new restore requests issued
+	InProgress int   // already being restored
+	Errors     int
+}
+
+// Status counts where the archived objects under a prefix stand.
+type Status struct {
+	Archived   int // no restore requested
+	InProgress int
+	Restored   int
+	Errors     int
+	// Expires is the earliest expiry among restored copies.
+	Expires time.Time
+}
+
+// ValidateTier rejects tiers RestoreObject doesn't know.
+func ValidateTier(tier string) error {
+	if tier == "" || slices.Contains(types.Tier("").Values(), types.Tier(tier)) {
+		return nil
+	}
+	return fmt.Errorf("unknown restore tier %q (use Standard, Bulk or Expedited)", tier)
+}
+
+// ParseStorageClasses reads a comma-separated list such as "GLACIER,DEEP_ARCHIVE".
+func ParseStorageClasses(list string) ([]types.ObjectStorageClass, error) {
+	var classes []types.ObjectStorageClass
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		class := types.ObjectStorageClass(name)
+		if !slices.Contains(DefaultStorageClasses, class) {
+			return nil, fmt.Errorf("storage class %q does not need restoring (use GLACIER or DEEP_ARCHIVE)", name)
+		}
+		classes = append(classes, class)
+	}
+	return classes, nil
+}
+
+func (o Options) out() io.Writer {
+	if o.Out == nil {
+		return os.Stdout
+	}
+	return o.Out
+}
+
+// each calls fn for every archived object matching opts.
+func each(ctx context.Context, client API, opts Options, fn func(obj types.Object)) error {
+	classes := opts.StorageClasses
+	if len(classes) == 0 {
+		classes = DefaultStorageClasses
+	}
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(opts.Bucket),
+		Prefix: aws.String(opts.Prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list objects: %w", err)
+		}
+		for _, obj := range page.Contents {
+			if slices.Contains(classes, obj.StorageClass) {
+				fn(obj)
+			}
+		}
+	}
+	return nil
+}
+
+// Start requests a restore of every archived object under the prefix. Objects
+// already being restored are counted, not re-requested.
+func Start(ctx context.Context, client API, opts Options) (*Result, error) {
+	out := opts.out()
+	tier := opts.Tier
+	if tier == "" {
+		tier = types.TierStandard
+	}
+	if opts.Days <= 0 {
+		return nil, fmt.Errorf("restore days must be positive (got %d)", opts.Days)
+	}
+
+	res := &Result{}
+	err := each(ctx, client, opts, func(obj types.Object) {
+		key := aws.ToString(obj.Key)
+		res.Matched++
+		res.Bytes += aws.ToInt64(obj.Size)
+		if opts.DryRun {
+			fmt.Fprintf(out, "[DRY RUN] Would restore: %s (%s, %d days, %s tier)\n", key, obj.StorageClass, opts.Days, tier)
+			return
+		}
+
+		_, err := client.RestoreObject(ctx, &s3.RestoreObjectInput{
+			Bucket: aws.String(opts.Bucket),
+			Key:    obj.Key,
+			RestoreRequest: &types.RestoreRequest{
+				Days:                 aws.Int32(opts.Days),
+				GlacierJobParameters: &types.GlacierJobParameters{Tier: tier},
+			},
+		})
+		var apiErr smithy.APIError
+		switch {
+		case err == nil:
+			res.Requested++
+			fmt.Fprintf(out, "♻️ RESTORE REQUESTED: %s\n", key)
+		case errors.As(err, &apiErr) && apiErr.ErrorCode() == "RestoreAlreadyInProgress":
+			res.InProgress++
+		default:
+			res.Errors++
+			log.Printf("⚠️ Failed to restore %s: %v\n", key, err)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// CheckStatus reads the restore state of every archived object under the
+// prefix from HeadObject's x-amz-restore header.
+func CheckStatus(ctx context.Context, client API, opts Options, verbose bool) (*Status, error) {
+	out := opts.out()
+	st := &Status{}
+	err := each(ctx, client, opts, func(obj types.Object) {
+		key := aws.ToString(obj.Key)
+		head, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(opts.Bucket), Key: obj.Key})
+		if err != nil {
+			st.Errors++
+			log.Printf("⚠️ Failed to read restore status of %s: %v\n", key, err)
+			return
+		}
+
+		ongoing, expiry := parseRestoreHeader(aws.ToString(head.Restore))
+		state := "archived"
+		switch {
+		case head.Restore == nil:
+			st.Archived++
+		case ongoing:
+			st.InProgress++
+			state = "in progress"
+		default:
+			st.Restored++
+			state = "restored"
+			if !expiry.IsZero() {
+				state += " until " + expiry.Format(time.RFC3339)
+				if st.Expires.IsZero() || expiry.Before(st.Expires) {
+					st.Expires = expiry
+				}
+			}
+		}
+		if verbose {
+			fmt.Fprintf(out, "   %s: %s\n", key, state)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// restoreField matches one key="value" pair of the x-amz-restore header. The
+// values themselves contain commas, so splitting on them doesn't work.
+var restoreField = regexp.MustCompile(`([a-z-]+)="([^"]*)"`)
+
+// parseRestoreHeader reads values like
+// `ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`.
+func parseRestoreHeader(h string) (ongoing bool, expiry time.Time) {
+	for _, m := range restoreField.FindAllStringSubmatch(h, -1) {
+		switch m[1] {
+		case "ongoing-request":
+			ongoing = m[2] == "true"
+		case "expiry-date":
+			if t, err := time.Parse(time.RFC1123, m[2]); err == nil {
+				expiry = t
+			}
+		}
+	}
+	return ongoing, expiry
+}
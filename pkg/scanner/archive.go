@@ -0,0 +1,88 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aslinger/s3-tidy/pkg/policy"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// archiver copies objects into a second bucket under the same key, keeping
+// metadata and tags, and verifies the copy before the source may go.
+type archiver struct {
+	client       API
+	source       string
+	bucket       string
+	storageClass types.StorageClass
+	// directory is set when either bucket is a directory bucket, which have
+	// no tags and whose ETags aren't comparable with other buckets'.
+	directory bool
+	// largeCopy is set when CopyObject itself handles objects over 5 GiB.
+	largeCopy bool
+	// sse is the SSE-C key for customer-key encrypted sources, if any.
+	sse *customerKey
+}
+
+// Archive copies c and checks that the archived object has the source's size
+// and, where comparable, ETag. Any mismatch is an error, so the source is never deleted on a
+// copy that can't be trusted.
+func (a *archiver) Archive(ctx context.Context, c policy.Candidate) error {
+	if c.Size > maxCopyObjectSize && !a.largeCopy {
+		if err := a.multipartCopy(ctx, c); err != nil {
+			return fmt.Errorf("multipart copy to s3://%s: %w", a.bucket, err)
+		}
+		return a.verify(ctx, c)
+	}
+
+	in := &s3.CopyObjectInput{
+		Bucket:            aws.String(a.bucket),
+		Key:               aws.String(c.Key),
+		CopySource:        aws.String(copySource(a.source, c.Key)),
+		MetadataDirective: types.MetadataDirectiveCopy,
+		TaggingDirective:  types.TaggingDirectiveCopy,
+		StorageClass:      a.storageClass,
+	}
+	if a.directory {
+		in.TaggingDirective = ""
+	}
+	_, err := a.sse.copy(ctx, a.client, in)
+	if err != nil {
+		return fmt.Errorf("copy to s3://%s: %w", a.bucket, err)
+	}
+	return a.verify(ctx, c)
+}
+
+func (a *archiver) verify(ctx context.Context, c policy.Candidate) error {
+	head, err := a.sse.head(ctx, a.client, &s3.HeadObjectInput{
+		Bucket: aws.String(a.bucket),
+		Key:    aws.String(c.Key),
+	})
+	if err != nil {
+		return fmt.Errorf("verify archived copy: %w", err)
+	}
+	if size := aws.ToInt64(head.ContentLength); size != c.Size {
+		return fmt.Errorf("archived copy is %d bytes, source is %d", size, c.Size)
+	}
+	// A multipart ETag ("...-N") describes the upload's part layout, which a
+	// copy doesn't reproduce, so only single-part ETags are comparable.
+	// Directory buckets' ETags aren't MD5 digests, so they never are.
+	if c.ETag != "" && !a.directory && !strings.Contains(c.ETag, "-") && aws.ToString(head.ETag) != c.ETag {
+		return fmt.Errorf("archived copy ETag %s does not match source %s", aws.ToString(head.ETag), c.ETag)
+	}
+	return nil
+}
+
+// copySource builds the URL-encoded "bucket/key" CopyObject expects, keeping
+// the slashes between key segments.
+func copySource(bucket, key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return bucket + "/" + strings.Join(segments, "/")
+}
@@ -0,0 +1,70 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+)
+
+// errScanBudget stops a listing once MaxObjects or MaxDuration is reached.
+var errScanBudget = errors.New("scan budget reached")
+
+// scanBudget is the MaxObjects and MaxDuration caps of one run.
+type scanBudget struct {
+	objects  int
+	deadline time.Time
+	// reason names the cap that stopped the listing, once one has.
+	reason string
+}
+
+func newScanBudget(opts Options, started time.Time) scanBudget {
+	b := scanBudget{objects: opts.MaxObjects}
+	if opts.MaxDuration > 0 {
+		b.deadline = started.Add(opts.MaxDuration)
+	}
+	return b
+}
+
+func (b *scanBudget) enabled() bool { return b.objects > 0 || !b.deadline.IsZero() }
+
+// spent reports whether the run may examine no more objects after scanned.
+func (b *scanBudget) spent(scanned int) bool {
+	switch {
+	case b.objects > 0 && scanned >= b.objects:
+		b.reason = fmt.Sprintf("--max-objects %d", b.objects)
+	case !b.deadline.IsZero() && !time.Now().Before(b.deadline):
+		b.reason = "--max-duration"
+	default:
+		return false
+	}
+	return true
+}
+
+// readCheckpoint returns the key a previous capped run stopped at, or "" when
+// the file doesn't exist (the first run, or the last one finished the
+// listing). Only the trailing newline is trimmed; keys may end with spaces.
+func readCheckpoint(path string) (string, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to read checkpoint: %w", err)
+	}
+	return strings.TrimSuffix(string(raw), "\n"), nil
+}
+
+// writeCheckpoint records where the next run resumes, or removes the file
+// once a run has listed the bucket to its end.
+func writeCheckpoint(path, after string) error {
+	if after == "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(path, []byte(after+"\n"), 0o600)
+}
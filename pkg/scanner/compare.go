@@ -0,0 +1,185 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Mismatch is how one key differs between the two buckets of a Compare.
+type Mismatch string
+
+const (
+	OnlyInA     Mismatch = "only_in_a"
+	OnlyInB     Mismatch = "only_in_b"
+	SizeDiffers Mismatch = "size_differs"
+	// NewerInA means A's object was modified after B's copy was made. B being
+	// newer is what any copy looks like, so that isn't a mismatch.
+	NewerInA Mismatch = "newer_in_a"
+)
+
+// Difference is one key that isn't the same in both buckets. A or B is nil
+// for keys only in the other.
+type Difference struct {
+	Key  string       `json:"key"`
+	Kind Mismatch     `json:"kind"`
+	A    *ObjectState `json:"a,omitempty"`
+	B    *ObjectState `json:"b,omitempty"`
+}
+
+// ObjectState is one side of a Difference, as listed.
+type ObjectState struct {
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// CompareOptions names the buckets of a Compare: A is the source, B the copy
+// that should mirror it (an archive bucket, say).
+type CompareOptions struct {
+	A, B string
+	// ListConcurrency is as in Options, for each bucket.
+	ListConcurrency int
+}
+
+// Comparison is the outcome of a Compare.
+type Comparison struct {
+	A        string    `json:"bucket_a"`
+	B        string    `json:"bucket_b"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+
+	ObjectsA    int   `json:"objects_a"`
+	ObjectsB    int   `json:"objects_b"`
+	BytesA      int64 `json:"bytes_a"`
+	BytesB      int64 `json:"bytes_b"`
+	OnlyInA     int   `json:"only_in_a"`
+	OnlyInB     int   `json:"only_in_b"`
+	SizeDiffers int   `json:"size_differs"`
+	NewerInA    int   `json:"newer_in_a"`
+}
+
+// Mirrors reports whether every object of A is in B, at the same size and
+// no older. Extra objects in B don't count against it.
+func (c *Comparison) Mirrors() bool {
+	return c.OnlyInA+c.SizeDiffers+c.NewerInA == 0
+}
+
+// Compare lists both buckets side by side and merge-joins the two key-ordered
+// streams, handing every difference to fn as it is found. Like a scan, it
+// holds a few pages per bucket, never a whole listing.
+func (s *Scanner) Compare(ctx context.Context, opts CompareOptions, fn func(Difference)) (*Comparison, error) {
+	if opts.A == opts.B {
+		return nil, fmt.Errorf("compare needs two different buckets")
+	}
+	for _, b := range []string{opts.A, opts.B} {
+		if IsDirectoryBucket(b) {
+			return nil, fmt.Errorf("directory bucket %s lists keys in no particular order, which a merge join can't compare", b)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	res := &Comparison{A: opts.A, B: opts.B, Started: time.Now()}
+	a, b := s.stream(ctx, opts.A, opts.ListConcurrency), s.stream(ctx, opts.B, opts.ListConcurrency)
+
+	state := func(o *types.Object) *ObjectState {
+		return &ObjectState{Size: aws.ToInt64(o.Size), LastModified: aws.ToTime(o.LastModified)}
+	}
+	for {
+		oa, okA := a.peek()
+		ob, okB := b.peek()
+		if a.err != nil {
+			return nil, fmt.Errorf("%s: %w", opts.A, a.err)
+		}
+		if b.err != nil {
+			return nil, fmt.Errorf("%s: %w", opts.B, b.err)
+		}
+		if !okA && !okB {
+			break
+		}
+		var ka, kb string
+		if okA {
+			ka = aws.ToString(oa.Key)
+		}
+		if okB {
+			kb = aws.ToString(ob.Key)
+		}
+		switch {
+		case okA && (!okB || ka < kb):
+			res.ObjectsA++
+			res.BytesA += aws.ToInt64(oa.Size)
+			res.OnlyInA++
+			fn(Difference{Key: ka, Kind: OnlyInA, A: state(oa)})
+			a.next()
+		case okB && (!okA || kb < ka):
+			res.ObjectsB++
+			res.BytesB += aws.ToInt64(ob.Size)
+			res.OnlyInB++
+			fn(Difference{Key: kb, Kind: OnlyInB, B: state(ob)})
+			b.next()
+		default:
+			res.ObjectsA++
+			res.ObjectsB++
+			res.BytesA += aws.ToInt64(oa.Size)
+			res.BytesB += aws.ToInt64(ob.Size)
+			switch {
+			case aws.ToInt64(oa.Size) != aws.ToInt64(ob.Size):
+				res.SizeDiffers++
+				fn(Difference{Key: ka, Kind: SizeDiffers, A: state(oa), B: state(ob)})
+			case aws.ToTime(oa.LastModified).After(aws.ToTime(ob.LastModified)):
+				res.NewerInA++
+				fn(Difference{Key: ka, Kind: NewerInA, A: state(oa), B: state(ob)})
+			}
+			a.next()
+			b.next()
+		}
+	}
+	res.Finished = time.Now()
+	return res, nil
+}
+
+// listStream is one bucket's listing running ahead on its own goroutine.
+type listStream struct {
+	pages chan []types.Object
+	errc  chan error
+	page  []types.Object
+	i     int
+	err   error
+}
+
+func (s *Scanner) stream(ctx context.Context, bucket string, concurrency int) *listStream {
+	ls := &listStream{pages: make(chan []types.Object, pagesPerPrefix), errc: make(chan error, 1)}
+	go func() {
+		defer close(ls.pages)
+		ls.errc <- s.list(ctx, bucket, "", concurrency, func(page []types.Object) error {
+			select {
+			case ls.pages <- page:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	return ls
+}
+
+// peek returns the next object without consuming it; false once the listing
+// is done, with err set if it failed.
+func (ls *listStream) peek() (*types.Object, bool) {
+	for ls.i >= len(ls.page) {
+		page, ok := <-ls.pages
+		if !ok {
+			if ls.errc != nil {
+				ls.err, ls.errc = <-ls.errc, nil
+			}
+			return nil, false
+		}
+		ls.page, ls.i = page, 0
+	}
+	return &ls.page[ls.i], true
+}
+
+func (ls *listStream) next() { ls.i++ }
@@ -0,0 +1,301 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/aslinger/s3-tidy/pkg/policy"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// MaxDeleteBatch is the DeleteObjects API limit.
+const MaxDeleteBatch = 1000
+
+// DeletionBatch describes one DeleteObjects call (DeleteObject for a batch
+// of one) after it completed.
+type DeletionBatch struct {
+	Seq          int   `json:"batch"`
+	Keys         int   `json:"key_count"`
+	Deleted      int   `json:"deleted"`
+	Failed       int   `json:"failed"`
+	Skipped      int   `json:"skipped_modified"`
+	DeletedBytes int64 `json:"bytes"`
+}
+
+// Verify selects how deletions guard against objects rewritten after they
+// were listed.
+type Verify string
+
+const (
+	// VerifyETag makes each delete conditional on the listed ETag (If-Match).
+	VerifyETag Verify = "etag"
+	// VerifyHead re-reads every object with HeadObject before its batch, for
+	// S3-compatible stores without conditional deletes.
+	VerifyHead Verify = "head"
+	// VerifyNone deletes whatever is at the key now.
+	VerifyNone Verify = "none"
+)
+
+// BatchManifest lists the objects a deletion batch is about to remove.
+type BatchManifest struct {
+	Bucket  string           `json:"bucket"`
+	Policy  string           `json:"policy"`
+	Seq     int              `json:"batch"`
+	Objects []ManifestObject `json:"objects"`
+}
+
+// ManifestObject is one entry of a BatchManifest.
+type ManifestObject struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	VersionID    string    `json:"version_id,omitempty"`
+	// ETag is set for the objects of a plan, which apply deletes only while
+	// they still carry it.
+	ETag string `json:"etag,omitempty"`
+}
+
+// batchDeleter groups deletions into DeleteObjects calls, which is both far
+// cheaper than one request per key and the unit downstream hooks observe.
+type batchDeleter struct {
+	client  API
+	bucket  string
+	size    int
+	pending []policy.Candidate
+	seq     int
+	res     *Result
+	verify  Verify
+	// record reports the outcome of every object that reaches a batch.
+	record func(c policy.Candidate, o Outcome)
+
+	// archive, when set, must copy each object before it may be deleted.
+	archive *archiver
+	// sse is the SSE-C key VerifyHead re-reads encrypted objects with.
+	sse *customerKey
+	// redact is how keys appear in warnings.
+	redact Redaction
+	// beforeBatch runs ahead of every DeleteObjects call; an error vetoes the batch.
+	beforeBatch func(ctx context.Context, seq int, batch []policy.Candidate) error
+	// onBatch runs after every batch, successful or not.
+	onBatch func(ctx context.Context, b DeletionBatch)
+}
+
+func newBatchDeleter(client API, bucket string, size int, verify Verify, res *Result, record func(policy.Candidate, Outcome)) *batchDeleter {
+	if size <= 0 || size > MaxDeleteBatch {
+		size = MaxDeleteBatch
+	}
+	if verify == "" {
+		verify = VerifyETag
+	}
+	return &batchDeleter{client: client, bucket: bucket, size: size, verify: verify, res: res, record: record}
+}
+
+// Add queues a deletion, sending the batch once it is full.
+func (d *batchDeleter) Add(ctx context.Context, c policy.Candidate) {
+	d.pending = append(d.pending, c)
+	if len(d.pending) >= d.size {
+		d.Flush(ctx)
+	}
+}
+
+// Flush deletes whatever is queued.
+func (d *batchDeleter) Flush(ctx context.Context) {
+	if len(d.pending) == 0 {
+		return
+	}
+	batch := d.pending
+	d.pending = nil
+	d.seq++
+
+	summary := DeletionBatch{Seq: d.seq, Keys: len(batch)}
+	ctx, end := startBatch(ctx, d.bucket, d.seq, len(batch))
+	defer func() { end(summary) }()
+	if d.beforeBatch != nil {
+		if err := d.beforeBatch(ctx, d.seq, batch); err != nil {
+			log.Printf("⚠️ Pre-delete hook rejected batch %d; %d objects left in place: %v\n", d.seq, len(batch), err)
+			summary.Failed = len(batch)
+			d.recordAll(batch, OutcomeFailed)
+			d.finish(ctx, summary)
+			return
+		}
+	}
+
+	if d.verify == VerifyHead {
+		batch = d.unchanged(ctx, batch, &summary)
+	}
+
+	// Only objects with a verified archive copy may be deleted.
+	if d.archive != nil {
+		kept := batch[:0:0]
+		for _, c := range batch {
+			err := d.archive.Archive(ctx, c)
+			if isEncryptionError(err) {
+				d.res.Encrypted++
+				d.record(c, OutcomeEncrypted)
+				continue
+			}
+			if err != nil {
+				log.Printf("⚠️ Failed to archive %s, leaving it in place: %v\n", d.redact.Key(c.Key), err)
+				summary.Failed++
+				d.record(c, OutcomeFailed)
+				continue
+			}
+			d.res.Archived++
+			kept = append(kept, c)
+		}
+		batch = kept
+	}
+
+	if len(batch) == 0 {
+		d.finish(ctx, summary)
+		return
+	}
+	if len(batch) == 1 {
+		d.deleteOne(ctx, batch[0], &summary)
+		d.finish(ctx, summary)
+		return
+	}
+
+	ids := make([]types.ObjectIdentifier, len(batch))
+	for i, c := range batch {
+		ids[i] = types.ObjectIdentifier{Key: aws.String(c.Key)}
+		if c.VersionID != "" {
+			ids[i].VersionId = aws.String(c.VersionID)
+		}
+		// A version never changes, so only current versions need the ETag check.
+		if d.verify == VerifyETag && c.ETag != "" && c.VersionID == "" {
+			ids[i].ETag = aws.String(c.ETag)
+		}
+	}
+
+	out, err := d.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(d.bucket),
+		Delete: &types.Delete{Objects: ids, Quiet: aws.Bool(true)},
+	})
+	if err != nil {
+		log.Printf("⚠️ Failed to delete batch of %d objects: %v\n", len(batch), err)
+		summary.Failed += len(batch)
+		d.recordAll(batch, OutcomeFailed)
+		d.finish(ctx, summary)
+		return
+	}
+
+	// Quiet mode only reports failures; everything else was deleted. A failed
+	// precondition means the key was rewritten (or removed) after listing.
+	// Errors are matched on key and version, since a key list may name
+	// several versions of one key.
+	failed := make(map[[2]string]types.Error, len(out.Errors))
+	for _, e := range out.Errors {
+		failed[[2]string{aws.ToString(e.Key), aws.ToString(e.VersionId)}] = e
+	}
+	for _, c := range batch {
+		e, ok := failed[[2]string{c.Key, c.VersionID}]
+		switch code := aws.ToString(e.Code); {
+		case !ok:
+			summary.Deleted++
+			summary.DeletedBytes += c.Size
+			d.record(c, OutcomeDeleted)
+		case changedSinceRead(code):
+			summary.Skipped++
+			d.record(c, OutcomeSkipped)
+		default:
+			log.Printf("⚠️ Failed to delete %s: %s\n", d.redact.Key(c.Key), aws.ToString(e.Message))
+			summary.Failed++
+			d.record(c, OutcomeFailed)
+		}
+	}
+
+	d.finish(ctx, summary)
+}
+
+// deleteOne deletes a batch of one with DeleteObject, conditional on the
+// listed ETag like a DeleteObjects entry. Stores without multi-object
+// deletes get by with --delete-batch-size 1 this way.
+func (d *batchDeleter) deleteOne(ctx context.Context, c policy.Candidate, summary *DeletionBatch) {
+	in := &s3.DeleteObjectInput{Bucket: aws.String(d.bucket), Key: aws.String(c.Key)}
+	if c.VersionID != "" {
+		in.VersionId = aws.String(c.VersionID)
+	}
+	if d.verify == VerifyETag && c.ETag != "" && c.VersionID == "" {
+		in.IfMatch = aws.String(c.ETag)
+	}
+	_, err := d.client.DeleteObject(ctx, in)
+	var apiErr smithy.APIError
+	switch {
+	case err == nil:
+		summary.Deleted++
+		summary.DeletedBytes += c.Size
+		d.record(c, OutcomeDeleted)
+	case errors.As(err, &apiErr) && changedSinceRead(apiErr.ErrorCode()):
+		summary.Skipped++
+		d.record(c, OutcomeSkipped)
+	default:
+		log.Printf("⚠️ Failed to delete %s: %v\n", d.redact.Key(c.Key), err)
+		summary.Failed++
+		d.record(c, OutcomeFailed)
+	}
+}
+
+// changedSinceRead reports whether a conditional delete failed because the
+// object was rewritten (or removed) after it was read.
+func changedSinceRead(code string) bool {
+	return code == "PreconditionFailed" || code == "NoSuchKey"
+}
+
+// unchanged drops the objects whose current ETag or LastModified no longer
+// match the listing.
+func (d *batchDeleter) unchanged(ctx context.Context, batch []policy.Candidate, summary *DeletionBatch) []policy.Candidate {
+	kept := batch[:0:0]
+	for _, c := range batch {
+		in := &s3.HeadObjectInput{Bucket: aws.String(d.bucket), Key: aws.String(c.Key)}
+		if c.VersionID != "" {
+			in.VersionId = aws.String(c.VersionID)
+		}
+		head, err := d.sse.head(ctx, d.client, in)
+		var notFound *types.NotFound
+		switch {
+		case errors.As(err, &notFound):
+		case isEncryptionError(err):
+			d.res.Encrypted++
+			d.record(c, OutcomeEncrypted)
+			continue
+		case err != nil:
+			log.Printf("⚠️ Failed to re-check %s, leaving it in place: %v\n", d.redact.Key(c.Key), err)
+			summary.Failed++
+			d.record(c, OutcomeFailed)
+			continue
+		case (c.ETag == "" || aws.ToString(head.ETag) == c.ETag) && !aws.ToTime(head.LastModified).After(c.LastModified):
+			kept = append(kept, c)
+			continue
+		}
+		summary.Skipped++
+		d.record(c, OutcomeSkipped)
+	}
+	return kept
+}
+
+func (d *batchDeleter) recordAll(batch []policy.Candidate, o Outcome) {
+	for _, c := range batch {
+		d.record(c, o)
+	}
+}
+
+// finish folds a batch into the run totals and tells the listeners.
+func (d *batchDeleter) finish(ctx context.Context, b DeletionBatch) {
+	d.res.Deleted += b.Deleted
+	d.res.DeletedBytes += b.DeletedBytes
+	d.res.Errors += b.Failed
+	d.res.ModifiedSinceScan += b.Skipped
+	d.notify(ctx, b)
+}
+
+func (d *batchDeleter) notify(ctx context.Context, b DeletionBatch) {
+	if d.onBatch != nil {
+		d.onBatch(ctx, b)
+	}
+}
@@ -0,0 +1,74 @@
+package scanner_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/aslinger/s3-tidy/pkg/scanner/s3fake"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// hookFunc runs before every deletion batch, after listing: the window in
+// which another writer can rewrite or remove a listed object.
+type hookFunc func(m scanner.BatchManifest)
+
+func (f hookFunc) BeforeBatch(_ context.Context, m scanner.BatchManifest) error {
+	f(m)
+	return nil
+}
+
+func TestConditionalDeleteSkipsChangedObjects(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		batchSize int // 1 deletes with DeleteObject, more with DeleteObjects
+		op        string
+	}{
+		{"DeleteObjects", 0, "DeleteObjects"},
+		{"DeleteObject", 1, "DeleteObject"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newBucket(t, []string{"gone", "keep", "rewritten", "stale"})
+			interfere := hookFunc(func(m scanner.BatchManifest) {
+				for _, o := range m.Objects {
+					switch o.Key {
+					case "rewritten":
+						// Same key, new upload: a different ETag.
+						c.Put("b", s3fake.Object{Key: "rewritten", Size: 5, LastModified: now})
+					case "gone":
+						c.DeleteObject(context.Background(), &s3.DeleteObjectInput{Bucket: aws.String("b"), Key: aws.String("gone")})
+					}
+				}
+			})
+			res := run(t, c, scanner.Options{
+				BatchSize:      tc.batchSize,
+				PreDeleteHooks: []scanner.PreDeleteHook{interfere},
+				Selection:      excluding(t, "keep"),
+			})
+
+			if res.Deleted != 1 || res.ModifiedSinceScan != 2 || res.Errors != 0 {
+				t.Errorf("deleted %d, modified since scan %d, errors %d; want 1, 2, 0", res.Deleted, res.ModifiedSinceScan, res.Errors)
+			}
+			if got, want := c.Keys("b"), []string{"keep", "rewritten"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("keys left = %v, want %v", got, want)
+			}
+			if c.Calls(tc.op) == 0 {
+				t.Errorf("no %s call", tc.op)
+			}
+		})
+	}
+}
+
+func TestUnconditionalDeleteRemovesRewrittenObjects(t *testing.T) {
+	c := newBucket(t, []string{"rewritten"})
+	interfere := hookFunc(func(scanner.BatchManifest) {
+		c.Put("b", s3fake.Object{Key: "rewritten", Size: 5, LastModified: now})
+	})
+	res := run(t, c, scanner.Options{Verify: scanner.VerifyNone, PreDeleteHooks: []scanner.PreDeleteHook{interfere}})
+
+	if res.Deleted != 1 || len(c.Keys("b")) != 0 {
+		t.Errorf("deleted %d, keys left %v; want 1, none", res.Deleted, c.Keys("b"))
+	}
+}
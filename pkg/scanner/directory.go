@@ -0,0 +1,11 @@
+package scanner
+
+import "strings"
+
+// IsDirectoryBucket reports whether name is an S3 Express One Zone directory
+// bucket, which AWS names "<base>--<zone-id>--x-s3". The SDK routes these to
+// their zonal endpoint and signs with CreateSession credentials by itself;
+// what differs for the scanner is listing order, tags, ETags and pricing.
+func IsDirectoryBucket(name string) bool {
+	return strings.HasSuffix(name, "--x-s3")
+}
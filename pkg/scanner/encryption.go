@@ -0,0 +1,132 @@
+package scanner
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// customerKey is an SSE-C key in the three forms S3 wants it. A nil
+// *customerKey is valid and means none was supplied.
+type customerKey struct {
+	alg, key, md5 string
+}
+
+func newCustomerKey(raw []byte) (*customerKey, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("SSE-C key must be 32 bytes (AES-256), got %d", len(raw))
+	}
+	sum := md5.Sum(raw)
+	return &customerKey{alg: "AES256", key: base64.StdEncoding.EncodeToString(raw), md5: base64.StdEncoding.EncodeToString(sum[:])}, nil
+}
+
+// encryptionError means an object couldn't be read because its key isn't
+// available: it is SSE-C encrypted and no (or another) key was supplied, or
+// it is SSE-KMS encrypted and the KMS key policy denies us. Such objects are
+// skipped and reported rather than counted as failures.
+type encryptionError struct {
+	err error
+}
+
+func (e *encryptionError) Error() string { return e.err.Error() }
+func (e *encryptionError) Unwrap() error { return e.err }
+
+func isEncryptionError(err error) bool {
+	var e *encryptionError
+	return errors.As(err, &e)
+}
+
+// needsCustomerKey reports whether S3 refused a read for a missing SSE-C key.
+// It answers 400, with no body for HEAD and otherwise a message about
+// server-side encryption, which tells it apart from other bad requests.
+func needsCustomerKey(err error) bool {
+	var respErr *awshttp.ResponseError
+	if !errors.As(err, &respErr) || respErr.HTTPStatusCode() != http.StatusBadRequest {
+		return false
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorMessage() != "" {
+		return strings.Contains(strings.ToLower(apiErr.ErrorMessage()), "encryption")
+	}
+	return true
+}
+
+// kmsDenied reports whether a request failed on the object's KMS key rather
+// than on S3 permissions.
+func kmsDenied(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	code := apiErr.ErrorCode()
+	return strings.HasPrefix(code, "KMS.") || (code == "AccessDenied" && strings.Contains(strings.ToLower(apiErr.ErrorMessage()), "kms"))
+}
+
+// head is HeadObject that retries with the SSE-C key when S3 asks for one.
+func (k *customerKey) head(ctx context.Context, client API, in *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	out, err := client.HeadObject(ctx, in)
+	switch {
+	case err == nil:
+		return out, nil
+	case needsCustomerKey(err) && k == nil:
+		return nil, &encryptionError{fmt.Errorf("encrypted with a customer-provided key (SSE-C), none supplied: %w", err)}
+	case needsCustomerKey(err):
+		retry := *in
+		retry.SSECustomerAlgorithm, retry.SSECustomerKey, retry.SSECustomerKeyMD5 = &k.alg, &k.key, &k.md5
+		if out, err = client.HeadObject(ctx, &retry); err != nil {
+			return nil, &encryptionError{fmt.Errorf("not readable with the supplied SSE-C key: %w", err)}
+		}
+		return out, nil
+	case kmsDenied(err):
+		return nil, &encryptionError{err}
+	}
+	return nil, err
+}
+
+// copy is CopyObject that retries with the SSE-C key for both source and
+// copy when the source needs it, so the copy stays under the same key.
+func (k *customerKey) copy(ctx context.Context, client API, in *s3.CopyObjectInput) (*s3.CopyObjectOutput, error) {
+	out, err := client.CopyObject(ctx, in)
+	switch {
+	case err == nil:
+		return out, nil
+	case kmsDenied(err):
+		return nil, &encryptionError{err}
+	case !needsCustomerKey(err):
+		return nil, err
+	case k == nil:
+		return nil, &encryptionError{fmt.Errorf("source may be encrypted with a customer-provided key (SSE-C), none supplied: %w", err)}
+	}
+	retry := *in
+	retry.CopySourceSSECustomerAlgorithm, retry.CopySourceSSECustomerKey, retry.CopySourceSSECustomerKeyMD5 = &k.alg, &k.key, &k.md5
+	retry.SSECustomerAlgorithm, retry.SSECustomerKey, retry.SSECustomerKeyMD5 = &k.alg, &k.key, &k.md5
+	if out, err = client.CopyObject(ctx, &retry); err != nil {
+		if needsCustomerKey(err) || kmsDenied(err) {
+			err = &encryptionError{err}
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// forUpload and forPart set the key on a multipart copy of an SSE-C source,
+// whose parts are read and written under the same key.
+func (k *customerKey) forUpload(in *s3.CreateMultipartUploadInput) {
+	in.SSECustomerAlgorithm, in.SSECustomerKey, in.SSECustomerKeyMD5 = &k.alg, &k.key, &k.md5
+}
+
+func (k *customerKey) forPart(in *s3.UploadPartCopyInput) {
+	in.CopySourceSSECustomerAlgorithm, in.CopySourceSSECustomerKey, in.CopySourceSSECustomerKeyMD5 = &k.alg, &k.key, &k.md5
+	in.SSECustomerAlgorithm, in.SSECustomerKey, in.SSECustomerKeyMD5 = &k.alg, &k.key, &k.md5
+}
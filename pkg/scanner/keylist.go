@@ -0,0 +1,312 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aslinger/s3-tidy/pkg/cost"
+	"github.com/aslinger/s3-tidy/pkg/policy"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// maxKeyLine bounds one line of a key list: a 1024-byte key, a tab and a
+// version ID, with room to spare.
+const maxKeyLine = 4096
+
+// DeleteKeys deletes exactly the objects named by keys instead of the stale
+// objects of a listing. keys holds one key per line, optionally followed by a
+// tab and the version ID to delete; blank lines are skipped and nothing else
+// is trimmed, since keys may start or end with spaces. A version ID fails the
+// list on providers without object versions, which would otherwise delete
+// the current object instead.
+//
+// Every named object is re-read with HeadObject, so findings carry its size
+// and the delete is conditional on its ETag. Missing objects, excluded keys,
+// objects not yet replicated and encrypted objects without a usable key are
+// skipped as in a scan, and opts.MaxDelete caps how many are acted on. The
+// age, planner, filter, access log and ordering options don't apply.
+func (s *Scanner) DeleteKeys(ctx context.Context, opts Options, keys io.Reader) (*Result, error) {
+	_, versioned := s.client.(VersionLister)
+	lines := bufio.NewScanner(keys)
+	lines.Buffer(make([]byte, maxKeyLine), maxKeyLine)
+	lineNo := 0
+	next := func() (listedKey, bool, error) {
+		for lines.Scan() {
+			lineNo++
+			line := strings.TrimSuffix(lines.Text(), "\r")
+			if line == "" {
+				continue
+			}
+			key, version, _ := strings.Cut(line, "\t")
+			if key == "" {
+				return listedKey{}, false, fmt.Errorf("key list line %d: empty key", lineNo)
+			}
+			if version != "" && !versioned {
+				return listedKey{}, false, fmt.Errorf("key list line %d: this storage provider has no object versions, so version %s can't be deleted", lineNo, version)
+			}
+			return listedKey{key: key, version: version}, true, nil
+		}
+		if err := lines.Err(); err != nil {
+			return listedKey{}, false, fmt.Errorf("key list line %d: %w", lineNo+1, err)
+		}
+		return listedKey{}, false, nil
+	}
+
+	ctx, span := startRun(ctx, "delete keys", opts)
+	res, err := s.deleteKeys(ctx, opts, next)
+	endRun(span, res, err)
+	return res, err
+}
+
+// ApplyPlan is DeleteKeys for the objects of an approved plan (see
+// PlannedObjects). An object whose ETag, size or last-modified time differs
+// from what the plan recorded was rewritten after review, so it is skipped as
+// modified rather than deleted. Deletes are conditional on the planned ETag.
+func (s *Scanner) ApplyPlan(ctx context.Context, opts Options, objects []ManifestObject) (*Result, error) {
+	i := 0
+	next := func() (listedKey, bool, error) {
+		if i == len(objects) {
+			return listedKey{}, false, nil
+		}
+		o := &objects[i]
+		i++
+		return listedKey{key: o.Key, version: o.VersionID, planned: o}, true, nil
+	}
+
+	ctx, span := startRun(ctx, "apply plan", opts)
+	res, err := s.deleteKeys(ctx, opts, next)
+	endRun(span, res, err)
+	return res, err
+}
+
+// listedKey is one entry of a key list or plan.
+type listedKey struct {
+	key, version string
+	// planned is the object as a plan recorded it; nil for key lists.
+	planned *ManifestObject
+}
+
+// PlannedObjects returns the bucket and the objects a plan would delete: the
+// would_delete entries of a manifest written by a dry run, none when the run
+// found nothing to delete. A plan covers one bucket.
+func PlannedObjects(manifest []byte) (string, []ManifestObject, error) {
+	var bucket string
+	var objects []ManifestObject
+	lines := bufio.NewScanner(bytes.NewReader(manifest))
+	lines.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for lines.Scan() {
+		lineNo++
+		if len(bytes.TrimSpace(lines.Bytes())) == 0 {
+			continue
+		}
+		var f Finding
+		if err := json.Unmarshal(lines.Bytes(), &f); err != nil {
+			return "", nil, fmt.Errorf("plan line %d: %w", lineNo, err)
+		}
+		if f.Outcome != OutcomeWouldDelete {
+			continue
+		}
+		switch {
+		case f.Key == "":
+			return "", nil, fmt.Errorf("plan line %d: empty key", lineNo)
+		case bucket == "":
+			bucket = f.Bucket
+		case f.Bucket != bucket:
+			return "", nil, fmt.Errorf("plan line %d: bucket %s, but the plan is for %s", lineNo, f.Bucket, bucket)
+		}
+		if f.ETag == "" {
+			// Size and a timestamp in seconds can't tell a same-size rewrite apart.
+			return "", nil, fmt.Errorf("plan line %d: no ETag for %s; re-create the plan", lineNo, f.Key)
+		}
+		objects = append(objects, ManifestObject{Key: f.Key, Size: f.Size, LastModified: f.LastModified, VersionID: f.VersionID, ETag: f.ETag})
+	}
+	if err := lines.Err(); err != nil {
+		return "", nil, fmt.Errorf("plan line %d: %w", lineNo+1, err)
+	}
+	return bucket, objects, nil
+}
+
+func (s *Scanner) deleteKeys(ctx context.Context, opts Options, next func() (listedKey, bool, error)) (*Result, error) {
+	res := &Result{
+		Policy:  opts.Name,
+		Bucket:  opts.Bucket,
+		Started: time.Now(),
+		DryRun:  opts.DryRun,
+	}
+	if res.Policy == "" {
+		res.Policy = opts.Bucket
+	}
+	out := opts.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	if opts.ArchiveBucket != "" {
+		return nil, fmt.Errorf("archiving isn't supported when deleting a key list")
+	}
+	if opts.MaxDelete < 0 {
+		return nil, fmt.Errorf("max delete must not be negative (got %d)", opts.MaxDelete)
+	}
+	sse, err := newCustomerKey(opts.SSECustomerKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.RedactKeys.Validate(); err != nil {
+		return nil, err
+	}
+	redact := opts.RedactKeys.Key
+	directory := IsDirectoryBucket(opts.Bucket)
+
+	counter := &countingClient{API: s.client}
+	s = &Scanner{client: counter, sse: sse}
+
+	fmt.Fprintf(out, "🔍 Deleting the listed keys from 's3://%s'...\n", opts.Bucket)
+	if n := opts.Excludes.Len(); n > 0 {
+		fmt.Fprintf(out, "🛡️ Loaded %d exclusion entries\n", n)
+	}
+	if opts.MaxDelete > 0 {
+		fmt.Fprintf(out, "✋ Acting on at most %d objects\n", opts.MaxDelete)
+	}
+
+	record, flushSinks := recorder(opts, out, res)
+	defer flushSinks()
+	deleter := s.newDeleter(opts, res, record, directory, false)
+
+	for {
+		entry, ok, err := next()
+		if err != nil {
+			deleter.Flush(ctx)
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		key, version := entry.key, entry.version
+		res.Scanned++
+
+		if opts.Excludes.Matches(key) {
+			res.Excluded++
+			continue
+		}
+		// Objects past the cap are left without a request.
+		if opts.MaxDelete > 0 && res.Stale >= opts.MaxDelete {
+			res.Deferred++
+			continue
+		}
+
+		c, err := s.resolve(ctx, opts.Bucket, key, version)
+		var notFound *types.NotFound
+		switch {
+		case errors.As(err, &notFound):
+			res.Missing++
+			record(c.Candidate, OutcomeMissing)
+			continue
+		case isEncryptionError(err):
+			res.Encrypted++
+			record(c.Candidate, OutcomeEncrypted)
+			continue
+		case err != nil:
+			log.Printf("⚠️ Keeping %s: unable to read it: %v\n", redact(key), err)
+			res.Errors++
+			continue
+		}
+		if p := entry.planned; p != nil && (c.ETag != p.ETag || c.Size != p.Size || !c.LastModified.Truncate(time.Second).Equal(p.LastModified.Truncate(time.Second))) {
+			res.ModifiedSinceScan++
+			record(c.Candidate, OutcomeSkipped)
+			continue
+		}
+		if directory && c.StorageClass == "" {
+			c.StorageClass = string(types.ObjectStorageClassExpressOnezone)
+		}
+		if c.replication == types.ReplicationStatusPending || c.replication == types.ReplicationStatusFailed {
+			res.Unreplicated++
+			record(c.Candidate, OutcomeUnreplicated)
+			continue
+		}
+
+		res.Stale++
+		res.StaleBytes += c.Size
+		res.EstimatedSavings += opts.Pricing.MonthlySavings(c.Size, c.StorageClass)
+		if opts.DryRun {
+			record(c.Candidate, OutcomeWouldDelete)
+			continue
+		}
+		deleter.Add(ctx, c.Candidate)
+	}
+	deleter.Flush(ctx)
+
+	res.Currency = opts.Pricing.CurrencyCode()
+	res.EstimatedSavingsLocal = opts.Pricing.Convert(res.EstimatedSavings)
+	res.Requests = counter.counts()
+	res.RequestCost = opts.Pricing.RequestCost(res.Requests.List, res.Requests.Get, res.Requests.Put)
+	if directory {
+		res.RequestCost = opts.Pricing.ExpressRequestCost(res.Requests.List, res.Requests.Get, res.Requests.Put)
+	}
+	res.Finished = time.Now()
+
+	fmt.Fprintln(out, "------------------------------------------------")
+	if res.Excluded > 0 {
+		fmt.Fprintf(out, "🛡️ Skipped %d listed keys matched by the exclude file.\n", res.Excluded)
+	}
+	if res.Missing > 0 {
+		fmt.Fprintf(out, "❔ Skipped %d listed keys not in the bucket.\n", res.Missing)
+	}
+	if res.Unreplicated > 0 {
+		fmt.Fprintf(out, "🔁 Kept %d objects not yet replicated.\n", res.Unreplicated)
+	}
+	if res.Encrypted > 0 {
+		fmt.Fprintf(out, "🔐 Skipped %d encrypted objects whose key isn't available (--sse-c-key-file, or kms:Decrypt on the KMS key).\n", res.Encrypted)
+	}
+	if res.Deferred > 0 {
+		fmt.Fprintf(out, "✋ Reached --max-delete; left %d more listed keys for a later run.\n", res.Deferred)
+	}
+	fmt.Fprintf(out, "💸 Issued %d API requests (~%s).\n", res.Requests.Total(), opts.Pricing.Format(res.RequestCost, 4))
+	if opts.DryRun {
+		fmt.Fprintf(out, "✅ Dry run complete. Would delete %d of %d listed objects (%.2f GB).\n", res.Stale, res.Scanned, cost.GB(res.StaleBytes))
+		fmt.Fprintln(out, "   Run with --dry-run=false to execute cleanup.")
+	} else {
+		if res.ModifiedSinceScan > 0 {
+			fmt.Fprintf(out, "⏭️ Skipped %d objects modified or removed since they were read.\n", res.ModifiedSinceScan)
+		}
+		fmt.Fprintf(out, "✅ Cleanup complete. Deleted %d of %d listed objects (%.2f GB).\n", res.Deleted, res.Scanned, cost.GB(res.DeletedBytes))
+	}
+	return res, nil
+}
+
+// listedObject is a key list entry as HeadObject found it.
+type listedObject struct {
+	policy.Candidate
+	replication types.ReplicationStatus
+}
+
+// resolve reads the metadata of one listed key (or version). The returned
+// candidate names the key even when err is set, so it can be recorded.
+func (s *Scanner) resolve(ctx context.Context, bucket, key, version string) (listedObject, error) {
+	c := listedObject{Candidate: policy.Candidate{Key: key, VersionID: version}}
+	in := &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}
+	if version != "" {
+		in.VersionId = aws.String(version)
+	}
+	head, err := s.sse.head(ctx, s.client, in)
+	if err != nil {
+		return c, err
+	}
+	c.Size = aws.ToInt64(head.ContentLength)
+	c.LastModified = aws.ToTime(head.LastModified)
+	c.ModTime = c.LastModified
+	c.ETag = aws.ToString(head.ETag)
+	c.StorageClass = string(head.StorageClass)
+	c.replication = head.ReplicationStatus
+	return c, nil
+}
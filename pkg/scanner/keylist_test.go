@@ -0,0 +1,93 @@
+package scanner_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/aslinger/s3-tidy/pkg/scanner/s3fake"
+)
+
+// unversioned hides the fake's version listing, like the GCS and Azure
+// adapters, which have no object versions.
+type unversioned struct{ scanner.API }
+
+func TestDeleteKeysRefusesVersionsWithoutVersioning(t *testing.T) {
+	c := &s3fake.Client{}
+	c.PutNoncurrent("b", s3fake.Object{Key: "k", Size: 1, LastModified: now.AddDate(0, 0, -2), VersionID: "old"})
+	c.Put("b", s3fake.Object{Key: "k", Size: 2, LastModified: now, VersionID: "live"})
+
+	_, err := scanner.New(unversioned{c}).DeleteKeys(context.Background(), scanner.Options{Bucket: "b", Out: io.Discard}, strings.NewReader("k\told\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("error = %v, want line 1 refused", err)
+	}
+	if n := c.Calls("DeleteObject") + c.Calls("DeleteObjects"); n != 0 {
+		t.Errorf("%d delete calls, want none", n)
+	}
+	if got := c.Keys("b"); !reflect.DeepEqual(got, []string{"k"}) {
+		t.Errorf("keys left = %v, want the live k", got)
+	}
+
+	// With versions, the same line deletes only the named version.
+	res, err := scanner.New(c).DeleteKeys(context.Background(), scanner.Options{Bucket: "b", Out: io.Discard}, strings.NewReader("k\told\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Deleted != 1 || res.DeletedBytes != 1 {
+		t.Errorf("deleted %d (%d bytes), want the 1-byte old version", res.Deleted, res.DeletedBytes)
+	}
+	if got := c.Keys("b"); !reflect.DeepEqual(got, []string{"k"}) {
+		t.Errorf("keys left = %v, want the live k", got)
+	}
+}
+
+// plan dry-runs bucket "b" of c and returns the objects its manifest plans
+// to delete.
+func plan(t *testing.T, c *s3fake.Client) []scanner.ManifestObject {
+	t.Helper()
+	var manifest bytes.Buffer
+	run(t, c, scanner.Options{DryRun: true, Sinks: []scanner.Sink{scanner.NewManifestSink(&manifest)}})
+	_, objects, err := scanner.PlannedObjects(manifest.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return objects
+}
+
+func TestApplyPlanSkipsObjectsRewrittenAfterPlanning(t *testing.T) {
+	c := newBucket(t, []string{"rewritten", "racing", "unchanged"})
+	objects := plan(t, c)
+
+	// Same size, within the same second: only the ETag tells it apart.
+	c.Put("b", s3fake.Object{Key: "rewritten", Size: 100, LastModified: now.AddDate(0, 0, -100).Add(time.Millisecond)})
+	// Rewritten after apply re-read it, before the delete went out.
+	race := hookFunc(func(m scanner.BatchManifest) {
+		for _, o := range m.Objects {
+			if o.Key == "racing" {
+				c.Put("b", s3fake.Object{Key: "racing", Size: 100, LastModified: now.AddDate(0, 0, -100).Add(time.Millisecond)})
+			}
+		}
+	})
+	res, err := scanner.New(c).ApplyPlan(context.Background(), scanner.Options{Bucket: "b", Out: io.Discard, PreDeleteHooks: []scanner.PreDeleteHook{race}}, objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Deleted != 1 || res.ModifiedSinceScan != 2 {
+		t.Errorf("deleted %d, modified since scan %d; want 1, 2", res.Deleted, res.ModifiedSinceScan)
+	}
+	if got, want := c.Keys("b"), []string{"racing", "rewritten"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys left = %v, want %v", got, want)
+	}
+}
+
+func TestPlannedObjectsNeedETags(t *testing.T) {
+	line := `{"bucket":"b","key":"k","size":1,"last_modified":"2025-01-01T00:00:00Z","mod_time":"2025-01-01T00:00:00Z","outcome":"would_delete"}` + "\n"
+	if _, _, err := scanner.PlannedObjects([]byte(line)); err == nil {
+		t.Error("plan without ETags accepted")
+	}
+}
@@ -0,0 +1,186 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// DefaultListConcurrency is how many top-level prefixes are listed at once.
+const DefaultListConcurrency = 8
+
+// pagesPerPrefix bounds how far one prefix's lister may run ahead of the
+// consumer, so memory stays at roughly concurrency × pagesPerPrefix × 1000 keys.
+const pagesPerPrefix = 4
+
+// segment is one unit of the fanned-out listing: either a root-level object
+// or a top-level prefix to list in full.
+type segment struct {
+	sortKey string
+	root    *types.Object
+	prefix  string
+	after   string // resume point within prefix, if any
+	pages   chan []types.Object
+	err     chan error
+}
+
+// list hands every object in the bucket to fn one page at a time, in the same
+// lexicographic order a single ListObjectsV2 stream would produce. With
+// concurrency > 1 it first discovers the top-level prefixes with a delimiter
+// listing and lists up to concurrency of them in parallel; fn still runs on
+// the calling goroutine only, so callers need no locking.
+//
+// Directory buckets list keys in no particular order, so they always go
+// through the prefix fan-out: top-level prefixes then still arrive in key
+// order, each as one contiguous run, even though keys within one don't.
+//
+// A non-empty after resumes the listing past that key, like StartAfter.
+func (s *Scanner) list(ctx context.Context, bucket, after string, concurrency int, fn func([]types.Object) error) error {
+	if concurrency == 0 {
+		concurrency = DefaultListConcurrency
+	}
+	if concurrency <= 1 {
+		if !IsDirectoryBucket(bucket) {
+			return s.listPrefix(ctx, bucket, "", after, fn)
+		}
+		concurrency = 1
+	}
+
+	segments, err := s.topLevel(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if after != "" {
+		segments = resumeAfter(segments, after)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Start listers in segment order, keeping at most concurrency ahead of the
+	// consumer; a slot frees up when the consumer finishes a prefix.
+	slots := make(chan struct{}, concurrency)
+	started := 0
+	startNext := func() {
+		for started < len(segments) {
+			seg := segments[started]
+			if seg.root != nil {
+				started++
+				continue
+			}
+			select {
+			case slots <- struct{}{}:
+			default:
+				return
+			}
+			started++
+			go func() {
+				defer close(seg.pages)
+				err := s.listPrefix(ctx, bucket, seg.prefix, seg.after, func(page []types.Object) error {
+					select {
+					case seg.pages <- page:
+						return nil
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				})
+				seg.err <- err
+			}()
+		}
+	}
+
+	startNext()
+	for i, seg := range segments {
+		if seg.root != nil {
+			if err := fn([]types.Object{*seg.root}); err != nil {
+				return err
+			}
+			continue
+		}
+		if i >= started {
+			startNext()
+		}
+		for page := range seg.pages {
+			if err := fn(page); err != nil {
+				return err
+			}
+		}
+		if err := <-seg.err; err != nil {
+			return err
+		}
+		<-slots
+		startNext()
+	}
+	return nil
+}
+
+// topLevel lists the bucket root with a "/" delimiter and returns its objects
+// and common prefixes merged into key order.
+func (s *Scanner) topLevel(ctx context.Context, bucket string) ([]*segment, error) {
+	var segments []*segment
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(bucket),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+		for i := range page.Contents {
+			obj := page.Contents[i]
+			segments = append(segments, &segment{sortKey: aws.ToString(obj.Key), root: &obj})
+		}
+		for _, cp := range page.CommonPrefixes {
+			p := aws.ToString(cp.Prefix)
+			segments = append(segments, &segment{sortKey: p, prefix: p, pages: make(chan []types.Object, pagesPerPrefix), err: make(chan error, 1)})
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].sortKey < segments[j].sortKey })
+	return segments, nil
+}
+
+// resumeAfter drops the segments wholly at or before key and has the prefix
+// holding key resume within it. A prefix sorting before key holds only
+// smaller keys unless key itself starts with it.
+func resumeAfter(segments []*segment, key string) []*segment {
+	kept := segments[:0]
+	for _, seg := range segments {
+		switch {
+		case seg.root != nil && seg.sortKey <= key:
+		case seg.root == nil && strings.HasPrefix(key, seg.prefix):
+			seg.after = key
+			kept = append(kept, seg)
+		case seg.root == nil && seg.prefix < key:
+		default:
+			kept = append(kept, seg)
+		}
+	}
+	return kept
+}
+
+func (s *Scanner) listPrefix(ctx context.Context, bucket, prefix, after string, fn func([]types.Object) error) error {
+	in := &s3.ListObjectsV2Input{Bucket: aws.String(bucket)}
+	if prefix != "" {
+		in.Prefix = aws.String(prefix)
+	}
+	if after != "" {
+		in.StartAfter = aws.String(after)
+	}
+	paginator := s3.NewListObjectsV2Paginator(s.client, in)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list objects: %w", err)
+		}
+		if err := fn(page.Contents); err != nil {
+			return err
+		}
+	}
+	return nil
+}
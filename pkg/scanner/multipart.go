@@ -0,0 +1,155 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aslinger/s3-tidy/pkg/policy"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// maxCopyObjectSize is the largest object a single CopyObject call accepts.
+	maxCopyObjectSize = 5 << 30
+	// minCopyPartSize keeps the part count low for ordinary large objects.
+	minCopyPartSize = 512 << 20
+	maxCopyParts    = 10000
+	// copyPartConcurrency bounds the UploadPartCopy calls in flight per object.
+	copyPartConcurrency = 4
+)
+
+// multipartCopy copies objects over 5 GiB with UploadPartCopy. Unlike
+// CopyObject it can't carry metadata and tags over by itself, so both are read
+// from the source and set on the new upload explicitly.
+func (a *archiver) multipartCopy(ctx context.Context, c policy.Candidate) error {
+	head, err := a.sse.head(ctx, a.client, &s3.HeadObjectInput{Bucket: aws.String(a.source), Key: aws.String(c.Key)})
+	if err != nil {
+		return fmt.Errorf("read source metadata: %w", err)
+	}
+	tags := &s3.GetObjectTaggingOutput{}
+	if !a.directory {
+		if tags, err = a.client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{Bucket: aws.String(a.source), Key: aws.String(c.Key)}); err != nil {
+			return fmt.Errorf("read source tags: %w", err)
+		}
+	}
+
+	// An SSE-C source only answered the HEAD with the key; its copy keeps it.
+	sseC := head.SSECustomerAlgorithm != nil
+	create := &s3.CreateMultipartUploadInput{
+		Bucket:             aws.String(a.bucket),
+		Key:                aws.String(c.Key),
+		StorageClass:       a.storageClass,
+		Metadata:           head.Metadata,
+		ContentType:        head.ContentType,
+		ContentEncoding:    head.ContentEncoding,
+		ContentDisposition: head.ContentDisposition,
+		ContentLanguage:    head.ContentLanguage,
+		CacheControl:       head.CacheControl,
+		Expires:            head.Expires,
+		Tagging:            encodeTags(tags.TagSet),
+	}
+	if sseC {
+		a.sse.forUpload(create)
+	}
+	upload, err := a.client.CreateMultipartUpload(ctx, create)
+	if err != nil {
+		return err
+	}
+	abort := func() {
+		// Best effort; a lifecycle rule for incomplete uploads cleans up the rest.
+		a.client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+			Bucket: aws.String(a.bucket), Key: aws.String(c.Key), UploadId: upload.UploadId,
+		})
+	}
+
+	parts, err := a.copyParts(ctx, c, upload.UploadId, sseC)
+	if err != nil {
+		abort()
+		return err
+	}
+
+	_, err = a.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(a.bucket),
+		Key:             aws.String(c.Key),
+		UploadId:        upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		abort()
+		return err
+	}
+	return nil
+}
+
+func (a *archiver) copyParts(ctx context.Context, c policy.Candidate, uploadID *string, sseC bool) ([]types.CompletedPart, error) {
+	partSize := int64(minCopyPartSize)
+	if need := (c.Size + maxCopyParts - 1) / maxCopyParts; need > partSize {
+		partSize = need
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		parts    []types.CompletedPart
+		firstErr error
+	)
+	sem := make(chan struct{}, copyPartConcurrency)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for n, start := int32(1), int64(0); start < c.Size; n, start = n+1, start+partSize {
+		end := min(start+partSize, c.Size) - 1
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(n int32, start, end int64) {
+			defer func() { <-sem; wg.Done() }()
+			in := &s3.UploadPartCopyInput{
+				Bucket:          aws.String(a.bucket),
+				Key:             aws.String(c.Key),
+				UploadId:        uploadID,
+				PartNumber:      aws.Int32(n),
+				CopySource:      aws.String(copySource(a.source, c.Key)),
+				CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+			}
+			if sseC {
+				a.sse.forPart(in)
+			}
+			out, err := a.client.UploadPartCopy(ctx, in)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("part %d: %w", n, err)
+					cancel()
+				}
+				return
+			}
+			parts = append(parts, types.CompletedPart{PartNumber: aws.Int32(n), ETag: out.CopyPartResult.ETag})
+		}(n, start, end)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return *parts[i].PartNumber < *parts[j].PartNumber })
+	return parts, nil
+}
+
+// encodeTags renders a tag set as the query string CreateMultipartUpload takes.
+func encodeTags(tags []types.Tag) *string {
+	if len(tags) == 0 {
+		return nil
+	}
+	pairs := make([]string, len(tags))
+	for i, t := range tags {
+		pairs[i] = url.QueryEscape(aws.ToString(t.Key)) + "=" + url.QueryEscape(aws.ToString(t.Value))
+	}
+	return aws.String(strings.Join(pairs, "&"))
+}
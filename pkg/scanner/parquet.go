@@ -0,0 +1,81 @@
+package scanner
+
+import (
+	"io"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+const (
+	// parquetRowGroup bounds how many findings the writer holds before it
+	// encodes a row group, which is what keeps huge scans in bounded memory.
+	parquetRowGroup = 1 << 18
+	parquetBatch    = 4096
+)
+
+// parquetFinding is the column layout of ParquetSink, the same fields as
+// Finding. Timestamps are in milliseconds, which Athena reads natively.
+type parquetFinding struct {
+	Bucket       string    `parquet:"bucket,dict"`
+	Key          string    `parquet:"key"`
+	Size         int64     `parquet:"size"`
+	LastModified time.Time `parquet:"last_modified,timestamp(millisecond)"`
+	ModTime      time.Time `parquet:"mod_time,timestamp(millisecond)"`
+	StorageClass string    `parquet:"storage_class,dict"`
+	Outcome      string    `parquet:"outcome,dict"`
+	Archived     bool      `parquet:"archived"`
+}
+
+// ParquetSink writes findings as a zstd-compressed Parquet file, for scans
+// too large to query as CSV.
+type ParquetSink struct {
+	w     *parquet.GenericWriter[parquetFinding]
+	batch []parquetFinding
+}
+
+// NewParquetSink returns a sink writing Parquet to w. The file is complete
+// once Flush has written its footer; the caller closes w.
+func NewParquetSink(w io.Writer) *ParquetSink {
+	return &ParquetSink{
+		w: parquet.NewGenericWriter[parquetFinding](w,
+			parquet.Compression(&parquet.Zstd),
+			parquet.MaxRowsPerRowGroup(parquetRowGroup),
+			parquet.CreatedBy("s3-tidy", "", ""),
+		),
+		batch: make([]parquetFinding, 0, parquetBatch),
+	}
+}
+
+// Write implements Sink.
+func (s *ParquetSink) Write(f Finding) error {
+	s.batch = append(s.batch, parquetFinding{
+		Bucket:       f.Bucket,
+		Key:          f.Key,
+		Size:         f.Size,
+		LastModified: f.LastModified.UTC(),
+		ModTime:      f.ModTime.UTC(),
+		StorageClass: f.StorageClass,
+		Outcome:      string(f.Outcome),
+		Archived:     f.Archived,
+	})
+	if len(s.batch) < parquetBatch {
+		return nil
+	}
+	return s.writeBatch()
+}
+
+func (s *ParquetSink) writeBatch() error {
+	_, err := s.w.Write(s.batch)
+	s.batch = s.batch[:0]
+	return err
+}
+
+// Flush implements Sink. It writes the footer, so a ParquetSink takes no
+// findings after it.
+func (s *ParquetSink) Flush() error {
+	if err := s.writeBatch(); err != nil {
+		return err
+	}
+	return s.w.Close()
+}
@@ -0,0 +1,80 @@
+package scanner
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/aslinger/s3-tidy/pkg/policy"
+)
+
+// ranking holds back stale objects and keeps only the heaviest ones: at most
+// limit of them, and no more than needed to reach target. It keeps a min-heap
+// of its current picks and drops the lightest as soon as it isn't needed, so
+// memory grows with the limit or target rather than with the bucket.
+type ranking struct {
+	weight  func(policy.Candidate) float64
+	target  float64 // total weight to reach; 0 means keep everything
+	limit   int     // maximum picks; 0 means no limit
+	picks   rankHeap
+	total   float64
+	dropped int
+}
+
+// bySize ranks objects by size.
+func bySize(c policy.Candidate) float64 { return float64(c.Size) }
+
+type rankPick struct {
+	c      policy.Candidate
+	weight float64
+}
+
+// less orders picks by weight, breaking ties so older objects rank higher.
+func (a rankPick) less(b rankPick) bool {
+	if a.weight != b.weight {
+		return a.weight < b.weight
+	}
+	return a.c.ModTime.After(b.c.ModTime)
+}
+
+type rankHeap []rankPick
+
+func (h rankHeap) Len() int           { return len(h) }
+func (h rankHeap) Less(i, j int) bool { return h[i].less(h[j]) }
+func (h rankHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *rankHeap) Push(x any)        { *h = append(*h, x.(rankPick)) }
+func (h *rankHeap) Pop() any {
+	old := *h
+	p := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return p
+}
+
+func (r *ranking) Add(c policy.Candidate) {
+	p := rankPick{c: c, weight: r.weight(c)}
+	heap.Push(&r.picks, p)
+	r.total += p.weight
+	for r.overfull() {
+		p := heap.Pop(&r.picks).(rankPick)
+		r.total -= p.weight
+		r.dropped++
+	}
+}
+
+// overfull reports whether the lightest pick can go.
+func (r *ranking) overfull() bool {
+	if r.limit > 0 && len(r.picks) > r.limit {
+		return true
+	}
+	return r.target > 0 && len(r.picks) > 1 && r.total-r.picks[0].weight >= r.target
+}
+
+// Selected returns the picks, heaviest first.
+func (r *ranking) Selected() []policy.Candidate {
+	picks := append(rankHeap(nil), r.picks...)
+	sort.Slice(picks, func(i, j int) bool { return picks[j].less(picks[i]) })
+	out := make([]policy.Candidate, len(picks))
+	for i, p := range picks {
+		out[i] = p.c
+	}
+	return out
+}
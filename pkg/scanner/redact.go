@@ -0,0 +1,84 @@
+package scanner
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Redaction hides object keys, which may carry customer identifiers, from the
+// human-readable output of a run: progress lines, warnings, CSV findings and
+// prefixes in reports and notifications. The JSON-lines manifest keeps the
+// full keys; it is the audit record.
+type Redaction struct {
+	Mode RedactMode
+	// Secret keys the RedactHash HMAC. Without it anyone could hash guessed
+	// keys and compare.
+	Secret []byte
+}
+
+// RedactMode says how a Redaction hides keys.
+type RedactMode string
+
+const (
+	RedactNone RedactMode = ""
+	// RedactHash replaces a key with the start of its HMAC-SHA256 under the
+	// redaction secret, so a line can still be matched against the manifest
+	// by whoever holds the secret.
+	RedactHash RedactMode = "hash"
+	// RedactTruncate keeps only a key's top-level prefix.
+	RedactTruncate RedactMode = "truncate"
+)
+
+// MinRedactSecret is the shortest secret RedactHash accepts.
+const MinRedactSecret = 16
+
+// Validate rejects unknown modes, and hashing without a usable secret.
+func (r Redaction) Validate() error {
+	switch r.Mode {
+	case RedactNone, RedactTruncate:
+		return nil
+	case RedactHash:
+		if len(r.Secret) < MinRedactSecret {
+			return fmt.Errorf("hash key redaction needs a secret of at least %d bytes, got %d", MinRedactSecret, len(r.Secret))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown key redaction %q (use hash or truncate)", string(r.Mode))
+}
+
+// Key returns key as it may be shown.
+func (r Redaction) Key(key string) string {
+	switch r.Mode {
+	case RedactHash:
+		mac := hmac.New(sha256.New, r.Secret)
+		mac.Write([]byte(key))
+		return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:8])
+	case RedactTruncate:
+		if i := strings.Index(key, "/"); i >= 0 {
+			return key[:i+1] + "…"
+		}
+		return "…"
+	}
+	return key
+}
+
+// Sink wraps s so that it only ever sees redacted keys.
+func (r Redaction) Sink(s Sink) Sink {
+	if r.Mode == RedactNone {
+		return s
+	}
+	return redactingSink{Sink: s, redact: r}
+}
+
+type redactingSink struct {
+	Sink
+	redact Redaction
+}
+
+func (s redactingSink) Write(f Finding) error {
+	f.Key = s.redact.Key(f.Key)
+	return s.Sink.Write(f)
+}
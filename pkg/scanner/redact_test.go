@@ -0,0 +1,45 @@
+package scanner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestRedactHashNeedsSecret(t *testing.T) {
+	for _, secret := range []string{"", "too-short"} {
+		if err := (Redaction{Mode: RedactHash, Secret: []byte(secret)}).Validate(); err == nil {
+			t.Errorf("secret %q: no error", secret)
+		}
+	}
+	if err := (Redaction{Mode: RedactHash, Secret: []byte("0123456789abcdef")}).Validate(); err != nil {
+		t.Errorf("16-byte secret: %v", err)
+	}
+	if err := (Redaction{Mode: RedactTruncate}).Validate(); err != nil {
+		t.Errorf("truncate without a secret: %v", err)
+	}
+}
+
+func TestRedactHashIsKeyed(t *testing.T) {
+	const key = "customers/4711/export.csv"
+	a := Redaction{Mode: RedactHash, Secret: []byte("first secret, 16+ bytes")}
+	b := Redaction{Mode: RedactHash, Secret: []byte("second secret, 16+ bytes")}
+
+	if a.Key(key) != a.Key(key) {
+		t.Error("same key and secret hash differently")
+	}
+	if a.Key(key) == b.Key(key) {
+		t.Error("different secrets give the same hash")
+	}
+	if a.Key(key) == a.Key("customers/4712/export.csv") {
+		t.Error("different keys give the same hash")
+	}
+	plain := sha256.Sum256([]byte(key))
+	if strings.Contains(a.Key(key), hex.EncodeToString(plain[:8])) {
+		t.Error("hash can be recomputed without the secret")
+	}
+	if strings.Contains(a.Key(key), "4711") {
+		t.Errorf("redacted key %q shows the customer ID", a.Key(key))
+	}
+}
@@ -0,0 +1,36 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// replicated reports whether bucket has a replication configuration, in which
+// case an object must not be deleted before it has been copied: the replica
+// of a source deleted while PENDING never appears.
+func (s *Scanner) replicated(ctx context.Context, bucket string) (bool, error) {
+	out, err := s.client.GetBucketReplication(ctx, &s3.GetBucketReplicationInput{Bucket: aws.String(bucket)})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "ReplicationConfigurationNotFoundError" {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return out.ReplicationConfiguration != nil && len(out.ReplicationConfiguration.Rules) > 0, nil
+}
+
+// replicationStatus reads an object's x-amz-replication-status, which
+// ListObjectsV2 doesn't return. Objects outside every rule have none.
+func (s *Scanner) replicationStatus(ctx context.Context, bucket, key string) (types.ReplicationStatus, error) {
+	head, err := s.sse.head(ctx, s.client, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return "", err
+	}
+	return head.ReplicationStatus, nil
+}
@@ -0,0 +1,164 @@
+package scanner
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestCounts tallies the S3 requests a run issued, grouped the way S3
+// bills them.
+type RequestCounts struct {
+	List   int64 `json:"list"`   // ListObjectsV2, ListObjectVersions
+	Get    int64 `json:"get"`    // HeadObject, GetObjectTagging, GetObject (access logs), GetBucketReplication
+	Put    int64 `json:"put"`    // CopyObject, multipart copy steps, PutObjectTagging
+	Delete int64 `json:"delete"` // DeleteObject(s), AbortMultipartUpload; free
+}
+
+// Total is every request issued.
+func (r RequestCounts) Total() int64 {
+	return r.List + r.Get + r.Put + r.Delete
+}
+
+// countingClient counts the requests made through it, each in its own span.
+// Listing and multipart copies call it from several goroutines, hence the
+// atomics.
+type countingClient struct {
+	API
+	list, get, put, del atomic.Int64
+}
+
+func (c *countingClient) counts() RequestCounts {
+	return RequestCounts{List: c.list.Load(), Get: c.get.Load(), Put: c.put.Load(), Delete: c.del.Load()}
+}
+
+func (c *countingClient) ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	c.list.Add(1)
+	ctx, end := startRequest(ctx, "ListObjectsV2", aws.ToString(in.Bucket))
+	out, err := c.API.ListObjectsV2(ctx, in, optFns...)
+	if err == nil {
+		// One ListObjectsV2 request is one page of a listing.
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("s3tidy.prefix", aws.ToString(in.Prefix)), attribute.Int("s3tidy.page.keys", len(out.Contents)))
+	}
+	end(err)
+	return out, err
+}
+
+func (c *countingClient) ListObjectVersions(ctx context.Context, in *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	lister, ok := c.API.(VersionLister)
+	if !ok {
+		return nil, errNoVersions
+	}
+	c.list.Add(1)
+	ctx, end := startRequest(ctx, "ListObjectVersions", aws.ToString(in.Bucket))
+	out, err := lister.ListObjectVersions(ctx, in, optFns...)
+	if err == nil {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Int("s3tidy.page.keys", len(out.Versions)+len(out.DeleteMarkers)))
+	}
+	end(err)
+	return out, err
+}
+
+func (c *countingClient) HeadObject(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	c.get.Add(1)
+	ctx, end := startRequest(ctx, "HeadObject", aws.ToString(in.Bucket))
+	out, err := c.API.HeadObject(ctx, in, optFns...)
+	end(err)
+	return out, err
+}
+
+func (c *countingClient) GetObjectTagging(ctx context.Context, in *s3.GetObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	c.get.Add(1)
+	ctx, end := startRequest(ctx, "GetObjectTagging", aws.ToString(in.Bucket))
+	out, err := c.API.GetObjectTagging(ctx, in, optFns...)
+	end(err)
+	return out, err
+}
+
+func (c *countingClient) GetObject(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	c.get.Add(1)
+	ctx, end := startRequest(ctx, "GetObject", aws.ToString(in.Bucket))
+	out, err := c.API.GetObject(ctx, in, optFns...)
+	end(err)
+	return out, err
+}
+
+func (c *countingClient) CopyObject(ctx context.Context, in *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	c.put.Add(1)
+	ctx, end := startRequest(ctx, "CopyObject", aws.ToString(in.Bucket))
+	out, err := c.API.CopyObject(ctx, in, optFns...)
+	end(err)
+	return out, err
+}
+
+func (c *countingClient) PutObjectTagging(ctx context.Context, in *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+	tagger, ok := c.API.(ObjectTagger)
+	if !ok {
+		return nil, errNoTagging
+	}
+	c.put.Add(1)
+	ctx, end := startRequest(ctx, "PutObjectTagging", aws.ToString(in.Bucket))
+	out, err := tagger.PutObjectTagging(ctx, in, optFns...)
+	end(err)
+	return out, err
+}
+
+func (c *countingClient) CreateMultipartUpload(ctx context.Context, in *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	c.put.Add(1)
+	ctx, end := startRequest(ctx, "CreateMultipartUpload", aws.ToString(in.Bucket))
+	out, err := c.API.CreateMultipartUpload(ctx, in, optFns...)
+	end(err)
+	return out, err
+}
+
+func (c *countingClient) UploadPartCopy(ctx context.Context, in *s3.UploadPartCopyInput, optFns ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error) {
+	c.put.Add(1)
+	ctx, end := startRequest(ctx, "UploadPartCopy", aws.ToString(in.Bucket))
+	out, err := c.API.UploadPartCopy(ctx, in, optFns...)
+	end(err)
+	return out, err
+}
+
+func (c *countingClient) CompleteMultipartUpload(ctx context.Context, in *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	c.put.Add(1)
+	ctx, end := startRequest(ctx, "CompleteMultipartUpload", aws.ToString(in.Bucket))
+	out, err := c.API.CompleteMultipartUpload(ctx, in, optFns...)
+	end(err)
+	return out, err
+}
+
+func (c *countingClient) AbortMultipartUpload(ctx context.Context, in *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	c.del.Add(1)
+	ctx, end := startRequest(ctx, "AbortMultipartUpload", aws.ToString(in.Bucket))
+	out, err := c.API.AbortMultipartUpload(ctx, in, optFns...)
+	end(err)
+	return out, err
+}
+
+func (c *countingClient) DeleteObject(ctx context.Context, in *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	c.del.Add(1)
+	ctx, end := startRequest(ctx, "DeleteObject", aws.ToString(in.Bucket))
+	out, err := c.API.DeleteObject(ctx, in, optFns...)
+	end(err)
+	return out, err
+}
+
+func (c *countingClient) DeleteObjects(ctx context.Context, in *s3.DeleteObjectsInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	c.del.Add(1)
+	ctx, end := startRequest(ctx, "DeleteObjects", aws.ToString(in.Bucket))
+	out, err := c.API.DeleteObjects(ctx, in, optFns...)
+	end(err)
+	return out, err
+}
+
+func (c *countingClient) GetBucketReplication(ctx context.Context, in *s3.GetBucketReplicationInput, optFns ...func(*s3.Options)) (*s3.GetBucketReplicationOutput, error) {
+	c.get.Add(1)
+	ctx, end := startRequest(ctx, "GetBucketReplication", aws.ToString(in.Bucket))
+	out, err := c.API.GetBucketReplication(ctx, in, optFns...)
+	end(err)
+	return out, err
+}
@@ -0,0 +1,784 @@
+// Package s3fake is an in-memory stand-in for the S3 operations s3-tidy uses,
+// so scans and restores can be exercised without a bucket or credentials.
+package s3fake
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aslinger/s3-tidy/pkg/restore"
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+var (
+	_ scanner.API           = (*Client)(nil)
+	_ restore.API           = (*Client)(nil)
+	_ scanner.VersionLister = (*Client)(nil)
+	_ scanner.ObjectTagger  = (*Client)(nil)
+)
+
+// errNoTags is what directory buckets answer to anything involving tags.
+var errNoTags = &smithy.GenericAPIError{Code: "NotImplemented", Message: "Object tagging is not supported for directory buckets"}
+
+// Object is one stored object.
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	ETag         string
+	StorageClass types.StorageClass
+	Metadata     map[string]string
+	Tags         map[string]string
+	// Restore is the raw x-amz-restore header, set by RestoreObject.
+	Restore string
+	// Body is what GetObject returns; Put sets Size from it when Size is zero.
+	Body []byte
+	// ReplicationStatus is what HeadObject reports, e.g. PENDING.
+	ReplicationStatus types.ReplicationStatus
+	// VersionID identifies this version of the key; Put, PutNoncurrent and
+	// PutDeleteMarker assign one when it is empty.
+	VersionID string
+}
+
+type upload struct {
+	bucket, key string
+	obj         Object
+	parts       map[int32]int64 // part number -> size
+}
+
+// Client holds objects per bucket. The zero value is ready to use and safe for
+// concurrent calls.
+type Client struct {
+	// PageSize caps keys per ListObjectsV2 page; 0 means the API's 1000.
+	PageSize int
+	// FailDelete, when set, makes DeleteObject(s) fail for matching keys.
+	FailDelete func(key string) bool
+	// FailHead, when set, is asked before each HeadObject; a non-nil error
+	// is returned instead, e.g. a KMS error for an object whose key is denied.
+	FailHead func(key string) error
+
+	mu          sync.Mutex
+	buckets     map[string]map[string]Object
+	replication map[string]bool
+	uploads     map[string]*upload
+	calls       map[string]int
+	// versions holds each key's noncurrent versions and delete markers,
+	// newest first, behind the current object in buckets (if any).
+	versions map[string]map[string][]version
+	// lastVersion numbers the version IDs handed out.
+	lastVersion int
+}
+
+// version is a noncurrent version or a delete marker of a versioned key.
+type version struct {
+	obj    Object
+	marker bool
+}
+
+// AddBucket creates an empty bucket if it doesn't exist yet.
+func (c *Client) AddBucket(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.buckets == nil {
+		c.buckets = make(map[string]map[string]Object)
+	}
+	if c.buckets[name] == nil {
+		c.buckets[name] = make(map[string]Object)
+	}
+}
+
+// SetReplication says whether a bucket has a replication configuration.
+func (c *Client) SetReplication(bucket string, on bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.replication == nil {
+		c.replication = make(map[string]bool)
+	}
+	c.replication[bucket] = on
+}
+
+// GetBucketReplication returns a one-rule configuration for buckets set up
+// with SetReplication, and ReplicationConfigurationNotFoundError otherwise.
+func (c *Client) GetBucketReplication(ctx context.Context, in *s3.GetBucketReplicationInput, _ ...func(*s3.Options)) (*s3.GetBucketReplicationOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record("GetBucketReplication")
+	if _, err := c.bucket(aws.ToString(in.Bucket)); err != nil {
+		return nil, err
+	}
+	if !c.replication[aws.ToString(in.Bucket)] {
+		return nil, &smithy.GenericAPIError{Code: "ReplicationConfigurationNotFoundError", Message: "The replication configuration was not found"}
+	}
+	return &s3.GetBucketReplicationOutput{ReplicationConfiguration: &types.ReplicationConfiguration{
+		Role:  aws.String("arn:aws:iam::123456789012:role/replication"),
+		Rules: []types.ReplicationRule{{Status: types.ReplicationRuleStatusEnabled}},
+	}}, nil
+}
+
+// Put stores an object, replacing any existing one with the same key.
+func (c *Client) Put(bucket string, obj Object) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.buckets == nil {
+		c.buckets = make(map[string]map[string]Object)
+	}
+	if c.buckets[bucket] == nil {
+		c.buckets[bucket] = make(map[string]Object)
+	}
+	if obj.Size == 0 {
+		obj.Size = int64(len(obj.Body))
+	}
+	if obj.ETag == "" {
+		sum := md5.Sum([]byte(fmt.Sprintf("%s:%d:%d", obj.Key, obj.Size, obj.LastModified.UnixNano())))
+		obj.ETag = fmt.Sprintf(`"%x"`, sum)
+	}
+	c.assignVersion(&obj)
+	c.buckets[bucket][obj.Key] = obj
+}
+
+func (c *Client) assignVersion(obj *Object) {
+	if obj.VersionID == "" {
+		c.lastVersion++
+		obj.VersionID = fmt.Sprintf("v%d", c.lastVersion)
+	}
+}
+
+// PutNoncurrent stores obj as the newest noncurrent version of its key, behind
+// the current object (if any). Only ListObjectVersions sees it.
+func (c *Client) PutNoncurrent(bucket string, obj Object) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pushVersion(bucket, version{obj: obj})
+}
+
+// PutDeleteMarker deletes key the way a versioned bucket does: its current
+// object becomes a noncurrent version behind a new delete marker.
+func (c *Client) PutDeleteMarker(bucket, key string, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if obj, ok := c.buckets[bucket][key]; ok {
+		delete(c.buckets[bucket], key)
+		c.pushVersion(bucket, version{obj: obj})
+	}
+	c.pushVersion(bucket, version{obj: Object{Key: key, LastModified: at}, marker: true})
+}
+
+func (c *Client) pushVersion(bucket string, v version) {
+	c.assignVersion(&v.obj)
+	if c.versions == nil {
+		c.versions = make(map[string]map[string][]version)
+	}
+	if c.versions[bucket] == nil {
+		c.versions[bucket] = make(map[string][]version)
+	}
+	c.versions[bucket][v.obj.Key] = append([]version{v}, c.versions[bucket][v.obj.Key]...)
+}
+
+// Keys returns the keys left in bucket, sorted.
+func (c *Client) Keys(bucket string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sortedKeys(bucket)
+}
+
+// Calls reports how often an operation (e.g. "DeleteObjects") was invoked.
+func (c *Client) Calls(op string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[op]
+}
+
+func (c *Client) record(op string) {
+	if c.calls == nil {
+		c.calls = make(map[string]int)
+	}
+	c.calls[op]++
+}
+
+func (c *Client) sortedKeys(bucket string) []string {
+	keys := make([]string, 0, len(c.buckets[bucket]))
+	for k := range c.buckets[bucket] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *Client) bucket(name string) (map[string]Object, error) {
+	b, ok := c.buckets[name]
+	if !ok {
+		return nil, &smithy.GenericAPIError{Code: "NoSuchBucket", Message: "The specified bucket does not exist"}
+	}
+	return b, nil
+}
+
+// ListObjectsV2 pages through keys in lexicographic order, honouring Prefix,
+// Delimiter, StartAfter, MaxKeys and ContinuationToken like S3 does.
+func (c *Client) ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record("ListObjectsV2")
+	b, err := c.bucket(aws.ToString(in.Bucket))
+	if err != nil {
+		return nil, err
+	}
+
+	limit := 1000
+	if c.PageSize > 0 {
+		limit = c.PageSize
+	}
+	if in.MaxKeys != nil && int(*in.MaxKeys) < limit {
+		limit = int(*in.MaxKeys)
+	}
+	prefix, delim := aws.ToString(in.Prefix), aws.ToString(in.Delimiter)
+	after := aws.ToString(in.StartAfter)
+	if tok := aws.ToString(in.ContinuationToken); tok != "" {
+		after = tok
+	}
+
+	out := &s3.ListObjectsV2Output{Name: in.Bucket, Prefix: in.Prefix}
+	seenPrefix := make(map[string]bool)
+	count := 0
+	for _, k := range c.sortedKeys(aws.ToString(in.Bucket)) {
+		if k <= after || !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		// Keys under an already-returned common prefix are rolled up into it.
+		var cp string
+		if delim != "" {
+			if i := strings.Index(k[len(prefix):], delim); i >= 0 {
+				cp = k[:len(prefix)+i+len(delim)]
+			}
+		}
+		if cp != "" && seenPrefix[cp] {
+			after = k
+			continue
+		}
+		if count == limit {
+			out.IsTruncated = aws.Bool(true)
+			out.NextContinuationToken = aws.String(after)
+			break
+		}
+		after = k
+		count++
+		if cp != "" {
+			seenPrefix[cp] = true
+			out.CommonPrefixes = append(out.CommonPrefixes, types.CommonPrefix{Prefix: aws.String(cp)})
+			continue
+		}
+		obj := b[k]
+		out.Contents = append(out.Contents, types.Object{
+			Key:          aws.String(obj.Key),
+			Size:         aws.Int64(obj.Size),
+			LastModified: aws.Time(obj.LastModified),
+			ETag:         aws.String(obj.ETag),
+			StorageClass: types.ObjectStorageClass(obj.storageClass()),
+		})
+	}
+	out.KeyCount = aws.Int32(int32(count))
+	return out, nil
+}
+
+// ListObjectVersions pages through every version and delete marker in key
+// order, newest first within a key, honouring Prefix, KeyMarker,
+// VersionIdMarker and MaxKeys.
+func (c *Client) ListObjectVersions(ctx context.Context, in *s3.ListObjectVersionsInput, _ ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record("ListObjectVersions")
+	b, err := c.bucket(aws.ToString(in.Bucket))
+	if err != nil {
+		return nil, err
+	}
+
+	limit := 1000
+	if c.PageSize > 0 {
+		limit = c.PageSize
+	}
+	if in.MaxKeys != nil && int(*in.MaxKeys) < limit {
+		limit = int(*in.MaxKeys)
+	}
+	prefix := aws.ToString(in.Prefix)
+	keyMarker, idMarker := aws.ToString(in.KeyMarker), aws.ToString(in.VersionIdMarker)
+
+	history := c.versions[aws.ToString(in.Bucket)]
+	keys := c.sortedKeys(aws.ToString(in.Bucket))
+	for k := range history {
+		if _, ok := b[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	out := &s3.ListObjectVersionsOutput{Name: in.Bucket, Prefix: in.Prefix}
+	count := 0
+	// skipping is set while passing the entries up to VersionIdMarker.
+	skipping := idMarker != ""
+	for _, k := range keys {
+		if !strings.HasPrefix(k, prefix) || k < keyMarker || (k == keyMarker && !skipping) {
+			continue
+		}
+		var entries []version
+		if obj, ok := b[k]; ok {
+			entries = append(entries, version{obj: obj})
+		}
+		entries = append(entries, history[k]...)
+		for i, v := range entries {
+			id := v.obj.VersionID
+			if skipping && k == keyMarker {
+				if id == idMarker {
+					skipping = false
+				}
+				continue
+			}
+			if count == limit {
+				out.IsTruncated = aws.Bool(true)
+				return out, nil
+			}
+			count++
+			out.NextKeyMarker, out.NextVersionIdMarker = aws.String(k), aws.String(id)
+			latest := aws.Bool(i == 0)
+			if v.marker {
+				out.DeleteMarkers = append(out.DeleteMarkers, types.DeleteMarkerEntry{Key: aws.String(k), VersionId: aws.String(id), IsLatest: latest, LastModified: aws.Time(v.obj.LastModified)})
+				continue
+			}
+			out.Versions = append(out.Versions, types.ObjectVersion{
+				Key:          aws.String(k),
+				VersionId:    aws.String(id),
+				IsLatest:     latest,
+				Size:         aws.Int64(v.obj.Size),
+				LastModified: aws.Time(v.obj.LastModified),
+				ETag:         aws.String(v.obj.ETag),
+				StorageClass: types.ObjectVersionStorageClass(v.obj.storageClass()),
+			})
+		}
+		skipping = false
+	}
+	out.NextKeyMarker, out.NextVersionIdMarker = nil, nil
+	return out, nil
+}
+
+// HeadObject returns an object's metadata, or a NotFound error.
+func (c *Client) HeadObject(ctx context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record("HeadObject")
+	b, err := c.bucket(aws.ToString(in.Bucket))
+	if err != nil {
+		return nil, err
+	}
+	if c.FailHead != nil {
+		if err := c.FailHead(aws.ToString(in.Key)); err != nil {
+			return nil, err
+		}
+	}
+	obj, ok := b[aws.ToString(in.Key)]
+	if id := aws.ToString(in.VersionId); id != "" {
+		var marker bool
+		obj, marker, ok = c.findVersion(aws.ToString(in.Bucket), aws.ToString(in.Key), id)
+		if marker {
+			return nil, &smithy.GenericAPIError{Code: "MethodNotAllowed", Message: "The specified method is not allowed against this resource."}
+		}
+	}
+	if !ok {
+		return nil, &types.NotFound{Message: aws.String("Not Found")}
+	}
+	return &s3.HeadObjectOutput{
+		VersionId:     optional(obj.VersionID),
+		ContentLength: aws.Int64(obj.Size),
+		LastModified:  aws.Time(obj.LastModified),
+		ETag:          aws.String(obj.ETag),
+		StorageClass:  obj.storageClass(),
+		Metadata:      obj.Metadata,
+		Restore:       optional(obj.Restore),
+
+		ReplicationStatus: obj.ReplicationStatus,
+	}, nil
+}
+
+// GetObject returns an object's Body, or a NoSuchKey error.
+func (c *Client) GetObject(ctx context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record("GetObject")
+	b, err := c.bucket(aws.ToString(in.Bucket))
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := b[aws.ToString(in.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{Message: aws.String("The specified key does not exist.")}
+	}
+	return &s3.GetObjectOutput{
+		Body:          io.NopCloser(bytes.NewReader(obj.Body)),
+		ContentLength: aws.Int64(obj.Size),
+		LastModified:  aws.Time(obj.LastModified),
+		ETag:          aws.String(obj.ETag),
+		StorageClass:  obj.storageClass(),
+	}, nil
+}
+
+// CopyObject copies an object between (or within) buckets. The copy keeps the
+// source's ETag, as a single-part S3 copy does.
+func (c *Client) CopyObject(ctx context.Context, in *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record("CopyObject")
+	obj, err := c.source(aws.ToString(in.CopySource))
+	if err != nil {
+		return nil, err
+	}
+	dst, err := c.bucket(aws.ToString(in.Bucket))
+	if err != nil {
+		return nil, err
+	}
+	if obj.Size > 5<<30 {
+		return nil, &smithy.GenericAPIError{Code: "InvalidRequest", Message: "The specified copy source is larger than the maximum allowable size for a copy source: 5368709120"}
+	}
+	if in.TaggingDirective != "" && (scanner.IsDirectoryBucket(aws.ToString(in.Bucket)) || strings.Contains(aws.ToString(in.CopySource), "--x-s3/")) {
+		return nil, errNoTags
+	}
+	obj.Key = aws.ToString(in.Key)
+	obj.LastModified = time.Now()
+	obj.StorageClass = in.StorageClass
+	dst[obj.Key] = obj
+	return &s3.CopyObjectOutput{CopyObjectResult: &types.CopyObjectResult{ETag: aws.String(obj.ETag), LastModified: aws.Time(obj.LastModified)}}, nil
+}
+
+// DeleteObject removes one key, or with VersionId one version of it. Like S3,
+// deleting a missing key succeeds unless IfMatch is set, which fails with
+// NoSuchKey, or PreconditionFailed when the current ETag differs.
+func (c *Client) DeleteObject(ctx context.Context, in *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record("DeleteObject")
+	b, err := c.bucket(aws.ToString(in.Bucket))
+	if err != nil {
+		return nil, err
+	}
+	key := aws.ToString(in.Key)
+	if c.FailDelete != nil && c.FailDelete(key) {
+		return nil, &smithy.GenericAPIError{Code: "AccessDenied", Message: "Access Denied"}
+	}
+	if code, msg := precondition(b, key, in.IfMatch); code != "" {
+		return nil, &smithy.GenericAPIError{Code: code, Message: msg}
+	}
+	c.remove(aws.ToString(in.Bucket), key, aws.ToString(in.VersionId))
+	return &s3.DeleteObjectOutput{VersionId: in.VersionId}, nil
+}
+
+// precondition checks an If-Match ETag against key, returning the error code
+// S3 answers a failed check with, or "".
+func precondition(b map[string]Object, key string, etag *string) (code, msg string) {
+	if etag == nil {
+		return "", ""
+	}
+	obj, ok := b[key]
+	switch {
+	case !ok:
+		return "NoSuchKey", "The specified key does not exist."
+	case obj.ETag != *etag:
+		return "PreconditionFailed", "At least one of the pre-conditions you specified did not hold"
+	}
+	return "", ""
+}
+
+// findVersion looks up one version of key: the current object or one in its
+// history. "null", the version of objects written while versioning was off,
+// is the current object of a key without history.
+func (c *Client) findVersion(bucket, key, id string) (obj Object, marker, ok bool) {
+	history := c.versions[bucket][key]
+	if cur, found := c.buckets[bucket][key]; found && (cur.VersionID == id || id == "null" && len(history) == 0) {
+		return cur, false, true
+	}
+	for _, v := range history {
+		if v.obj.VersionID == id {
+			return v.obj, v.marker, true
+		}
+	}
+	return Object{}, false, false
+}
+
+// remove deletes key, or with a version ID that version for good. As in S3,
+// removing the current version (or the delete marker in front of the key)
+// makes the next newest version current. A missing version is no error.
+func (c *Client) remove(bucket, key, id string) {
+	b := c.buckets[bucket]
+	if id == "" {
+		delete(b, key)
+		return
+	}
+	history := c.versions[bucket][key]
+	if cur, ok := b[key]; ok && (cur.VersionID == id || id == "null" && len(history) == 0) {
+		delete(b, key)
+	} else {
+		i := 0
+		for i < len(history) && history[i].obj.VersionID != id {
+			i++
+		}
+		if i == len(history) {
+			return
+		}
+		if _, ok := b[key]; ok || i > 0 {
+			c.versions[bucket][key] = append(history[:i:i], history[i+1:]...)
+			return
+		}
+		history = history[1:]
+	}
+	// The key has no current version now: promote the newest in its history
+	// unless that is a delete marker.
+	if len(history) > 0 && !history[0].marker {
+		b[key] = history[0].obj
+		history = history[1:]
+	}
+	if len(history) > 0 {
+		c.versions[bucket][key] = history
+	} else {
+		delete(c.versions[bucket], key)
+	}
+}
+
+// DeleteObjects removes up to 1000 keys, reporting per-key failures the way
+// S3 does: in Errors, with a successful response.
+func (c *Client) DeleteObjects(ctx context.Context, in *s3.DeleteObjectsInput, _ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record("DeleteObjects")
+	b, err := c.bucket(aws.ToString(in.Bucket))
+	if err != nil {
+		return nil, err
+	}
+	if in.Delete == nil || len(in.Delete.Objects) > 1000 {
+		return nil, &smithy.GenericAPIError{Code: "MalformedXML", Message: "The XML you provided was not well-formed"}
+	}
+
+	out := &s3.DeleteObjectsOutput{}
+	quiet := aws.ToBool(in.Delete.Quiet)
+	for _, id := range in.Delete.Objects {
+		key := aws.ToString(id.Key)
+		if c.FailDelete != nil && c.FailDelete(key) {
+			out.Errors = append(out.Errors, types.Error{Key: id.Key, Code: aws.String("AccessDenied"), Message: aws.String("Access Denied")})
+			continue
+		}
+		if code, msg := precondition(b, key, id.ETag); code != "" {
+			out.Errors = append(out.Errors, types.Error{Key: id.Key, VersionId: id.VersionId, Code: aws.String(code), Message: aws.String(msg)})
+			continue
+		}
+		c.remove(aws.ToString(in.Bucket), key, aws.ToString(id.VersionId))
+		if !quiet {
+			out.Deleted = append(out.Deleted, types.DeletedObject{Key: id.Key, VersionId: id.VersionId})
+		}
+	}
+	return out, nil
+}
+
+func (o Object) storageClass() types.StorageClass {
+	if o.StorageClass == "" {
+		return types.StorageClassStandard
+	}
+	return o.StorageClass
+}
+
+func (c *Client) source(copySource string) (Object, error) {
+	srcBucket, srcKey, ok := strings.Cut(copySource, "/")
+	if !ok {
+		return Object{}, &smithy.GenericAPIError{Code: "InvalidArgument", Message: "Invalid copy source"}
+	}
+	if k, err := url.PathUnescape(srcKey); err == nil {
+		srcKey = k
+	}
+	src, err := c.bucket(srcBucket)
+	if err != nil {
+		return Object{}, err
+	}
+	obj, ok := src[srcKey]
+	if !ok {
+		return Object{}, &types.NoSuchKey{Message: aws.String("The specified key does not exist.")}
+	}
+	return obj, nil
+}
+
+// GetObjectTagging returns an object's tags.
+func (c *Client) GetObjectTagging(ctx context.Context, in *s3.GetObjectTaggingInput, _ ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record("GetObjectTagging")
+	if scanner.IsDirectoryBucket(aws.ToString(in.Bucket)) {
+		return nil, errNoTags
+	}
+	b, err := c.bucket(aws.ToString(in.Bucket))
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := b[aws.ToString(in.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{Message: aws.String("The specified key does not exist.")}
+	}
+	out := &s3.GetObjectTaggingOutput{}
+	for k, v := range obj.Tags {
+		out.TagSet = append(out.TagSet, types.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	sort.Slice(out.TagSet, func(i, j int) bool { return *out.TagSet[i].Key < *out.TagSet[j].Key })
+	return out, nil
+}
+
+// PutObjectTagging replaces an object's tags.
+func (c *Client) PutObjectTagging(ctx context.Context, in *s3.PutObjectTaggingInput, _ ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record("PutObjectTagging")
+	if scanner.IsDirectoryBucket(aws.ToString(in.Bucket)) {
+		return nil, errNoTags
+	}
+	b, err := c.bucket(aws.ToString(in.Bucket))
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := b[aws.ToString(in.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{Message: aws.String("The specified key does not exist.")}
+	}
+	obj.Tags = make(map[string]string)
+	if in.Tagging != nil {
+		for _, t := range in.Tagging.TagSet {
+			obj.Tags[aws.ToString(t.Key)] = aws.ToString(t.Value)
+		}
+	}
+	b[obj.Key] = obj
+	return &s3.PutObjectTaggingOutput{}, nil
+}
+
+// CreateMultipartUpload starts an upload that UploadPartCopy fills in.
+func (c *Client) CreateMultipartUpload(ctx context.Context, in *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record("CreateMultipartUpload")
+	if _, err := c.bucket(aws.ToString(in.Bucket)); err != nil {
+		return nil, err
+	}
+	if c.uploads == nil {
+		c.uploads = make(map[string]*upload)
+	}
+	id := fmt.Sprintf("upload-%d", len(c.uploads)+1)
+	obj := Object{Key: aws.ToString(in.Key), StorageClass: in.StorageClass, Metadata: in.Metadata}
+	if in.Tagging != nil {
+		q, err := url.ParseQuery(*in.Tagging)
+		if err != nil {
+			return nil, &smithy.GenericAPIError{Code: "InvalidArgument", Message: "Invalid tag"}
+		}
+		obj.Tags = make(map[string]string, len(q))
+		for k := range q {
+			obj.Tags[k] = q.Get(k)
+		}
+	}
+	c.uploads[id] = &upload{bucket: aws.ToString(in.Bucket), key: obj.Key, obj: obj, parts: make(map[int32]int64)}
+	return &s3.CreateMultipartUploadOutput{Bucket: in.Bucket, Key: in.Key, UploadId: aws.String(id)}, nil
+}
+
+// UploadPartCopy records a byte range of the source as one part.
+func (c *Client) UploadPartCopy(ctx context.Context, in *s3.UploadPartCopyInput, _ ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record("UploadPartCopy")
+	up, ok := c.uploads[aws.ToString(in.UploadId)]
+	if !ok {
+		return nil, &smithy.GenericAPIError{Code: "NoSuchUpload", Message: "The specified upload does not exist."}
+	}
+	src, err := c.source(aws.ToString(in.CopySource))
+	if err != nil {
+		return nil, err
+	}
+	var start, end int64
+	if _, err := fmt.Sscanf(aws.ToString(in.CopySourceRange), "bytes=%d-%d", &start, &end); err != nil || start > end || end >= src.Size {
+		return nil, &smithy.GenericAPIError{Code: "InvalidArgument", Message: "Invalid copy source range"}
+	}
+	if end-start+1 > 5<<30 {
+		return nil, &smithy.GenericAPIError{Code: "EntityTooLarge", Message: "Part exceeds the maximum allowed size"}
+	}
+	up.parts[aws.ToInt32(in.PartNumber)] = end - start + 1
+	etag := fmt.Sprintf(`"part-%d"`, aws.ToInt32(in.PartNumber))
+	return &s3.UploadPartCopyOutput{CopyPartResult: &types.CopyPartResult{ETag: aws.String(etag)}}, nil
+}
+
+// CompleteMultipartUpload assembles the listed parts into the object.
+func (c *Client) CompleteMultipartUpload(ctx context.Context, in *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record("CompleteMultipartUpload")
+	id := aws.ToString(in.UploadId)
+	up, ok := c.uploads[id]
+	if !ok || in.MultipartUpload == nil {
+		return nil, &smithy.GenericAPIError{Code: "NoSuchUpload", Message: "The specified upload does not exist."}
+	}
+	obj := up.obj
+	for _, p := range in.MultipartUpload.Parts {
+		size, ok := up.parts[aws.ToInt32(p.PartNumber)]
+		if !ok {
+			return nil, &smithy.GenericAPIError{Code: "InvalidPart", Message: "One or more of the specified parts could not be found."}
+		}
+		obj.Size += size
+	}
+	obj.LastModified = time.Now()
+	obj.ETag = fmt.Sprintf(`"%x-%d"`, md5.Sum([]byte(id)), len(in.MultipartUpload.Parts))
+	c.buckets[up.bucket][up.key] = obj
+	delete(c.uploads, id)
+	return &s3.CompleteMultipartUploadOutput{Bucket: in.Bucket, Key: in.Key, ETag: aws.String(obj.ETag)}, nil
+}
+
+// AbortMultipartUpload discards an upload and its parts.
+func (c *Client) AbortMultipartUpload(ctx context.Context, in *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record("AbortMultipartUpload")
+	delete(c.uploads, aws.ToString(in.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+// RestoreObject starts a restore of an archived object. The fake never
+// finishes one by itself; set Object.Restore to simulate completion.
+func (c *Client) RestoreObject(ctx context.Context, in *s3.RestoreObjectInput, _ ...func(*s3.Options)) (*s3.RestoreObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record("RestoreObject")
+	b, err := c.bucket(aws.ToString(in.Bucket))
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := b[aws.ToString(in.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{Message: aws.String("The specified key does not exist.")}
+	}
+	switch obj.storageClass() {
+	case types.StorageClassGlacier, types.StorageClassDeepArchive:
+	default:
+		return nil, &types.InvalidObjectState{Message: aws.String("Restore is not allowed for the object's current storage class")}
+	}
+	if strings.Contains(obj.Restore, `ongoing-request="true"`) {
+		return nil, &smithy.GenericAPIError{Code: "RestoreAlreadyInProgress", Message: "Object restore is already in progress"}
+	}
+	// Requesting an already restored object only extends its expiry.
+	if obj.Restore == "" {
+		obj.Restore = `ongoing-request="true"`
+		b[obj.Key] = obj
+	}
+	return &s3.RestoreObjectOutput{}, nil
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,123 @@
+package s3fake
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+var at = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func errorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
+
+func TestDeleteObjectIfMatch(t *testing.T) {
+	ctx := context.Background()
+	c := &Client{}
+	c.Put("b", Object{Key: "k", Size: 1, LastModified: at})
+	head, err := c.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("b"), Key: aws.String("k")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("b"), Key: aws.String("k"), IfMatch: aws.String(`"other"`)})
+	if code := errorCode(err); code != "PreconditionFailed" {
+		t.Errorf("mismatched If-Match: error %v, want PreconditionFailed", err)
+	}
+	if len(c.Keys("b")) != 1 {
+		t.Fatal("mismatched If-Match deleted the object")
+	}
+	if _, err := c.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("b"), Key: aws.String("k"), IfMatch: head.ETag}); err != nil {
+		t.Fatalf("matching If-Match: %v", err)
+	}
+	if len(c.Keys("b")) != 0 {
+		t.Fatal("matching If-Match left the object")
+	}
+	_, err = c.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("b"), Key: aws.String("k"), IfMatch: head.ETag})
+	if code := errorCode(err); code != "NoSuchKey" {
+		t.Errorf("If-Match on a missing key: error %v, want NoSuchKey", err)
+	}
+	if _, err := c.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("b"), Key: aws.String("k")}); err != nil {
+		t.Errorf("unconditional delete of a missing key: %v", err)
+	}
+}
+
+func TestDeleteVersion(t *testing.T) {
+	ctx := context.Background()
+	c := &Client{}
+	c.PutNoncurrent("b", Object{Key: "k", Size: 1, LastModified: at, VersionID: "old"})
+	c.Put("b", Object{Key: "k", Size: 2, LastModified: at.Add(time.Hour), VersionID: "new"})
+
+	del := func(id string) {
+		t.Helper()
+		if _, err := c.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("b"), Key: aws.String("k"), VersionId: aws.String(id)}); err != nil {
+			t.Fatalf("delete version %s: %v", id, err)
+		}
+	}
+	size := func() int64 {
+		t.Helper()
+		head, err := c.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("b"), Key: aws.String("k")})
+		if err != nil {
+			t.Fatalf("head: %v", err)
+		}
+		return aws.ToInt64(head.ContentLength)
+	}
+
+	del("missing")
+	if got := size(); got != 2 {
+		t.Fatalf("deleting an unknown version changed the object: size %d", got)
+	}
+	del("new")
+	if got := size(); got != 1 {
+		t.Fatalf("after deleting the current version, size %d, want the older version's 1", got)
+	}
+	del("old")
+	if got := c.Keys("b"); len(got) != 0 {
+		t.Fatalf("keys left = %v, want none", got)
+	}
+}
+
+func TestDeleteObjectsVersions(t *testing.T) {
+	ctx := context.Background()
+	c := &Client{}
+	c.PutNoncurrent("b", Object{Key: "k", Size: 1, LastModified: at, VersionID: "old"})
+	c.Put("b", Object{Key: "k", Size: 2, LastModified: at.Add(time.Hour), VersionID: "new"})
+
+	out, err := c.DeleteObjects(ctx, &s3.DeleteObjectsInput{Bucket: aws.String("b"), Delete: &types.Delete{Objects: []types.ObjectIdentifier{
+		{Key: aws.String("k"), VersionId: aws.String("old")},
+	}}})
+	if err != nil || len(out.Errors) > 0 {
+		t.Fatalf("DeleteObjects: %v %v", err, out.Errors)
+	}
+	if _, err := c.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("b"), Key: aws.String("k"), VersionId: aws.String("new")}); err != nil {
+		t.Errorf("current version gone after deleting the noncurrent one: %v", err)
+	}
+	var notFound *types.NotFound
+	if _, err := c.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("b"), Key: aws.String("k"), VersionId: aws.String("old")}); !errors.As(err, &notFound) {
+		t.Errorf("head of the deleted version: %v, want NotFound", err)
+	}
+
+	versions, err := c.ListObjectVersions(ctx, &s3.ListObjectVersionsInput{Bucket: aws.String("b")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, v := range versions.Versions {
+		ids = append(ids, aws.ToString(v.VersionId))
+	}
+	if want := []string{"new"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("versions left = %v, want %v", ids, want)
+	}
+}
@@ -0,0 +1,894 @@
+// Package scanner lists a bucket, applies a resolved policy and deletes (or
+// reports on) the stale objects it finds.
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aslinger/s3-tidy/pkg/accesslog"
+	"github.com/aslinger/s3-tidy/pkg/cost"
+	"github.com/aslinger/s3-tidy/pkg/policy"
+	"github.com/aslinger/s3-tidy/pkg/tiering"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Options carries everything a single scan needs, so new settings don't keep
+// widening the Run signature. The embedded Selection decides what is stale.
+type Options struct {
+	Name   string // policy name, used to label results; defaults to the bucket
+	Bucket string
+	DryRun bool
+	Report bool
+	policy.Selection
+
+	// Action is what happens to stale objects outside dry runs and reports:
+	// they are deleted (the default), tagged for review, or deleted only once
+	// a tag from an earlier tagging run has expired. ExpiryTag is the tag key
+	// (empty means DefaultExpiryTag) and ExpireAfter the date ActionTag
+	// writes into it.
+	Action      Action
+	ExpiryTag   string
+	ExpireAfter time.Time
+
+	// ArchiveBucket, when set, receives a verified copy of every object before
+	// it is deleted; objects that fail to archive are left in place.
+	ArchiveBucket       string
+	ArchiveStorageClass string // storage class of the archived copies; empty means STANDARD
+
+	// Review, when set, receives every stale object once listing is done and
+	// returns the subset to act on (the CLI's TUI). It is the one option that
+	// holds a whole run's findings in memory.
+	Review func(ctx context.Context, stale []policy.Candidate) ([]policy.Candidate, error)
+	// Confirm, when set, wraps the scanner's act and flush steps in a Confirmer
+	// that streams stale objects through an approval step instead.
+	Confirm func(act func(policy.Candidate), flush func()) Confirmer
+	// TargetSavings, when positive, acts only on the stale objects that save
+	// the most (USD per month) until this much is reached, largest and then
+	// oldest first. They are held back until listing is done; the rest are
+	// left for a later run.
+	TargetSavings float64
+	// LargestFirst holds stale objects back until listing is done and acts on
+	// them in descending size order, so an interrupted or capped run has
+	// already reclaimed the most space. It holds every stale object in memory
+	// unless MaxDelete bounds it.
+	LargestFirst bool
+	// MaxDelete, when positive, caps how many stale objects a run acts on: the
+	// largest ones with LargestFirst, otherwise the first ones listed.
+	MaxDelete int
+	// MaxObjects and MaxDuration, when positive, stop the listing after
+	// examining that many objects or once that much time has passed. What was
+	// found so far is still acted on and reported, and Result.ResumeAfter
+	// says where to pick up. Planners need the whole listing, so a scan using
+	// one can't be capped.
+	MaxObjects  int
+	MaxDuration time.Duration
+	// StartAfter resumes a capped scan past this key.
+	StartAfter string
+	// CheckpointFile, when set, carries the resume point from one capped run
+	// to the next: a run starts after the key in it, rewrites it when it
+	// stops early and removes it once it lists the bucket to its end.
+	CheckpointFile string
+	// Versions lists the bucket with ListObjectVersions instead of ListObjectsV2, as a single
+	// stream: each key is aged by its newest version, and keys whose current
+	// version is a delete marker are reported (never deleted) with the bytes
+	// of the noncurrent versions behind them.
+	Versions bool
+
+	Out            io.Writer       // progress and summary output; nil means stdout
+	BatchSize      int             // keys per DeleteObjects call; 0 means the API maximum
+	BatchNotifiers []BatchNotifier // told about every deletion batch as it completes
+	PreDeleteHooks []PreDeleteHook // run before every deletion batch; any error skips the batch
+	Sinks          []Sink          // receive every finding as it is produced, after the console
+	Verify         Verify          // how deletes detect objects rewritten since listing; empty means VerifyETag
+	Pricing        cost.Pricing    // storage rates and display currency; the zero value means list prices in USD
+	// AccessLog, when enabled, is read before listing; objects read since its
+	// Since time are kept however old they are.
+	AccessLog accesslog.Source
+	// Tiering, when set, sees every listed object and its recommendations end
+	// up in Result.Tiering.
+	Tiering *tiering.Analyzer
+	// ListConcurrency is how many top-level prefixes are listed in parallel;
+	// 0 means DefaultListConcurrency and 1 lists the bucket as a single stream.
+	ListConcurrency int
+	// SSECustomerKey is the 32-byte key of SSE-C encrypted objects, sent
+	// only when S3 asks for one. Without it such objects are skipped.
+	SSECustomerKey []byte
+	// RedactKeys hides object keys from progress lines, warnings, tiering
+	// prefixes and the console sink; opts.Sinks are the caller's to wrap.
+	RedactKeys Redaction
+}
+
+// Confirmer gates stale objects as they stream past, acting only on the ones
+// an operator approves.
+type Confirmer interface {
+	Add(c policy.Candidate)
+	// Finish settles whatever is still buffered once listing is done.
+	Finish()
+}
+
+// BatchNotifier is told about every deletion batch as it completes, so
+// downstream systems can react without waiting for the whole run.
+type BatchNotifier interface {
+	NotifyBatch(ctx context.Context, res *Result, b DeletionBatch) error
+}
+
+// PreDeleteHook sees every deletion batch before it is sent, e.g. to snapshot
+// references elsewhere. Returning an error leaves the batch's objects in place.
+type PreDeleteHook interface {
+	BeforeBatch(ctx context.Context, m BatchManifest) error
+}
+
+// Result summarises one run. It is what the daemon records as run history.
+type Result struct {
+	Policy   string    `json:"policy"`
+	Bucket   string    `json:"bucket"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	DryRun   bool      `json:"dry_run"`
+	Report   bool      `json:"report"`
+
+	Scanned  int `json:"objects_scanned"`
+	Stale    int `json:"objects_stale"`
+	Deleted  int `json:"objects_deleted"`
+	Excluded int `json:"objects_excluded"`
+	Retained int `json:"objects_retained"`
+	Undated  int `json:"objects_undated"`
+	Kept     int `json:"objects_kept"`
+	Filtered int `json:"objects_filtered"`
+	Archived int `json:"objects_archived"`
+	// ModifiedSinceScan counts objects left alone because they were rewritten
+	// between listing and deletion.
+	ModifiedSinceScan int `json:"objects_modified_since_scan"`
+	// RecentlyRead counts old objects kept because the access logs show a read.
+	RecentlyRead int `json:"objects_recently_read"`
+	// Deferred counts stale objects left for a later run by TargetSavings or
+	// MaxDelete.
+	Deferred int `json:"objects_deferred"`
+	// Unreplicated counts stale objects skipped because replication hasn't
+	// copied them yet (PENDING) or gave up (FAILED).
+	Unreplicated int `json:"objects_unreplicated"`
+	// Encrypted counts stale objects skipped because reading them needs an
+	// SSE-C key that wasn't supplied or a KMS key we may not use.
+	Encrypted int `json:"objects_skipped_encrypted"`
+	// Missing counts objects named by a key list that weren't in the bucket.
+	Missing int `json:"objects_missing"`
+	Errors  int `json:"errors"`
+	// Truncated is set when MaxObjects or MaxDuration stopped the listing, and
+	// ResumeAfter is then the last key examined (redacted like other keys).
+	Truncated   bool   `json:"truncated"`
+	ResumeAfter string `json:"resume_after,omitempty"`
+	// DeleteMarkers counts keys of a Versions listing whose current version
+	// is a delete marker; DeleteMarkerBytes is their noncurrent versions.
+	DeleteMarkers     int   `json:"delete_markers"`
+	DeleteMarkerBytes int64 `json:"delete_marker_noncurrent_bytes"`
+	// Tagged counts stale objects ActionTag marked for review; TagKept counts
+	// stale objects ActionDeleteTagged kept for lack of an expired tag.
+	Tagged  int `json:"objects_tagged"`
+	TagKept int `json:"objects_kept_by_tag"`
+
+	StaleBytes       int64   `json:"stale_bytes"`
+	DeletedBytes     int64   `json:"deleted_bytes"`
+	EstimatedSavings float64 `json:"estimated_monthly_savings_usd"`
+	// Currency and EstimatedSavingsLocal are the savings in the display
+	// currency; EstimatedSavings stays in USD so runs remain comparable.
+	Currency              string  `json:"currency"`
+	EstimatedSavingsLocal float64 `json:"estimated_monthly_savings"`
+
+	// Requests and RequestCost (USD) are what the run itself spent on S3 API calls.
+	Requests    RequestCounts `json:"requests"`
+	RequestCost float64       `json:"request_cost_usd"`
+
+	// Tiering lists prefixes cheaper in Intelligent-Tiering, when requested.
+	Tiering []tiering.Recommendation `json:"tiering,omitempty"`
+}
+
+// NetSavings is the first month's savings after paying for the run's own
+// requests, in USD. Later months save the full EstimatedSavings.
+func (r *Result) NetSavings() float64 {
+	return r.EstimatedSavings - r.RequestCost
+}
+
+// Scanner runs scans against one S3 client. It holds no per-run state, so one
+// Scanner can serve every policy of a daemon.
+type Scanner struct {
+	client API
+	sse    *customerKey // a run's SSE-C key, set on its per-run copy
+}
+
+// New returns a Scanner using client for every request.
+func New(client API) *Scanner {
+	return &Scanner{client: client}
+}
+
+// NewFromConfig builds a Scanner on a real S3 client.
+func NewFromConfig(cfg aws.Config) *Scanner {
+	return New(s3.NewFromConfig(cfg))
+}
+
+// Run executes one scan and prints progress and the summary to opts.Out.
+// Errors are returned rather than fatal so long-running callers survive them.
+// The scan is traced as a "scan" span around its requests and batches.
+func (s *Scanner) Run(ctx context.Context, opts Options) (*Result, error) {
+	ctx, span := startRun(ctx, "scan", opts)
+	res, err := s.run(ctx, opts)
+	endRun(span, res, err)
+	return res, err
+}
+
+func (s *Scanner) run(ctx context.Context, opts Options) (*Result, error) {
+	res := &Result{
+		Policy:  opts.Name,
+		Bucket:  opts.Bucket,
+		Started: time.Now(),
+		DryRun:  opts.DryRun,
+		Report:  opts.Report,
+	}
+	if res.Policy == "" {
+		res.Policy = opts.Bucket
+	}
+	out := opts.Out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	if opts.ArchiveBucket != "" && opts.ArchiveBucket == opts.Bucket {
+		return nil, fmt.Errorf("archive bucket must differ from the scanned bucket")
+	}
+	directory := IsDirectoryBucket(opts.Bucket)
+	if IsDirectoryBucket(opts.ArchiveBucket) && opts.ArchiveStorageClass != "" && opts.ArchiveStorageClass != string(types.StorageClassExpressOnezone) {
+		return nil, fmt.Errorf("directory bucket %s only stores %s, not %s", opts.ArchiveBucket, types.StorageClassExpressOnezone, opts.ArchiveStorageClass)
+	}
+	if opts.TargetSavings < 0 {
+		return nil, fmt.Errorf("savings target must not be negative (got %g)", opts.TargetSavings)
+	}
+	if opts.MaxDelete < 0 {
+		return nil, fmt.Errorf("max delete must not be negative (got %d)", opts.MaxDelete)
+	}
+	if opts.MaxObjects < 0 || opts.MaxDuration < 0 {
+		return nil, fmt.Errorf("max objects and max duration must not be negative")
+	}
+	budget := newScanBudget(opts, res.Started)
+	resumable := budget.enabled() || opts.StartAfter != "" || opts.CheckpointFile != ""
+	switch {
+	case resumable && opts.Planner != nil:
+		return nil, fmt.Errorf("%s needs the whole listing, so its scans can't be capped or resumed", opts.Planner.Describe())
+	case resumable && directory:
+		return nil, fmt.Errorf("directory bucket %s lists keys in no particular order, so its scans can't be capped or resumed", opts.Bucket)
+	case opts.StartAfter != "" && opts.CheckpointFile != "":
+		return nil, fmt.Errorf("resume from a start key or a checkpoint file, not both")
+	case opts.Versions && directory:
+		return nil, fmt.Errorf("directory bucket %s has no object versions", opts.Bucket)
+	}
+	if err := opts.Action.Validate(); err != nil {
+		return nil, err
+	}
+	tagged := opts.Action == ActionTag || opts.Action == ActionDeleteTagged
+	expiryTag := opts.ExpiryTag
+	if expiryTag == "" {
+		expiryTag = DefaultExpiryTag
+	}
+	switch _, canTag := s.client.(ObjectTagger); {
+	case tagged && directory:
+		return nil, fmt.Errorf("directory bucket %s doesn't support object tags", opts.Bucket)
+	case opts.Action == ActionTag && opts.ArchiveBucket != "":
+		return nil, fmt.Errorf("tagging doesn't delete anything to archive; archive when deleting with the %s action", ActionDeleteTagged)
+	case opts.Action == ActionTag && opts.ExpireAfter.IsZero():
+		return nil, fmt.Errorf("tagging needs the date objects expire after")
+	case opts.Action == ActionTag && !canTag:
+		return nil, errNoTagging
+	}
+	expiry := opts.ExpireAfter.UTC().Format(expiryLayout)
+	if _, ok := s.client.(VersionLister); opts.Versions && !ok {
+		return nil, errNoVersions
+	}
+	after := opts.StartAfter
+	if opts.CheckpointFile != "" {
+		var err error
+		if after, err = readCheckpoint(opts.CheckpointFile); err != nil {
+			return nil, err
+		}
+	}
+
+	sse, err := newCustomerKey(opts.SSECustomerKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.RedactKeys.Validate(); err != nil {
+		return nil, err
+	}
+	redact := opts.RedactKeys.Key
+
+	lc, ok := s.client.(LargeCopier)
+	largeCopy := ok && lc.CopiesLargeObjects()
+
+	// Count this run's requests on a per-run copy; the Scanner itself is shared.
+	counter := &countingClient{API: s.client}
+	s = &Scanner{client: counter, sse: sse}
+
+	// 1. Announce the cutoff (resolved by the caller from --days or --before)
+	cutoff := opts.Cutoff
+	if opts.Planner != nil {
+		fmt.Fprintf(out, "🔍 Scanning 's3://%s' with %s...\n", opts.Bucket, opts.Planner.Describe())
+	} else if opts.Days > 0 {
+		fmt.Fprintf(out, "🔍 Scanning 's3://%s' for objects older than %s (%d days)...\n", opts.Bucket, cutoff.Format("2006-01-02 15:04 MST"), opts.Days)
+	} else {
+		fmt.Fprintf(out, "🔍 Scanning 's3://%s' for objects modified before %s...\n", opts.Bucket, cutoff.Format("2006-01-02 15:04 MST"))
+	}
+	if directory {
+		fmt.Fprintln(out, "⚡ Directory bucket (S3 Express One Zone): keys within a prefix are listed in no particular order")
+	}
+	if !opts.Floor.IsZero() {
+		fmt.Fprintf(out, "🔒 Retaining anything modified before %s (--max-age)\n", opts.Floor.Format("2006-01-02 15:04 MST"))
+	}
+	if n := opts.Excludes.Len(); n > 0 {
+		fmt.Fprintf(out, "🛡️ Loaded %d exclusion entries\n", n)
+	}
+	if len(opts.Exts) > 0 {
+		fmt.Fprintf(out, "🧩 Only considering keys ending in %s\n", opts.Exts)
+	}
+	if opts.ArchiveBucket != "" {
+		fmt.Fprintf(out, "📦 Archiving to 's3://%s' before deleting\n", opts.ArchiveBucket)
+	}
+	switch opts.Action {
+	case ActionTag:
+		fmt.Fprintf(out, "🏷️ Tagging stale objects %s=%s instead of deleting them\n", expiryTag, expiry)
+	case ActionDeleteTagged:
+		fmt.Fprintf(out, "🏷️ Deleting only stale objects whose %s tag has expired\n", expiryTag)
+	}
+	if opts.Versions {
+		fmt.Fprintln(out, "🕰️ Listing object versions: keys are aged by their newest version and delete-marked keys are flagged")
+	}
+	if after != "" {
+		fmt.Fprintf(out, "⏩ Resuming after '%s'\n", redact(after))
+	}
+	if budget.objects > 0 {
+		fmt.Fprintf(out, "⏱️ Examining at most %d objects\n", budget.objects)
+	}
+	if opts.MaxDuration > 0 {
+		fmt.Fprintf(out, "⏱️ Listing for at most %s\n", opts.MaxDuration)
+	}
+	var reads *accesslog.Reads
+	if opts.AccessLog.Enabled() {
+		var err error
+		if reads, err = accesslog.Load(ctx, s.client, opts.AccessLog, opts.Bucket); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(out, "📖 Read %s: %d objects read since %s\n", opts.AccessLog.Describe(), reads.Len(), opts.AccessLog.Since.Format("2006-01-02"))
+		if reads.Malformed > 0 {
+			log.Printf("⚠️ Skipped %d unparsable access log lines or rows\n", reads.Malformed)
+		}
+	}
+
+	// Directory buckets don't replicate.
+	replicated := !directory
+	if replicated {
+		replicated, err = s.replicated(ctx, opts.Bucket)
+	}
+	if err != nil {
+		log.Printf("⚠️ Unable to read the replication configuration of %s, checking every object's replication status: %v\n", opts.Bucket, err)
+		replicated = true
+	}
+	if replicated {
+		fmt.Fprintln(out, "🔁 Replication is configured: objects not yet replicated are skipped (one HeadObject per stale object)")
+	}
+
+	// ranked holds stale objects back for a savings target or largest-first order.
+	var ranked *ranking
+	switch {
+	case opts.TargetSavings > 0:
+		ranked = &ranking{target: opts.TargetSavings, limit: opts.MaxDelete, weight: func(c policy.Candidate) float64 {
+			return opts.Pricing.MonthlySavings(c.Size, c.StorageClass)
+		}}
+		fmt.Fprintf(out, "🎯 Acting only on the largest stale objects until %s/month is saved\n", opts.Pricing.Format(opts.TargetSavings, 2))
+	case opts.LargestFirst:
+		ranked = &ranking{limit: opts.MaxDelete, weight: bySize}
+		fmt.Fprintln(out, "📏 Acting on stale objects largest first once listing is done")
+	}
+	if opts.MaxDelete > 0 {
+		fmt.Fprintf(out, "✋ Acting on at most %d stale objects\n", opts.MaxDelete)
+	}
+	selected := 0
+
+	var pending []policy.Candidate
+
+	record, flushSinks := recorder(opts, out, res)
+	defer flushSinks()
+	deleter := s.newDeleter(opts, res, record, directory, largeCopy)
+
+	handleStale := func(c policy.Candidate) {
+		res.Stale++
+		res.StaleBytes += c.Size
+		res.EstimatedSavings += opts.Pricing.MonthlySavings(c.Size, c.StorageClass)
+
+		if opts.Report {
+			record(c, OutcomeStale)
+			return
+		}
+
+		if opts.DryRun && opts.Action == ActionTag {
+			record(c, OutcomeWouldTag)
+			return
+		}
+		if opts.DryRun {
+			record(c, OutcomeWouldDelete)
+			return
+		}
+
+		if opts.Action == ActionTag {
+			if err := s.tagForExpiry(ctx, opts.Bucket, c.Key, expiryTag, expiry); err != nil {
+				log.Printf("⚠️ Failed to tag %s: %v\n", redact(c.Key), err)
+				res.Errors++
+				record(c, OutcomeFailed)
+				return
+			}
+			res.Tagged++
+			record(c, OutcomeTagged)
+			return
+		}
+
+		// Actual Deletion Logic (batched into DeleteObjects calls)
+		deleter.Add(ctx, c)
+	}
+
+	var confirmer Confirmer
+	if opts.Confirm != nil {
+		confirmer = opts.Confirm(handleStale, func() { deleter.Flush(ctx) })
+	}
+
+	if cl, ok := opts.Filter.(io.Closer); ok {
+		defer cl.Close()
+	}
+	// filtered spares objects the access logs show as read, objects not
+	// replicated yet and, when deleting tagged objects, objects whose tag
+	// hasn't expired, then asks the external filter, if any.
+	filtered := func(c policy.Candidate) (bool, error) {
+		if reads != nil {
+			if _, ok := reads.LastRead(c.Key); ok {
+				res.RecentlyRead++
+				return true, nil
+			}
+		}
+		if replicated {
+			status, err := s.replicationStatus(ctx, opts.Bucket, c.Key)
+			switch {
+			case isEncryptionError(err):
+				res.Encrypted++
+				record(c, OutcomeEncrypted)
+				return true, nil
+			case err != nil:
+				log.Printf("⚠️ Keeping %s: unable to read its replication status: %v\n", redact(c.Key), err)
+				res.Errors++
+				return true, nil
+			case status == types.ReplicationStatusPending || status == types.ReplicationStatusFailed:
+				res.Unreplicated++
+				record(c, OutcomeUnreplicated)
+				return true, nil
+			}
+		}
+		if opts.Action == ActionDeleteTagged {
+			expired, err := s.tagExpired(ctx, opts.Bucket, c.Key, expiryTag, res.Started)
+			if err != nil {
+				log.Printf("⚠️ Keeping %s: unable to read its %s tag: %v\n", redact(c.Key), expiryTag, err)
+				res.Errors++
+				return true, nil
+			}
+			if !expired {
+				res.TagKept++
+				return true, nil
+			}
+		}
+		if opts.Filter == nil {
+			return false, nil
+		}
+		keep, err := opts.Filter.Keep(ctx, opts.Bucket, c)
+		if err != nil {
+			return true, fmt.Errorf("filter failed, stopping before acting on %s: %w", redact(c.Key), err)
+		}
+		if keep {
+			res.Filtered++
+		}
+		return keep, nil
+	}
+
+	// act hands a selected object to the approval step, if any, or to handleStale.
+	act := func(c policy.Candidate) {
+		if confirmer != nil {
+			confirmer.Add(c)
+			return
+		}
+		if opts.Review != nil {
+			pending = append(pending, c)
+			return
+		}
+		handleStale(c)
+	}
+	selectStale := func(c policy.Candidate) {
+		if ranked != nil {
+			ranked.Add(c)
+			return
+		}
+		if opts.MaxDelete > 0 && selected >= opts.MaxDelete {
+			res.Deferred++
+			return
+		}
+		selected++
+		act(c)
+	}
+
+	// 2. Pagination Loop (fanned out across top-level prefixes, delivered in key order)
+	scanned := metric.WithAttributes(attribute.String("s3tidy.bucket", opts.Bucket))
+	last := after
+	examine := func(objects []types.Object) error {
+		telemetry().scanned.Add(ctx, int64(len(objects)), scanned)
+		for _, obj := range objects {
+			if budget.enabled() && budget.spent(res.Scanned) {
+				return errScanBudget
+			}
+			res.Scanned++
+			last = *obj.Key
+			if !opts.Exts.Matches(*obj.Key) {
+				continue
+			}
+
+			// Age comes from the key when a date pattern is configured, so
+			// re-uploaded backups don't reset their retention clock.
+			modTime := *obj.LastModified
+			if opts.KeyDates != nil {
+				if t, ok := opts.KeyDates.Extract(*obj.Key); ok {
+					modTime = t
+				} else {
+					res.Undated++
+				}
+			}
+
+			if opts.Tiering != nil {
+				o := tiering.Object{Key: *obj.Key, Size: aws.ToInt64(obj.Size), StorageClass: string(obj.StorageClass), LastModified: *obj.LastModified}
+				if reads != nil {
+					o.LastRead, _ = reads.LastRead(*obj.Key)
+				}
+				o.Stale = opts.Planner == nil && modTime.Before(cutoff)
+				opts.Tiering.Observe(o)
+			}
+
+			// Buffered planners decide on age themselves once listing is done.
+			if opts.Planner == nil && !modTime.Before(cutoff) {
+				continue
+			}
+			if !opts.Floor.IsZero() && modTime.Before(opts.Floor) {
+				res.Retained++
+				continue
+			}
+			if opts.Excludes.Matches(*obj.Key) {
+				res.Excluded++
+				continue
+			}
+
+			c := policy.Candidate{Key: *obj.Key, ModTime: modTime, LastModified: *obj.LastModified, ETag: aws.ToString(obj.ETag), StorageClass: string(obj.StorageClass)}
+			// FIX: Dereference the pointer (*obj.Size)
+			if obj.Size != nil {
+				c.Size = *obj.Size
+			}
+			if directory && c.StorageClass == "" {
+				c.StorageClass = string(types.ObjectStorageClassExpressOnezone)
+			}
+
+			if opts.Planner != nil {
+				opts.Planner.Add(c)
+				continue
+			}
+			if keep, err := filtered(c); err != nil {
+				return err
+			} else if keep {
+				continue
+			}
+			selectStale(c)
+		}
+		return nil
+	}
+	// flagDeleted reports a delete-marked key of a versioned listing. Nothing
+	// is deleted: only the noncurrent versions behind the marker hold bytes.
+	flagDeleted := func(d deleteMarked) error {
+		if budget.enabled() && budget.spent(res.Scanned) {
+			return errScanBudget
+		}
+		last = d.key
+		if !opts.Exts.Matches(d.key) {
+			return nil
+		}
+		res.DeleteMarkers++
+		res.DeleteMarkerBytes += d.noncurrent
+		record(policy.Candidate{Key: d.key, Size: d.noncurrent, LastModified: d.deleted, ModTime: d.deleted}, OutcomeDeleteMarker)
+		return nil
+	}
+	if opts.Versions {
+		err = s.listVersions(ctx, opts.Bucket, after, examine, flagDeleted)
+	} else {
+		err = s.list(ctx, opts.Bucket, after, opts.ListConcurrency, examine)
+	}
+	if errors.Is(err, errScanBudget) {
+		res.Truncated = true
+		res.ResumeAfter = redact(last)
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if opts.CheckpointFile != "" {
+		resume := ""
+		if res.Truncated {
+			resume = last
+		}
+		if err := writeCheckpoint(opts.CheckpointFile, resume); err != nil {
+			log.Printf("⚠️ Unable to update checkpoint %s: %v\n", opts.CheckpointFile, err)
+			res.Errors++
+		}
+	}
+
+	if opts.Planner != nil {
+		for _, c := range opts.Planner.Expired() {
+			if keep, err := filtered(c); err != nil {
+				return nil, err
+			} else if keep {
+				continue
+			}
+			selectStale(c)
+		}
+	}
+
+	if ranked != nil {
+		res.Deferred = ranked.dropped
+		for _, c := range ranked.Selected() {
+			act(c)
+		}
+	}
+
+	if confirmer != nil {
+		confirmer.Finish()
+	}
+
+	if opts.Review != nil {
+		approved, err := opts.Review(ctx, pending)
+		if err != nil {
+			return nil, fmt.Errorf("interactive review failed: %w", err)
+		}
+		fmt.Fprintf(out, "👀 Operator approved %d of %d stale objects.\n", len(approved), len(pending))
+		for _, c := range approved {
+			handleStale(c)
+		}
+	}
+
+	deleter.Flush(ctx)
+
+	// 3. FinOps Report / Summary
+	fmt.Fprintln(out, "------------------------------------------------")
+	if res.Truncated {
+		fmt.Fprintf(out, "⏱️ Stopped at %s after examining %d objects; the rest of the bucket is left for a later run.\n", budget.reason, res.Scanned)
+		if opts.CheckpointFile != "" {
+			fmt.Fprintf(out, "📍 The next run with checkpoint %s resumes after '%s'.\n", opts.CheckpointFile, res.ResumeAfter)
+		} else {
+			fmt.Fprintf(out, "📍 Resume after '%s' (--start-after).\n", res.ResumeAfter)
+		}
+	}
+
+	// Calculate Savings (accumulated per object, since rates differ by storage class)
+	sizeInGB := cost.GB(res.StaleBytes)
+	res.Currency = opts.Pricing.CurrencyCode()
+	res.EstimatedSavingsLocal = opts.Pricing.Convert(res.EstimatedSavings)
+	res.Requests = counter.counts()
+	res.RequestCost = opts.Pricing.RequestCost(res.Requests.List, res.Requests.Get, res.Requests.Put)
+	if directory {
+		res.RequestCost = opts.Pricing.ExpressRequestCost(res.Requests.List, res.Requests.Get, res.Requests.Put)
+	}
+	if opts.Planner != nil {
+		res.Kept = opts.Planner.Kept()
+	}
+	if opts.Tiering != nil {
+		res.Tiering = opts.Tiering.Recommendations()
+		for i := range res.Tiering {
+			res.Tiering[i].Prefix = redact(res.Tiering[i].Prefix)
+		}
+	}
+	res.Finished = time.Now()
+
+	if opts.Report {
+		fmt.Fprintln(out, "📊 FINOPS COST REPORT")
+		fmt.Fprintf(out, "   • Stale Objects Found: %d\n", res.Stale)
+		if res.Excluded > 0 {
+			fmt.Fprintf(out, "   • Stale Objects Excluded: %d\n", res.Excluded)
+		}
+		if res.Retained > 0 {
+			fmt.Fprintf(out, "   • Objects Retained (older than --max-age): %d\n", res.Retained)
+		}
+		if res.Undated > 0 {
+			fmt.Fprintf(out, "   • Keys Without a Date (aged by LastModified): %d\n", res.Undated)
+		}
+		if opts.Planner != nil {
+			fmt.Fprintf(out, "   • Objects Kept by Retention Policy: %d\n", opts.Planner.Kept())
+		}
+		if opts.Filter != nil {
+			fmt.Fprintf(out, "   • Objects Kept by Filter: %d\n", res.Filtered)
+		}
+		if reads != nil {
+			fmt.Fprintf(out, "   • Old Objects Read Recently (kept): %d\n", res.RecentlyRead)
+		}
+		if opts.Tiering != nil {
+			var savings float64
+			for _, r := range res.Tiering {
+				savings += r.TieringSavings
+			}
+			fmt.Fprintf(out, "   • Intelligent-Tiering Candidates: %d prefixes (~%s/month)\n", len(res.Tiering), opts.Pricing.Format(savings, 4))
+		}
+		if res.Deferred > 0 {
+			fmt.Fprintf(out, "   • Stale Objects Left for a Later Run: %d\n", res.Deferred)
+		}
+		if replicated {
+			fmt.Fprintf(out, "   • Stale Objects Not Yet Replicated (kept): %d\n", res.Unreplicated)
+		}
+		if res.Encrypted > 0 {
+			fmt.Fprintf(out, "   • Encrypted Objects Without a Usable Key (kept): %d\n", res.Encrypted)
+		}
+		if opts.Action == ActionDeleteTagged {
+			fmt.Fprintf(out, "   • Stale Objects Without an Expired %s Tag (kept): %d\n", expiryTag, res.TagKept)
+		}
+		if opts.Versions {
+			fmt.Fprintf(out, "   • Delete-Marked Keys Still Storing Noncurrent Versions: %d (%.4f GB)\n", res.DeleteMarkers, cost.GB(res.DeleteMarkerBytes))
+		}
+		fmt.Fprintf(out, "   • Total Storage Reclaimable: %.4f GB\n", sizeInGB)
+		fmt.Fprintf(out, "   • Estimated Monthly Savings: %s\n", opts.Pricing.Format(res.EstimatedSavings, 4))
+		fmt.Fprintf(out, "   • API Requests: %d (LIST %d, GET/HEAD %d, PUT/COPY %d, DELETE %d)\n", res.Requests.Total(), res.Requests.List, res.Requests.Get, res.Requests.Put, res.Requests.Delete)
+		fmt.Fprintf(out, "   • Request Cost of This Scan: %s\n", opts.Pricing.Format(res.RequestCost, 4))
+		fmt.Fprintf(out, "   • Net Savings, First Month: %s\n", opts.Pricing.Format(res.NetSavings(), 4))
+		if directory {
+			fmt.Fprintf(out, "   (Based on S3 Express One Zone pricing of ~%s/GB)\n", opts.Pricing.Format(opts.Pricing.PerGB(string(types.ObjectStorageClassExpressOnezone)), 4))
+		} else {
+			fmt.Fprintf(out, "   (Based on S3 Standard pricing of ~%s/GB)\n", opts.Pricing.Format(opts.Pricing.PerGB(""), 4))
+		}
+		return res, nil
+	}
+
+	if res.Excluded > 0 {
+		fmt.Fprintf(out, "🛡️ Skipped %d stale objects matched by the exclude file.\n", res.Excluded)
+	}
+	if res.Retained > 0 {
+		fmt.Fprintf(out, "🔒 Retained %d objects older than --max-age.\n", res.Retained)
+	}
+	if res.Undated > 0 {
+		fmt.Fprintf(out, "📅 %d keys had no date matching the key pattern; LastModified was used instead.\n", res.Undated)
+	}
+	if opts.Planner != nil {
+		fmt.Fprintf(out, "🗄️ Retention policy kept %d objects.\n", opts.Planner.Kept())
+	}
+	if opts.Filter != nil {
+		fmt.Fprintf(out, "🧩 Filter kept %d objects.\n", res.Filtered)
+	}
+	if reads != nil {
+		fmt.Fprintf(out, "📖 Kept %d old objects read since %s.\n", res.RecentlyRead, opts.AccessLog.Since.Format("2006-01-02"))
+	}
+	if replicated {
+		fmt.Fprintf(out, "🔁 Kept %d stale objects not yet replicated.\n", res.Unreplicated)
+	}
+	if res.Encrypted > 0 {
+		fmt.Fprintf(out, "🔐 Skipped %d encrypted objects whose key isn't available (--sse-c-key-file, or kms:Decrypt on the KMS key).\n", res.Encrypted)
+	}
+	if opts.Action == ActionDeleteTagged {
+		fmt.Fprintf(out, "🏷️ Kept %d stale objects without an expired %s tag.\n", res.TagKept, expiryTag)
+	}
+	if res.DeleteMarkers > 0 {
+		fmt.Fprintf(out, "🪦 %d keys are deleted (their current version is a delete marker) but still store %.2f GB of noncurrent versions; a NoncurrentVersionExpiration lifecycle rule frees them.\n", res.DeleteMarkers, cost.GB(res.DeleteMarkerBytes))
+	}
+	switch {
+	case opts.TargetSavings > 0 && ranked.total < ranked.target:
+		fmt.Fprintf(out, "🎯 Savings target of %s/month not reached: the objects acted on save %s/month.\n", opts.Pricing.Format(ranked.target, 2), opts.Pricing.Format(ranked.total, 2))
+	case opts.TargetSavings > 0:
+		fmt.Fprintf(out, "🎯 Reached the %s/month savings target; left %d more stale objects for a later run.\n", opts.Pricing.Format(ranked.target, 2), res.Deferred)
+	case res.Deferred > 0:
+		fmt.Fprintf(out, "✋ Reached --max-delete; left %d more stale objects for a later run.\n", res.Deferred)
+	}
+
+	fmt.Fprintf(out, "💸 Issued %d API requests (~%s).\n", res.Requests.Total(), opts.Pricing.Format(res.RequestCost, 4))
+
+	switch {
+	case opts.DryRun && opts.Action == ActionTag:
+		fmt.Fprintf(out, "✅ Dry run complete. Found %d stale objects (%.2f GB) to tag %s=%s.\n", res.Stale, sizeInGB, expiryTag, expiry)
+		fmt.Fprintln(out, "   Run with --dry-run=false to tag them.")
+	case opts.DryRun:
+		fmt.Fprintf(out, "✅ Dry run complete. Found %d stale objects (%.2f GB).\n", res.Stale, sizeInGB)
+		fmt.Fprintln(out, "   Run with --dry-run=false to execute cleanup.")
+	case opts.Action == ActionTag:
+		fmt.Fprintf(out, "✅ Tagging complete. Tagged %d objects %s=%s.\n", res.Tagged, expiryTag, expiry)
+		fmt.Fprintf(out, "   Owners can remove the tag to keep an object; after %s, --action %s deletes the rest.\n", expiry, ActionDeleteTagged)
+	default:
+		if opts.ArchiveBucket != "" {
+			fmt.Fprintf(out, "📦 Archived %d objects to 's3://%s'.\n", res.Archived, opts.ArchiveBucket)
+		}
+		if res.ModifiedSinceScan > 0 {
+			fmt.Fprintf(out, "⏭️ Skipped %d objects modified since the scan.\n", res.ModifiedSinceScan)
+		}
+		fmt.Fprintf(out, "✅ Cleanup complete. Deleted %d objects.\n", res.Deleted)
+	}
+	return res, nil
+}
+
+// recorder streams findings to the console and opts.Sinks as they happen. A
+// sink that fails once is dropped so the run can finish, and the failure
+// counts as an error. flush runs once the run is done.
+func recorder(opts Options, out io.Writer, res *Result) (record func(policy.Candidate, Outcome), flush func()) {
+	sinks := append([]Sink{opts.RedactKeys.Sink(consoleSink{out: out, archive: opts.ArchiveBucket != ""})}, opts.Sinks...)
+	record = func(c policy.Candidate, o Outcome) {
+		f := Finding{Bucket: opts.Bucket, Key: c.Key, Size: c.Size, LastModified: c.LastModified, ModTime: c.ModTime, StorageClass: c.StorageClass, VersionID: c.VersionID, ETag: c.ETag, Outcome: o}
+		f.Archived = opts.ArchiveBucket != "" && o == OutcomeDeleted
+		for i, sink := range sinks {
+			if sink == nil {
+				continue
+			}
+			if err := sink.Write(f); err != nil {
+				log.Printf("⚠️ Output sink failed, no further findings will be written to it: %v\n", err)
+				res.Errors++
+				sinks[i] = nil
+			}
+		}
+	}
+	flush = func() {
+		for _, sink := range sinks {
+			if sink == nil {
+				continue
+			}
+			if err := sink.Flush(); err != nil {
+				log.Printf("⚠️ Failed to flush output sink: %v\n", err)
+			}
+		}
+	}
+	return record, flush
+}
+
+// newDeleter builds a run's batch deleter, with its archiver, batch
+// notifiers and pre-delete hooks as opts configure them.
+func (s *Scanner) newDeleter(opts Options, res *Result, record func(policy.Candidate, Outcome), directory, largeCopy bool) *batchDeleter {
+	deleter := newBatchDeleter(s.client, opts.Bucket, opts.BatchSize, opts.Verify, res, record)
+	deleter.sse = s.sse
+	deleter.redact = opts.RedactKeys
+	if opts.ArchiveBucket != "" {
+		deleter.archive = &archiver{
+			client:       s.client,
+			source:       opts.Bucket,
+			bucket:       opts.ArchiveBucket,
+			storageClass: types.StorageClass(opts.ArchiveStorageClass),
+			directory:    directory || IsDirectoryBucket(opts.ArchiveBucket),
+			largeCopy:    largeCopy,
+			sse:          s.sse,
+		}
+	}
+	deleter.onBatch = func(ctx context.Context, b DeletionBatch) {
+		for _, n := range opts.BatchNotifiers {
+			if err := n.NotifyBatch(ctx, res, b); err != nil {
+				log.Printf("⚠️ Batch notification failed: %v\n", err)
+			}
+		}
+	}
+
+	if len(opts.PreDeleteHooks) > 0 {
+		deleter.beforeBatch = func(ctx context.Context, seq int, batch []policy.Candidate) error {
+			m := BatchManifest{Bucket: res.Bucket, Policy: res.Policy, Seq: seq, Objects: make([]ManifestObject, len(batch))}
+			for i, c := range batch {
+				m.Objects[i] = ManifestObject{Key: c.Key, Size: c.Size, LastModified: c.ModTime, VersionID: c.VersionID}
+			}
+			for _, h := range opts.PreDeleteHooks {
+				if err := h.BeforeBatch(ctx, m); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	return deleter
+}
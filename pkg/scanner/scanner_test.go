@@ -0,0 +1,159 @@
+package scanner_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aslinger/s3-tidy/pkg/policy"
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/aslinger/s3-tidy/pkg/scanner/s3fake"
+)
+
+var now = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// newBucket returns a fake holding bucket "b" with keys: the ones named old
+// are 100 days old, the rest a day old.
+func newBucket(t *testing.T, old []string, recent ...string) *s3fake.Client {
+	t.Helper()
+	c := &s3fake.Client{PageSize: 2}
+	c.AddBucket("b")
+	for _, k := range old {
+		c.Put("b", s3fake.Object{Key: k, Size: 100, LastModified: now.AddDate(0, 0, -100)})
+	}
+	for _, k := range recent {
+		c.Put("b", s3fake.Object{Key: k, Size: 100, LastModified: now.AddDate(0, 0, -1)})
+	}
+	return c
+}
+
+// run scans bucket "b" of c for objects older than 30 days.
+func run(t *testing.T, c *s3fake.Client, opts scanner.Options) *scanner.Result {
+	t.Helper()
+	opts.Bucket, opts.Out = "b", io.Discard
+	opts.Cutoff = now.AddDate(0, 0, -30)
+	res, err := scanner.New(c).Run(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return res
+}
+
+func TestRunDryRunDeletesNothing(t *testing.T) {
+	c := newBucket(t, []string{"a", "b", "c"}, "d")
+	res := run(t, c, scanner.Options{DryRun: true})
+
+	if res.Scanned != 4 || res.Stale != 3 || res.StaleBytes != 300 || res.Deleted != 0 {
+		t.Errorf("scanned %d, stale %d (%d bytes), deleted %d; want 4, 3 (300 bytes), 0", res.Scanned, res.Stale, res.StaleBytes, res.Deleted)
+	}
+	if got := c.Keys("b"); len(got) != 4 {
+		t.Errorf("keys left = %v, want all 4", got)
+	}
+	if n := c.Calls("DeleteObjects"); n != 0 {
+		t.Errorf("DeleteObjects called %d times in a dry run", n)
+	}
+}
+
+func TestRunDeletesStaleObjects(t *testing.T) {
+	c := newBucket(t, []string{"a", "b", "c"}, "d")
+	res := run(t, c, scanner.Options{})
+
+	if res.Deleted != 3 || res.DeletedBytes != 300 {
+		t.Errorf("deleted %d (%d bytes), want 3 (300 bytes)", res.Deleted, res.DeletedBytes)
+	}
+	if got, want := c.Keys("b"), []string{"d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys left = %v, want %v", got, want)
+	}
+}
+
+func TestRunBatchesDeletes(t *testing.T) {
+	c := newBucket(t, []string{"a", "b", "c", "d", "e"})
+	var batches []scanner.DeletionBatch
+	res := run(t, c, scanner.Options{BatchSize: 2, BatchNotifiers: []scanner.BatchNotifier{batchRecorder(&batches)}})
+
+	if res.Deleted != 5 {
+		t.Fatalf("deleted %d, want 5", res.Deleted)
+	}
+	// The last batch holds one key and goes out as a DeleteObject.
+	if n, m := c.Calls("DeleteObjects"), c.Calls("DeleteObject"); n != 2 || m != 1 {
+		t.Errorf("DeleteObjects called %d times and DeleteObject %d, want 2 and 1", n, m)
+	}
+	var keys []int
+	for _, b := range batches {
+		keys = append(keys, b.Keys)
+	}
+	if want := []int{2, 2, 1}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("batch sizes = %v, want %v", keys, want)
+	}
+}
+
+// excluding returns a selection whose exclude file holds lines.
+func excluding(t *testing.T, lines ...string) policy.Selection {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "exclude.txt")
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	excludes, err := policy.LoadExcludeFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return policy.Selection{Excludes: excludes}
+}
+
+func TestRunSkipsExcludedKeys(t *testing.T) {
+	c := newBucket(t, []string{"keep/me.txt", "logs/a.log", "logs/b.tmp", "pinned"})
+	res := run(t, c, scanner.Options{Selection: excluding(t, "# owned by the data team", "pinned", "logs/*.log")})
+
+	if res.Excluded != 2 || res.Deleted != 2 {
+		t.Errorf("excluded %d, deleted %d; want 2, 2", res.Excluded, res.Deleted)
+	}
+	if got, want := c.Keys("b"), []string{"logs/a.log", "pinned"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys left = %v, want %v", got, want)
+	}
+}
+
+func TestRunMaxDelete(t *testing.T) {
+	c := newBucket(t, []string{"a", "b", "c", "d"})
+	res := run(t, c, scanner.Options{MaxDelete: 3})
+
+	if res.Deleted != 3 || res.Deferred != 1 {
+		t.Errorf("deleted %d, deferred %d; want 3, 1", res.Deleted, res.Deferred)
+	}
+	if got, want := c.Keys("b"), []string{"d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys left = %v, want %v", got, want)
+	}
+}
+
+func TestRunMaxDeleteLargestFirst(t *testing.T) {
+	c := &s3fake.Client{}
+	c.AddBucket("b")
+	for k, size := range map[string]int64{"a": 10, "b": 300, "c": 20, "d": 200} {
+		c.Put("b", s3fake.Object{Key: k, Size: size, LastModified: now.AddDate(0, 0, -100)})
+	}
+	res := run(t, c, scanner.Options{MaxDelete: 2, LargestFirst: true})
+
+	if res.Deleted != 2 || res.DeletedBytes != 500 {
+		t.Errorf("deleted %d (%d bytes), want 2 (500 bytes)", res.Deleted, res.DeletedBytes)
+	}
+	if got, want := c.Keys("b"), []string{"a", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys left = %v, want %v", got, want)
+	}
+}
+
+// batchRecorder collects every deletion batch a run reports into batches.
+func batchRecorder(batches *[]scanner.DeletionBatch) scanner.BatchNotifier {
+	return batchFunc(func(b scanner.DeletionBatch) { *batches = append(*batches, b) })
+}
+
+type batchFunc func(b scanner.DeletionBatch)
+
+func (f batchFunc) NotifyBatch(_ context.Context, _ *scanner.Result, b scanner.DeletionBatch) error {
+	f(b)
+	return nil
+}
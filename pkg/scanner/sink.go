@@ -0,0 +1,153 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Outcome is what a run did with one stale object.
+type Outcome string
+
+const (
+	OutcomeStale       Outcome = "stale"        // --report: found, not acted on
+	OutcomeWouldDelete Outcome = "would_delete" // --dry-run
+	OutcomeDeleted     Outcome = "deleted"
+	OutcomeSkipped     Outcome = "skipped_modified" // rewritten since listing
+	OutcomeFailed      Outcome = "failed"
+	// OutcomeUnreplicated: kept because replication hasn't copied it yet.
+	OutcomeUnreplicated Outcome = "skipped_unreplicated"
+	// OutcomeEncrypted: kept because its SSE-C or KMS key isn't available.
+	OutcomeEncrypted Outcome = "skipped_encrypted"
+	// OutcomeMissing: named by a key list but not in the bucket.
+	OutcomeMissing Outcome = "skipped_missing"
+	// OutcomeDeleteMarker: a versioned key whose current version is a delete
+	// marker; Size is the noncurrent versions it still stores.
+	OutcomeDeleteMarker Outcome = "flagged_delete_marker"
+	// OutcomeWouldTag and OutcomeTagged: marked for review by ActionTag.
+	OutcomeWouldTag Outcome = "would_tag"
+	OutcomeTagged   Outcome = "tagged"
+)
+
+// Finding is one stale object and what became of it. Sinks receive findings
+// one at a time as the run produces them; nothing collects them in memory.
+type Finding struct {
+	Bucket       string    `json:"bucket"`
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	// ModTime is the age the policy judged by: LastModified, or the date in the
+	// key when a key date pattern is configured.
+	ModTime      time.Time `json:"mod_time"`
+	StorageClass string    `json:"storage_class,omitempty"` // as listed; empty means STANDARD
+	VersionID    string    `json:"version_id,omitempty"`    // only for versions named by a key list
+	ETag         string    `json:"etag,omitempty"`          // as listed, so a plan can tell rewrites apart
+	Outcome      Outcome   `json:"outcome"`
+	Archived     bool      `json:"archived,omitempty"`
+}
+
+// Sink receives every finding of a run. Flush is called once the run is done.
+type Sink interface {
+	Write(f Finding) error
+	Flush() error
+}
+
+// consoleSink prints the per-object progress lines of a run.
+type consoleSink struct {
+	out     io.Writer
+	archive bool
+}
+
+func (s consoleSink) Write(f Finding) error {
+	switch f.Outcome {
+	case OutcomeWouldDelete:
+		verb := "delete"
+		if s.archive {
+			verb = "archive and delete"
+		}
+		sizeMB := float64(f.Size) / 1024 / 1024
+		fmt.Fprintf(s.out, "[DRY RUN] Would %s: %s (%s, %.2f MB)\n", verb, f.Key, f.ModTime.Format(time.RFC3339), sizeMB)
+	case OutcomeDeleted:
+		fmt.Fprintf(s.out, "🗑️ DELETED: %s\n", f.Key)
+	case OutcomeWouldTag:
+		fmt.Fprintf(s.out, "[DRY RUN] Would tag: %s (%s, %.2f MB)\n", f.Key, f.ModTime.Format(time.RFC3339), float64(f.Size)/1024/1024)
+	case OutcomeTagged:
+		fmt.Fprintf(s.out, "🏷️ TAGGED: %s\n", f.Key)
+	case OutcomeSkipped:
+		fmt.Fprintf(s.out, "⏭️ SKIPPED (modified since scan): %s\n", f.Key)
+	case OutcomeUnreplicated:
+		fmt.Fprintf(s.out, "🔁 SKIPPED (not yet replicated): %s\n", f.Key)
+	case OutcomeEncrypted:
+		fmt.Fprintf(s.out, "🔐 SKIPPED (encrypted, key not available): %s\n", f.Key)
+	case OutcomeMissing:
+		fmt.Fprintf(s.out, "❔ SKIPPED (not found): %s\n", f.Key)
+	case OutcomeDeleteMarker:
+		fmt.Fprintf(s.out, "🪦 DELETE MARKER (%.2f MB of noncurrent versions): %s\n", float64(f.Size)/1024/1024, f.Key)
+	}
+	// Stale objects are only summarised; failures are already logged.
+	return nil
+}
+
+func (s consoleSink) Flush() error { return nil }
+
+// CSVSink writes findings as CSV rows under a header line.
+type CSVSink struct {
+	w      *csv.Writer
+	header bool
+}
+
+// NewCSVSink returns a sink writing CSV to w. The caller closes w.
+func NewCSVSink(w io.Writer) *CSVSink {
+	return &CSVSink{w: csv.NewWriter(w)}
+}
+
+// Write implements Sink.
+func (s *CSVSink) Write(f Finding) error {
+	if !s.header {
+		s.header = true
+		if err := s.w.Write([]string{"bucket", "key", "size", "last_modified", "mod_time", "outcome", "archived"}); err != nil {
+			return err
+		}
+	}
+	return s.w.Write([]string{
+		f.Bucket,
+		f.Key,
+		strconv.FormatInt(f.Size, 10),
+		f.LastModified.UTC().Format(time.RFC3339),
+		f.ModTime.UTC().Format(time.RFC3339),
+		string(f.Outcome),
+		strconv.FormatBool(f.Archived),
+	})
+}
+
+// Flush implements Sink.
+func (s *CSVSink) Flush() error {
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// ManifestSink writes findings as JSON lines, one object per line.
+type ManifestSink struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewManifestSink returns a sink writing JSON lines to w. The caller closes w.
+func NewManifestSink(w io.Writer) *ManifestSink {
+	buf := bufio.NewWriter(w)
+	return &ManifestSink{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+// Write implements Sink.
+func (s *ManifestSink) Write(f Finding) error {
+	return s.enc.Encode(f)
+}
+
+// Flush implements Sink.
+func (s *ManifestSink) Flush() error {
+	return s.buf.Flush()
+}
@@ -0,0 +1,97 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Action is what a run does with the stale objects it selects, outside dry
+// runs and reports.
+type Action string
+
+const (
+	// ActionDelete deletes them (the default).
+	ActionDelete Action = "delete"
+	// ActionTag marks them for review instead: each gets ExpiryTag set to the
+	// ExpireAfter date, which owners can remove to keep the object.
+	ActionTag Action = "tag"
+	// ActionDeleteTagged deletes only stale objects whose ExpiryTag date has
+	// passed; untagged objects and unexpired tags are kept.
+	ActionDeleteTagged Action = "delete-tagged"
+)
+
+// DefaultExpiryTag is the tag key ActionTag and ActionDeleteTagged use unless
+// Options.ExpiryTag names another.
+const DefaultExpiryTag = "s3tidy:expire-after"
+
+// expiryLayout is the format of the tag's value, a date in UTC.
+const expiryLayout = "2006-01-02"
+
+// Validate rejects unknown actions; empty means ActionDelete.
+func (a Action) Validate() error {
+	switch a {
+	case "", ActionDelete, ActionTag, ActionDeleteTagged:
+		return nil
+	}
+	return fmt.Errorf("unknown action %q (use delete, tag or delete-tagged)", a)
+}
+
+// ObjectTagger is implemented by clients that can set object tags, which
+// ActionTag needs. *s3.Client does.
+type ObjectTagger interface {
+	PutObjectTagging(ctx context.Context, in *s3.PutObjectTaggingInput, optFns ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error)
+}
+
+var _ ObjectTagger = (*s3.Client)(nil)
+
+var errNoTagging = errors.New("this storage provider can't tag objects")
+
+// tagForExpiry sets tag to value on key, keeping the object's other tags:
+// PutObjectTagging replaces the whole set. An object already carrying tag
+// keeps its date, so repeated tagging runs don't push the expiry out.
+func (s *Scanner) tagForExpiry(ctx context.Context, bucket, key, tag, value string) error {
+	tagger, ok := s.client.(ObjectTagger)
+	if !ok {
+		return errNoTagging
+	}
+	current, err := s.client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return err
+	}
+	set := []types.Tag{{Key: aws.String(tag), Value: aws.String(value)}}
+	for _, t := range current.TagSet {
+		if aws.ToString(t.Key) == tag {
+			return nil
+		}
+		set = append(set, t)
+	}
+	_, err = tagger.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{Bucket: aws.String(bucket), Key: aws.String(key), Tagging: &types.Tagging{TagSet: set}})
+	return err
+}
+
+// tagExpired reports whether key carries tag with a date before now's UTC
+// day: an object tagged to expire after 2025-01-01 may go from 2025-01-02.
+// A missing or unreadable value keeps the object.
+func (s *Scanner) tagExpired(ctx context.Context, bucket, key, tag string, now time.Time) (bool, error) {
+	out, err := s.client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return false, err
+	}
+	for _, t := range out.TagSet {
+		if aws.ToString(t.Key) != tag {
+			continue
+		}
+		after, err := time.Parse(expiryLayout, aws.ToString(t.Value))
+		if err != nil {
+			return false, fmt.Errorf("tag %s=%q is not a date (%s)", tag, aws.ToString(t.Value), expiryLayout)
+		}
+		return !now.UTC().Before(after.AddDate(0, 0, 1)), nil
+	}
+	return false, nil
+}
@@ -0,0 +1,100 @@
+package scanner
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// tagClient is one object's tag set; every other API call panics.
+type tagClient struct {
+	API
+	tags map[string]string
+}
+
+func (c *tagClient) GetObjectTagging(_ context.Context, _ *s3.GetObjectTaggingInput, _ ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error) {
+	out := &s3.GetObjectTaggingOutput{}
+	for k, v := range c.tags {
+		out.TagSet = append(out.TagSet, types.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return out, nil
+}
+
+func (c *tagClient) PutObjectTagging(_ context.Context, in *s3.PutObjectTaggingInput, _ ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error) {
+	c.tags = make(map[string]string)
+	for _, t := range in.Tagging.TagSet {
+		c.tags[aws.ToString(t.Key)] = aws.ToString(t.Value)
+	}
+	return &s3.PutObjectTaggingOutput{}, nil
+}
+
+func TestTagForExpiryKeepsOtherTags(t *testing.T) {
+	c := &tagClient{tags: map[string]string{"owner": "data-team", "cost-center": "42"}}
+	if err := New(c).tagForExpiry(context.Background(), "b", "k", DefaultExpiryTag, "2025-02-01"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"owner": "data-team", "cost-center": "42", DefaultExpiryTag: "2025-02-01"}
+	if !reflect.DeepEqual(c.tags, want) {
+		t.Errorf("tags = %v, want %v", c.tags, want)
+	}
+}
+
+func TestTagForExpiryKeepsExistingDate(t *testing.T) {
+	c := &tagClient{tags: map[string]string{DefaultExpiryTag: "2025-01-15", "owner": "data-team"}}
+	if err := New(c).tagForExpiry(context.Background(), "b", "k", DefaultExpiryTag, "2025-03-01"); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.tags[DefaultExpiryTag]; got != "2025-01-15" {
+		t.Errorf("expiry = %s, want the original 2025-01-15", got)
+	}
+	if got := c.tags["owner"]; got != "data-team" {
+		t.Errorf("owner tag = %q, want it kept", got)
+	}
+}
+
+func TestTagExpiredDayBoundary(t *testing.T) {
+	c := &tagClient{tags: map[string]string{DefaultExpiryTag: "2025-01-01"}}
+	for _, tc := range []struct {
+		now  time.Time
+		want bool
+	}{
+		{time.Date(2024, 12, 31, 12, 0, 0, 0, time.UTC), false},
+		{time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{time.Date(2025, 1, 1, 23, 59, 59, 0, time.UTC), false},
+		{time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), true},
+		// Still 2025-01-01 in UTC, whatever the local date says.
+		{time.Date(2025, 1, 2, 8, 0, 0, 0, time.FixedZone("UTC+9", 9*3600)), false},
+		{time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), true},
+	} {
+		got, err := New(c).tagExpired(context.Background(), "b", "k", DefaultExpiryTag, tc.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("expired at %s = %v, want %v", tc.now.Format(time.RFC3339), got, tc.want)
+		}
+	}
+}
+
+func TestTagExpiredKeepsMalformedAndMissingTags(t *testing.T) {
+	later := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tags := range []map[string]string{
+		{DefaultExpiryTag: "next week"},
+		{DefaultExpiryTag: "01/01/2025"},
+		{DefaultExpiryTag: ""},
+		{"owner": "data-team"},
+	} {
+		expired, err := New(&tagClient{tags: tags}).tagExpired(context.Background(), "b", "k", DefaultExpiryTag, later)
+		if expired {
+			t.Errorf("tags %v: expired, want the object kept", tags)
+		}
+		if _, ok := tags[DefaultExpiryTag]; ok && err == nil {
+			t.Errorf("tags %v: no error for a malformed date", tags)
+		}
+	}
+}
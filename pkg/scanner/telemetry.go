@@ -0,0 +1,124 @@
+package scanner
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation is the OpenTelemetry scope of everything the scanner emits.
+// Spans and metrics go to the global providers, which do nothing until the
+// embedder (the CLI, when OTLP is configured) installs real ones.
+const instrumentation = "github.com/aslinger/s3-tidy/pkg/scanner"
+
+var tracer = otel.Tracer(instrumentation)
+
+type instruments struct {
+	requestDuration metric.Float64Histogram
+	batchDuration   metric.Float64Histogram
+	scanned         metric.Int64Counter
+	deleted         metric.Int64Counter
+	deletedBytes    metric.Int64Counter
+}
+
+// telemetry creates the instruments on first use. Creating one only fails for
+// an invalid name, which otel.Handle reports; the instrument is a no-op then.
+var telemetry = sync.OnceValue(func() *instruments {
+	m := otel.Meter(instrumentation)
+	var in instruments
+	var err error
+	if in.requestDuration, err = m.Float64Histogram("s3tidy.request.duration", metric.WithUnit("s"), metric.WithDescription("Duration of object store API requests, by operation.")); err != nil {
+		otel.Handle(err)
+	}
+	if in.batchDuration, err = m.Float64Histogram("s3tidy.delete_batch.duration", metric.WithUnit("s"), metric.WithDescription("Duration of deletion batches, including re-checks and archive copies.")); err != nil {
+		otel.Handle(err)
+	}
+	if in.scanned, err = m.Int64Counter("s3tidy.objects.scanned", metric.WithDescription("Objects listed.")); err != nil {
+		otel.Handle(err)
+	}
+	if in.deleted, err = m.Int64Counter("s3tidy.objects.deleted", metric.WithDescription("Objects deleted.")); err != nil {
+		otel.Handle(err)
+	}
+	if in.deletedBytes, err = m.Int64Counter("s3tidy.deleted.bytes", metric.WithUnit("By"), metric.WithDescription("Bytes freed by deletions.")); err != nil {
+		otel.Handle(err)
+	}
+	return &in
+})
+
+// startRun opens the root span of a run.
+func startRun(ctx context.Context, name string, opts Options) (context.Context, trace.Span) {
+	policy := opts.Name
+	if policy == "" {
+		policy = opts.Bucket
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("s3tidy.bucket", opts.Bucket),
+		attribute.String("s3tidy.policy", policy),
+		attribute.Bool("s3tidy.dry_run", opts.DryRun),
+		attribute.Bool("s3tidy.report", opts.Report),
+	))
+}
+
+// endRun closes a run's root span with its totals, or its error.
+func endRun(span trace.Span, res *Result, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	if res != nil {
+		span.SetAttributes(
+			attribute.Int("s3tidy.objects.scanned", res.Scanned),
+			attribute.Int("s3tidy.objects.stale", res.Stale),
+			attribute.Int("s3tidy.objects.deleted", res.Deleted),
+			attribute.Int64("s3tidy.bytes.stale", res.StaleBytes),
+			attribute.Int("s3tidy.errors", res.Errors),
+		)
+	}
+	span.End()
+}
+
+// startRequest opens the span of one API request; the returned function
+// closes it and records the request's duration.
+func startRequest(ctx context.Context, op, bucket string) (context.Context, func(error)) {
+	ops := attribute.String("s3tidy.operation", op)
+	ctx, span := tracer.Start(ctx, op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(ops, attribute.String("s3tidy.bucket", bucket)))
+	started := time.Now()
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		telemetry().requestDuration.Record(ctx, time.Since(started).Seconds(), metric.WithAttributes(ops, attribute.Bool("s3tidy.error", err != nil)))
+	}
+}
+
+// startBatch opens the span of one deletion batch; the returned function
+// closes it with the batch's outcome.
+func startBatch(ctx context.Context, bucket string, seq, keys int) (context.Context, func(DeletionBatch)) {
+	at := attribute.String("s3tidy.bucket", bucket)
+	ctx, span := tracer.Start(ctx, "delete batch", trace.WithAttributes(at, attribute.Int("s3tidy.batch", seq), attribute.Int("s3tidy.batch.keys", keys)))
+	started := time.Now()
+	return ctx, func(b DeletionBatch) {
+		span.SetAttributes(
+			attribute.Int("s3tidy.batch.deleted", b.Deleted),
+			attribute.Int("s3tidy.batch.failed", b.Failed),
+			attribute.Int("s3tidy.batch.skipped", b.Skipped),
+		)
+		if b.Failed > 0 {
+			span.SetStatus(codes.Error, "some objects of the batch failed")
+		}
+		span.End()
+		t := telemetry()
+		attrs := metric.WithAttributes(at)
+		t.batchDuration.Record(ctx, time.Since(started).Seconds(), attrs)
+		t.deleted.Add(ctx, int64(b.Deleted), attrs)
+		t.deletedBytes.Add(ctx, b.DeletedBytes, attrs)
+	}
+}
@@ -0,0 +1,156 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// VersionLister is implemented by clients that can list object versions,
+// which Options.Versions needs. *s3.Client does; other providers don't.
+type VersionLister interface {
+	ListObjectVersions(ctx context.Context, in *s3.ListObjectVersionsInput, optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error)
+}
+
+var _ VersionLister = (*s3.Client)(nil)
+
+var errNoVersions = errors.New("this storage provider can't list object versions")
+
+// deleteMarked is a key whose current version is a delete marker: gone from
+// ordinary listings, but still billed for its noncurrent versions.
+type deleteMarked struct {
+	key        string
+	deleted    time.Time // when the delete marker was written
+	noncurrent int64     // bytes of the versions behind it
+}
+
+// versionedKey gathers the versions of one key as they stream past; a key's
+// versions may span pages.
+type versionedKey struct {
+	key        string
+	latest     *types.ObjectVersion
+	marker     *types.DeleteMarkerEntry
+	newest     time.Time
+	noncurrent int64
+}
+
+// listVersions is list for Options.Versions: it hands every key that still
+// has a current version to fn, as an object carrying that version's size and
+// the time of the key's newest version, and every delete-marked key to marked,
+// both in key order. fn and marked run on the calling goroutine; the listing
+// is a single stream, so ListConcurrency doesn't apply.
+//
+// A non-empty after resumes the listing past that key, like StartAfter.
+func (s *Scanner) listVersions(ctx context.Context, bucket, after string, fn func([]types.Object) error, marked func(deleteMarked) error) error {
+	lister, ok := s.client.(VersionLister)
+	if !ok {
+		return errNoVersions
+	}
+	in := &s3.ListObjectVersionsInput{Bucket: aws.String(bucket)}
+	if after != "" {
+		in.KeyMarker = aws.String(after)
+	}
+
+	var batch []types.Object
+	var cur *versionedKey
+	// emit settles cur once every one of its versions has been seen.
+	emit := func() error {
+		if cur == nil {
+			return nil
+		}
+		k := cur
+		cur = nil
+		if k.marker != nil {
+			if len(batch) > 0 {
+				if err := fn(batch); err != nil {
+					return err
+				}
+				batch = nil
+			}
+			return marked(deleteMarked{key: k.key, deleted: aws.ToTime(k.marker.LastModified), noncurrent: k.noncurrent})
+		}
+		if k.latest == nil {
+			// Only noncurrent versions in view, which a consistent listing
+			// never produces; leave the key alone rather than guess.
+			return nil
+		}
+		batch = append(batch, types.Object{
+			Key:          k.latest.Key,
+			Size:         k.latest.Size,
+			ETag:         k.latest.ETag,
+			StorageClass: types.ObjectStorageClass(k.latest.StorageClass),
+			LastModified: aws.Time(k.newest),
+		})
+		return nil
+	}
+	add := func(key string) (*versionedKey, error) {
+		if cur != nil && cur.key == key {
+			return cur, nil
+		}
+		if err := emit(); err != nil {
+			return nil, err
+		}
+		cur = &versionedKey{key: key}
+		return cur, nil
+	}
+
+	for {
+		page, err := lister.ListObjectVersions(ctx, in)
+		if err != nil {
+			return fmt.Errorf("failed to list object versions: %w", err)
+		}
+		// Versions and delete markers come as two lists, each in key order;
+		// merge them so one key's entries are gathered together.
+		vs, ms := page.Versions, page.DeleteMarkers
+		for len(vs) > 0 || len(ms) > 0 {
+			if len(ms) == 0 || (len(vs) > 0 && aws.ToString(vs[0].Key) <= aws.ToString(ms[0].Key)) {
+				v := vs[0]
+				vs = vs[1:]
+				k, err := add(aws.ToString(v.Key))
+				if err != nil {
+					return err
+				}
+				if t := aws.ToTime(v.LastModified); t.After(k.newest) {
+					k.newest = t
+				}
+				if aws.ToBool(v.IsLatest) {
+					k.latest = &v
+				} else {
+					k.noncurrent += aws.ToInt64(v.Size)
+				}
+				continue
+			}
+			m := ms[0]
+			ms = ms[1:]
+			k, err := add(aws.ToString(m.Key))
+			if err != nil {
+				return err
+			}
+			if aws.ToBool(m.IsLatest) {
+				k.marker = &m
+			}
+		}
+		if len(batch) > 0 {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = nil
+		}
+		if !aws.ToBool(page.IsTruncated) {
+			break
+		}
+		in.KeyMarker, in.VersionIdMarker = page.NextKeyMarker, page.NextVersionIdMarker
+	}
+	if err := emit(); err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+// Package signing makes deletion manifests tamper-evident. A manifest is
+// signed once it is complete, with an HMAC secret or an Ed25519 private key,
+// and the detached signature travels next to it; whoever executes the
+// manifest verifies it first and refuses it if a single byte has changed.
+//
+// A signed manifest can also be approved: a second person countersigns its
+// signature with their own Ed25519 key, and FourEyes checks that the approval
+// didn't come from the manifest's signer.
+package signing
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Algorithms a Signature may carry.
+const (
+	HMACSHA256 = "hmac-sha256"
+	Ed25519    = "ed25519"
+)
+
+// Suffix is appended to a manifest's path to name its signature file.
+const Suffix = ".sig"
+
+// ApprovalSuffix is appended to a manifest's path to name its approval file.
+const ApprovalSuffix = ".approval"
+
+// What a signature is over, bound into the signed message so an approval
+// can't pass as a manifest signature or the other way round.
+const (
+	purposeManifest = "manifest"
+	purposeApproval = "approval"
+)
+
+// Signature is the detached signature of one manifest, stored as JSON.
+type Signature struct {
+	Algorithm string    `json:"algorithm"`
+	KeyID     string    `json:"key_id"`           // fingerprint of the key, to tell keys apart
+	SHA256    string    `json:"sha256"`           // hex digest of the manifest
+	Signed    time.Time `json:"signed"`           // covered by the signature
+	Value     string    `json:"signature"`        // base64
+	Signer    string    `json:"signer,omitempty"` // who signed, as they said; covered too
+}
+
+// Key signs, verifies, or (a public key) only verifies.
+type Key struct {
+	secret []byte // HMAC
+	priv   ed25519.PrivateKey
+	pub    ed25519.PublicKey
+}
+
+// LoadKey reads a key file: a PEM "PRIVATE KEY" (PKCS #8 Ed25519), a PEM
+// "PUBLIC KEY" (verify only), or anything else as an HMAC secret, which must
+// be at least 32 bytes once surrounding whitespace is trimmed.
+func LoadKey(path string) (*Key, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read signing key: %w", err)
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		secret := bytes.TrimSpace(raw)
+		if len(secret) < 32 {
+			return nil, fmt.Errorf("%s: HMAC secret must be at least 32 bytes, got %d", path, len(secret))
+		}
+		return &Key{secret: secret}, nil
+	}
+	switch block.Type {
+	case "PRIVATE KEY":
+		k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		priv, ok := k.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s: only Ed25519 private keys are supported, got %T", path, k)
+		}
+		return &Key{priv: priv, pub: priv.Public().(ed25519.PublicKey)}, nil
+	case "PUBLIC KEY":
+		k, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		pub, ok := k.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%s: only Ed25519 public keys are supported, got %T", path, k)
+		}
+		return &Key{pub: pub}, nil
+	}
+	return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+}
+
+// CanSign reports whether k holds a secret or private key.
+func (k *Key) CanSign() bool { return k.secret != nil || k.priv != nil }
+
+// Algorithm is the algorithm k signs with, HMACSHA256 or Ed25519.
+func (k *Key) Algorithm() string {
+	if k.secret != nil {
+		return HMACSHA256
+	}
+	return Ed25519
+}
+
+// ID fingerprints the key; a secret's fingerprint doesn't reveal it.
+func (k *Key) ID() string {
+	material := []byte(k.pub)
+	if k.secret != nil {
+		material = append([]byte("s3-tidy hmac key\n"), k.secret...)
+	}
+	sum := sha256.Sum256(material)
+	return hex.EncodeToString(sum[:8])
+}
+
+// message is what a signature covers: its purpose, the digest, signing time
+// and signer.
+func message(purpose string, sig *Signature) []byte {
+	return fmt.Appendf(nil, "s3-tidy %s v1\nsha256:%s\nsigned:%s\nsigner:%s\n", purpose, sig.SHA256, sig.Signed.UTC().Format(time.RFC3339Nano), sig.Signer)
+}
+
+// Sign signs manifest as of now. signer is recorded as given; only the key
+// proves anything.
+func (k *Key) Sign(manifest []byte, signer string, now time.Time) (*Signature, error) {
+	return k.sign(purposeManifest, manifest, signer, now)
+}
+
+func (k *Key) sign(purpose string, data []byte, signer string, now time.Time) (*Signature, error) {
+	if !k.CanSign() {
+		return nil, errors.New("a public key can only verify; sign with the private key")
+	}
+	sum := sha256.Sum256(data)
+	sig := &Signature{Algorithm: k.Algorithm(), KeyID: k.ID(), SHA256: hex.EncodeToString(sum[:]), Signed: now.UTC(), Signer: signer}
+	msg := message(purpose, sig)
+	if k.secret != nil {
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(msg)
+		sig.Value = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	} else {
+		sig.Value = base64.StdEncoding.EncodeToString(ed25519.Sign(k.priv, msg))
+	}
+	return sig, nil
+}
+
+// Verify checks that sig was made by k over exactly manifest.
+func (k *Key) Verify(manifest []byte, sig *Signature) error {
+	return k.verify(purposeManifest, manifest, sig)
+}
+
+func (k *Key) verify(purpose string, data []byte, sig *Signature) error {
+	if sig.Algorithm != k.Algorithm() {
+		return fmt.Errorf("%s is signed with %s, the key is for %s", purpose, sig.Algorithm, k.Algorithm())
+	}
+	if sig.KeyID != k.ID() {
+		return fmt.Errorf("%s is signed by key %s, not %s", purpose, sig.KeyID, k.ID())
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != sig.SHA256 {
+		return fmt.Errorf("%s was changed after signing (sha256 %s, signed %s)", purpose, got, sig.SHA256)
+	}
+	value, err := base64.StdEncoding.DecodeString(sig.Value)
+	if err != nil {
+		return fmt.Errorf("malformed signature: %w", err)
+	}
+	msg := message(purpose, sig)
+	var ok bool
+	if k.secret != nil {
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(msg)
+		ok = hmac.Equal(value, mac.Sum(nil))
+	} else {
+		ok = ed25519.Verify(k.pub, msg, value)
+	}
+	if !ok {
+		return errors.New("signature does not verify")
+	}
+	return nil
+}
+
+// SignFile signs the manifest at path and writes path+Suffix.
+func (k *Key) SignFile(path, signer string, now time.Time) (*Signature, error) {
+	manifest, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := k.Sign(manifest, signer, now)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.MarshalIndent(sig, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return sig, os.WriteFile(path+Suffix, append(raw, '\n'), 0o644)
+}
+
+// VerifyFile verifies the manifest at path against the signature file
+// sigPath (path+Suffix when empty) and returns the verified manifest, so the
+// caller acts on exactly the bytes that were checked.
+func (k *Key) VerifyFile(path, sigPath string) ([]byte, *Signature, error) {
+	if sigPath == "" {
+		sigPath = path + Suffix
+	}
+	manifest, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	raw, err := os.ReadFile(sigPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read signature: %w", err)
+	}
+	var sig Signature
+	if err := json.Unmarshal(raw, &sig); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", sigPath, err)
+	}
+	if err := k.Verify(manifest, &sig); err != nil {
+		return nil, &sig, err
+	}
+	return manifest, &sig, nil
+}
+
+// approved is what an approval covers: everything the manifest's signature
+// covers plus the signature itself, so it pins the digest, the signer and the
+// key that signed.
+func approved(manifest *Signature) []byte {
+	return fmt.Appendf(message(purposeManifest, manifest), "algorithm:%s\nkey:%s\nsignature:%s\n", manifest.Algorithm, manifest.KeyID, manifest.Value)
+}
+
+// ApproveFile approves the manifest at path, whose verified signature is
+// manifest, and writes path+ApprovalSuffix. approver is recorded as given,
+// like a signer. Only Ed25519 keys approve: with a shared HMAC secret, the
+// approver could equally have signed the manifest.
+func (k *Key) ApproveFile(path string, manifest *Signature, approver string, now time.Time) (*Signature, error) {
+	if k.Algorithm() != Ed25519 {
+		return nil, errHMACApproval
+	}
+	sig, err := k.sign(purposeApproval, approved(manifest), approver, now)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.MarshalIndent(sig, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return sig, os.WriteFile(path+ApprovalSuffix, append(raw, '\n'), 0o644)
+}
+
+// VerifyApprovalFile verifies path+ApprovalSuffix as an approval of manifest,
+// the signature that path's manifest verified against. It doesn't read
+// path+Suffix again, which could have changed since.
+func (k *Key) VerifyApprovalFile(path string, manifest *Signature) (*Signature, error) {
+	raw, err := os.ReadFile(path + ApprovalSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read approval: %w", err)
+	}
+	var approval Signature
+	if err := json.Unmarshal(raw, &approval); err != nil {
+		return nil, fmt.Errorf("%s: %w", path+ApprovalSuffix, err)
+	}
+	if err := k.verify(purposeApproval, approved(manifest), &approval); err != nil {
+		return &approval, err
+	}
+	return &approval, nil
+}
+
+var errHMACApproval = errors.New("two-person approval needs Ed25519 keys: anyone who can verify an HMAC signature can also forge it")
+
+// FourEyes checks that approval came from someone other than whoever signed
+// the manifest: both signed with Ed25519, so neither key's holder could have
+// made the other signature, with different keys, and by a named approver who
+// isn't the named signer. The names are as given; the keys are the proof.
+func FourEyes(manifest, approval *Signature) error {
+	switch {
+	case manifest.Algorithm != Ed25519 || approval.Algorithm != Ed25519:
+		return errHMACApproval
+	case manifest.Signer == "":
+		return errors.New("the manifest doesn't name its signer; sign plans with 'plan'")
+	case approval.KeyID == manifest.KeyID:
+		return fmt.Errorf("the approval is signed with the manifest's own key %s; approve with another key", approval.KeyID)
+	case approval.Signer == "":
+		return errors.New("the approval doesn't name its approver")
+	case approval.Signer == manifest.Signer:
+		return fmt.Errorf("%s signed the manifest and can't also approve it", approval.Signer)
+	}
+	return nil
+}
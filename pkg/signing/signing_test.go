@@ -0,0 +1,322 @@
+package signing
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var signedAt = time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+
+// writeKey writes the PEM of an Ed25519 key (private, or public with pub)
+// and loads it back.
+func writeKey(t *testing.T, priv ed25519.PrivateKey, pub bool) *Key {
+	t.Helper()
+	var block *pem.Block
+	if pub {
+		der, err := x509.MarshalPKIXPublicKey(priv.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	} else {
+		der, err := x509.MarshalPKCS8PrivateKey(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	k, err := LoadKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// newKeyPair returns the private and public halves of a fresh Ed25519 key.
+func newKeyPair(t *testing.T) (priv, pub *Key) {
+	t.Helper()
+	_, k, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeKey(t, k, false), writeKey(t, k, true)
+}
+
+func hmacKey(t *testing.T, secret string) *Key {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte(secret+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	k, err := LoadKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestSignVerifyRoundTrip(t *testing.T) {
+	priv, pub := newKeyPair(t)
+	for name, keys := range map[string][2]*Key{
+		"ed25519": {priv, pub},
+		"hmac":    {hmacKey(t, strings.Repeat("s", 32)), hmacKey(t, strings.Repeat("s", 32))},
+	} {
+		t.Run(name, func(t *testing.T) {
+			manifest := []byte(`{"key":"a"}` + "\n")
+			sig, err := keys[0].Sign(manifest, "alice", signedAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := keys[1].Verify(manifest, sig); err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+		})
+	}
+}
+
+// Signatures written by earlier releases must keep verifying, so what a
+// manifest signature covers can't change.
+func TestManifestSignatureIsStable(t *testing.T) {
+	sig, err := hmacKey(t, strings.Repeat("s", 32)).Sign([]byte(`{"key":"a"}`+"\n"), "alice", signedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "8aUnpashTra/IsWYDxt3TOOCWiNo3RIDd9aqp5igfFM="; sig.Value != want {
+		t.Errorf("signature = %s, want %s", sig.Value, want)
+	}
+}
+
+func TestPublicKeyCannotSign(t *testing.T) {
+	_, pub := newKeyPair(t)
+	if pub.CanSign() {
+		t.Error("a public key reports CanSign")
+	}
+	if _, err := pub.Sign([]byte("x"), "", signedAt); err == nil {
+		t.Error("a public key signed")
+	}
+}
+
+func TestShortHMACSecretRejected(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("too short"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKey(path); err == nil {
+		t.Error("loaded a 9-byte HMAC secret")
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	priv, pub := newKeyPair(t)
+	manifest := []byte("{\"key\":\"a\"}\n{\"key\":\"b\"}\n")
+	sig, err := priv.Sign(manifest, "alice", signedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edited := bytes.Replace(manifest, []byte(`"b"`), []byte(`"c"`), 1)
+	if err := pub.Verify(edited, sig); err == nil {
+		t.Error("an edited manifest verified")
+	}
+	for name, change := range map[string]func(s *Signature){
+		"signer":    func(s *Signature) { s.Signer = "mallory" },
+		"time":      func(s *Signature) { s.Signed = s.Signed.Add(time.Hour) },
+		"digest":    func(s *Signature) { s.SHA256 = strings.Repeat("0", 64) },
+		"signature": func(s *Signature) { s.Value = "AAAA" + s.Value[4:] },
+	} {
+		forged := *sig
+		change(&forged)
+		if err := pub.Verify(manifest, &forged); err == nil {
+			t.Errorf("a signature with a changed %s verified", name)
+		}
+	}
+}
+
+func TestVerifyRejectsOtherKeys(t *testing.T) {
+	priv, _ := newKeyPair(t)
+	_, otherPub := newKeyPair(t)
+	manifest := []byte("{}\n")
+	sig, err := priv.Sign(manifest, "", signedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := otherPub.Verify(manifest, sig); err == nil {
+		t.Error("verified with another Ed25519 key")
+	}
+	if err := hmacKey(t, strings.Repeat("s", 32)).Verify(manifest, sig); err == nil {
+		t.Error("an Ed25519 signature verified with an HMAC secret")
+	}
+
+	hsig, err := hmacKey(t, strings.Repeat("s", 32)).Sign(manifest, "", signedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hmacKey(t, strings.Repeat("t", 32)).Verify(manifest, hsig); err == nil {
+		t.Error("verified with another HMAC secret")
+	}
+}
+
+func TestSignFileVerifyFile(t *testing.T) {
+	priv, pub := newKeyPair(t)
+	path := filepath.Join(t.TempDir(), "plan.jsonl")
+	manifest := []byte(`{"key":"a"}` + "\n")
+	if err := os.WriteFile(path, manifest, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := priv.SignFile(path, "alice", signedAt); err != nil {
+		t.Fatal(err)
+	}
+	got, sig, err := pub.VerifyFile(path, "")
+	if err != nil {
+		t.Fatalf("VerifyFile: %v", err)
+	}
+	if !bytes.Equal(got, manifest) || sig.Signer != "alice" {
+		t.Errorf("VerifyFile = %q signed by %q, want the manifest signed by alice", got, sig.Signer)
+	}
+
+	if err := os.WriteFile(path, append(manifest, `{"key":"b"}`+"\n"...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := pub.VerifyFile(path, ""); err == nil {
+		t.Error("VerifyFile accepted a manifest appended to after signing")
+	}
+}
+
+// approvedPlan signs a plan as alice and approves it as bob.
+func approvedPlan(t *testing.T) (path string, plan, approval *Signature, planPub, approverPub *Key) {
+	t.Helper()
+	planPriv, planPub := newKeyPair(t)
+	approverPriv, approverPub := newKeyPair(t)
+	path = filepath.Join(t.TempDir(), "plan.jsonl")
+	if err := os.WriteFile(path, []byte(`{"key":"a"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := planPriv.SignFile(path, "alice", signedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	approval, err = approverPriv.ApproveFile(path, plan, "bob", signedAt.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, plan, approval, planPub, approverPub
+}
+
+func TestApprovalRoundTrip(t *testing.T) {
+	path, plan, _, planPub, approverPub := approvedPlan(t)
+	_, verified, err := planPub.VerifyFile(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	approval, err := approverPub.VerifyApprovalFile(path, verified)
+	if err != nil {
+		t.Fatalf("VerifyApprovalFile: %v", err)
+	}
+	if approval.Signer != "bob" {
+		t.Errorf("approver = %q, want bob", approval.Signer)
+	}
+	if err := FourEyes(plan, approval); err != nil {
+		t.Errorf("FourEyes: %v", err)
+	}
+}
+
+func TestApprovalIsBoundToThePlanSignature(t *testing.T) {
+	path, plan, _, _, approverPub := approvedPlan(t)
+
+	// A plan re-signed by its planner, even over the same manifest, isn't the
+	// signature that was approved.
+	resigned := *plan
+	resigned.Signed = resigned.Signed.Add(time.Minute)
+	if _, err := approverPub.VerifyApprovalFile(path, &resigned); err == nil {
+		t.Error("the approval verified against another plan signature")
+	}
+	other := *plan
+	other.SHA256 = strings.Repeat("0", 64)
+	if _, err := approverPub.VerifyApprovalFile(path, &other); err == nil {
+		t.Error("the approval verified against another manifest digest")
+	}
+}
+
+func TestPurposesAreSeparate(t *testing.T) {
+	path, plan, approval, planPub, approverPub := approvedPlan(t)
+
+	// An approval isn't a manifest signature, over any bytes.
+	manifest, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := approverPub.Verify(manifest, approval); err == nil {
+		t.Error("an approval verified as a manifest signature")
+	}
+	if err := approverPub.Verify(approved(plan), approval); err == nil {
+		t.Error("an approval verified as a signature of what it approves")
+	}
+	// Nor is a manifest signature an approval.
+	if err := os.WriteFile(path+ApprovalSuffix, mustRead(t, path+Suffix), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := planPub.VerifyApprovalFile(path, plan); err == nil {
+		t.Error("a manifest signature verified as an approval")
+	}
+}
+
+func TestFourEyes(t *testing.T) {
+	plan := &Signature{Algorithm: Ed25519, KeyID: "k1", Signer: "alice"}
+	for _, tc := range []struct {
+		name     string
+		plan     *Signature
+		approval *Signature
+		ok       bool
+	}{
+		{"different people and keys", plan, &Signature{Algorithm: Ed25519, KeyID: "k2", Signer: "bob"}, true},
+		{"same key", plan, &Signature{Algorithm: Ed25519, KeyID: "k1", Signer: "bob"}, false},
+		{"same person", plan, &Signature{Algorithm: Ed25519, KeyID: "k2", Signer: "alice"}, false},
+		{"unnamed approver", plan, &Signature{Algorithm: Ed25519, KeyID: "k2"}, false},
+		{"unnamed planner", &Signature{Algorithm: Ed25519, KeyID: "k1"}, &Signature{Algorithm: Ed25519, KeyID: "k2", Signer: "bob"}, false},
+		{"hmac approval", plan, &Signature{Algorithm: HMACSHA256, KeyID: "k2", Signer: "bob"}, false},
+		{"hmac plan", &Signature{Algorithm: HMACSHA256, KeyID: "k1", Signer: "alice"}, &Signature{Algorithm: Ed25519, KeyID: "k2", Signer: "bob"}, false},
+	} {
+		if err := FourEyes(tc.plan, tc.approval); (err == nil) != tc.ok {
+			t.Errorf("%s: FourEyes = %v, want ok %v", tc.name, err, tc.ok)
+		}
+	}
+}
+
+func TestHMACKeysCannotApprove(t *testing.T) {
+	priv, _ := newKeyPair(t)
+	path := filepath.Join(t.TempDir(), "plan.jsonl")
+	if err := os.WriteFile(path, []byte("{}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := priv.SignFile(path, "alice", signedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hmacKey(t, strings.Repeat("s", 32)).ApproveFile(path, plan, "bob", signedAt); err == nil {
+		t.Error("an HMAC secret approved a plan")
+	}
+	if _, err := os.Stat(path + ApprovalSuffix); err == nil {
+		t.Error("a refused approval left a file behind")
+	}
+}
+
+func mustRead(t *testing.T, path string) []byte {
+	t.Helper()
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
@@ -0,0 +1,134 @@
+// Package tiering finds prefixes that would be cheaper in S3
+// Intelligent-Tiering, for data that is cold but can't simply be deleted.
+package tiering
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aslinger/s3-tidy/pkg/cost"
+	"github.com/aslinger/s3-tidy/pkg/policy"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Advice is what a Recommendation suggests doing with a prefix.
+type Advice string
+
+const (
+	// AdviceTier means moving the prefix to Intelligent-Tiering saves more, or
+	// its data is still read and must stay.
+	AdviceTier Advice = "tier"
+	// AdviceDelete means deleting the prefix's stale data saves at least as
+	// much and nothing there was read recently.
+	AdviceDelete Advice = "delete"
+)
+
+// Object is what the analyzer needs to know about one listed object.
+type Object struct {
+	Key          string
+	Size         int64
+	StorageClass string
+	LastModified time.Time
+	// LastRead is the last read the access logs show; zero means none known.
+	LastRead time.Time
+	// Stale means the policy would delete the object on age alone.
+	Stale bool
+}
+
+// Recommendation sums one prefix's Standard data. Costs are USD per month.
+type Recommendation struct {
+	Prefix       string `json:"prefix"`
+	Objects      int    `json:"objects"`
+	Bytes        int64  `json:"bytes"`
+	RecentlyRead int    `json:"recently_read"`
+
+	CurrentCost    float64 `json:"current_cost_usd"`
+	TieringCost    float64 `json:"tiering_cost_usd"`
+	TieringSavings float64 `json:"tiering_savings_usd"`
+
+	StaleObjects    int     `json:"stale_objects"`
+	StaleBytes      int64   `json:"stale_bytes"`
+	DeletionSavings float64 `json:"deletion_savings_usd"`
+
+	Advice Advice `json:"advice"`
+}
+
+// Analyzer groups Standard objects by prefix and projects what they would
+// cost in Intelligent-Tiering. Without access logs an object's idle time is
+// taken from LastModified, on the heuristic that old data is rarely read. It
+// holds one total per prefix, not per object.
+type Analyzer struct {
+	now      time.Time
+	depth    int
+	pricing  cost.Pricing
+	byPrefix map[string]*Recommendation
+}
+
+// NewAnalyzer groups keys by their first depth segments (0 means 1) and
+// prices them with p, measuring idle time up to now.
+func NewAnalyzer(now time.Time, depth int, p cost.Pricing) *Analyzer {
+	if depth <= 0 {
+		depth = 1
+	}
+	return &Analyzer{now: now, depth: depth, pricing: p, byPrefix: make(map[string]*Recommendation)}
+}
+
+// Observe adds one object. Only Standard objects large enough for
+// Intelligent-Tiering to move are counted.
+func (a *Analyzer) Observe(o Object) {
+	if o.StorageClass != "" && o.StorageClass != string(types.ObjectStorageClassStandard) {
+		return
+	}
+	if o.Size < cost.IntelligentTieringMinObjectSize {
+		return
+	}
+	prefix := policy.GroupKey(o.Key, a.depth)
+	r, ok := a.byPrefix[prefix]
+	if !ok {
+		r = &Recommendation{Prefix: prefix}
+		a.byPrefix[prefix] = r
+	}
+
+	lastAccess := o.LastModified
+	if o.LastRead.After(lastAccess) {
+		lastAccess = o.LastRead
+	}
+	current := a.pricing.MonthlySavings(o.Size, o.StorageClass)
+
+	r.Objects++
+	r.Bytes += o.Size
+	if !o.LastRead.IsZero() {
+		r.RecentlyRead++
+	}
+	r.CurrentCost += current
+	r.TieringCost += a.pricing.IntelligentTieringCost(o.Size, a.now.Sub(lastAccess))
+	if o.Stale && o.LastRead.IsZero() {
+		r.StaleObjects++
+		r.StaleBytes += o.Size
+		r.DeletionSavings += current
+	}
+}
+
+// Recommendations returns the prefixes Intelligent-Tiering would save money
+// on, biggest saving first.
+func (a *Analyzer) Recommendations() []Recommendation {
+	var recs []Recommendation
+	for _, r := range a.byPrefix {
+		r.TieringSavings = r.CurrentCost - r.TieringCost
+		if r.TieringSavings <= 0 {
+			continue
+		}
+		r.Advice = AdviceTier
+		if r.RecentlyRead == 0 && r.DeletionSavings >= r.TieringSavings {
+			r.Advice = AdviceDelete
+		}
+		recs = append(recs, *r)
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		if recs[i].TieringSavings != recs[j].TieringSavings {
+			return recs[i].TieringSavings > recs[j].TieringSavings
+		}
+		return recs[i].Prefix < recs[j].Prefix
+	})
+	return recs
+}
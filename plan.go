@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aslinger/s3-tidy/internal/humanize"
+	"github.com/aslinger/s3-tidy/pkg/cost"
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/aslinger/s3-tidy/pkg/signing"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/spf13/cobra"
+)
+
+// Plan Flags
+var (
+	planOut      string
+	planKey      string
+	planIdentity string
+)
+
+func newPlanCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Write a signed plan of the stale objects a run would delete, for approval",
+		Long: `Scans a bucket as a dry run and writes the objects it would delete to --out
+as a JSON-lines manifest, signed with --key and the planner's identity (the
+AWS caller ARN, or --identity). Someone else then reviews it with 'approve',
+and 'apply' deletes exactly those objects once the approval verifies.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := resolvePricing(cmd, cost.Pricing{}); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			if err := runPlan(context.Background(), cmd); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+		},
+	}
+	addSelectionFlags(cmd)
+	cmd.Flags().StringVarP(&planOut, "out", "o", "", "File to write the plan to; the signature goes to <out>.sig (required)")
+	cmd.Flags().StringVar(&planKey, "key", "", "Planner's Ed25519 private key (PEM) to sign the plan with (required)")
+	cmd.Flags().StringVar(&planIdentity, "identity", "", "Who is signing, recorded in the signature (default: the AWS caller ARN)")
+	addRedactFlag(cmd)
+	addPricingFlags(cmd)
+	addProviderFlags(cmd)
+	cmd.MarkFlagsOneRequired("bucket", "container")
+	cmd.MarkFlagRequired("out")
+	cmd.MarkFlagRequired("key")
+	return cmd
+}
+
+func runPlan(ctx context.Context, cmd *cobra.Command) error {
+	key, err := signing.LoadKey(planKey)
+	if err != nil {
+		return err
+	}
+	if !key.CanSign() {
+		return fmt.Errorf("--key is a public key; sign the plan with the private key")
+	}
+	if key.Algorithm() != signing.Ed25519 {
+		return fmt.Errorf("--key must be an Ed25519 private key: whoever can verify an HMAC-signed plan could also forge it")
+	}
+	who, err := principal(ctx, planIdentity)
+	if err != nil {
+		return err
+	}
+	opts, err := scanOptions(policyFromFlags(cmd), time.Now())
+	if err != nil {
+		return err
+	}
+	// A plan only records; deleting is apply's job.
+	opts.DryRun = true
+	opts.Out = progressWriter()
+
+	sc, err := newScanner(ctx)
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(planOut)
+	if err != nil {
+		return fmt.Errorf("unable to create plan: %w", err)
+	}
+	opts.Sinks = []scanner.Sink{scanner.NewManifestSink(out)}
+	res, err := sc.Run(ctx, opts)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	printRunSummary(os.Stdout, res)
+	planned, bytes, err := planTotals(planOut)
+	if err != nil {
+		return err
+	}
+	if planned == 0 {
+		fmt.Println("✅ Nothing to delete; no plan written.")
+		return os.Remove(planOut)
+	}
+	sig, err := key.SignFile(planOut, who, time.Now())
+	if err != nil {
+		return fmt.Errorf("unable to sign plan: %w", err)
+	}
+	fmt.Printf("📝 Plan of %d objects (%s) signed by %s (%s, key %s): %s\n", planned, humanize.Bytes(bytes), who, sig.Algorithm, sig.KeyID, planOut)
+	fmt.Printf("👀 Next, someone else runs: s3-tidy approve --plan %s\n", planOut)
+	return nil
+}
+
+// planTotals counts the objects of the plan at path and their bytes.
+func planTotals(path string) (int, int64, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	_, objects, err := scanner.PlannedObjects(raw)
+	if err != nil {
+		return 0, 0, err
+	}
+	var bytes int64
+	for _, o := range objects {
+		bytes += o.Size
+	}
+	return len(objects), bytes, nil
+}
+
+// principal is who is running the command, for plan and approval signatures:
+// override when set, else the ARN the AWS credentials resolve to.
+func principal(ctx context.Context, override string) (string, error) {
+	if override != "" {
+		return override, nil
+	}
+	if provider != "" && provider != "s3" {
+		return "", fmt.Errorf("--identity is required with --provider %s", provider)
+	}
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return "", fmt.Errorf("unable to load SDK config: %w", err)
+	}
+	id, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve who you are (or pass --identity): %w", err)
+	}
+	return aws.ToString(id.Arn), nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/aslinger/s3-tidy/pkg/cost"
+	"github.com/spf13/cobra"
+)
+
+// Pricing Flags (shared by scan, report and daemon)
+var (
+	pricePerGB   float64
+	classPrices  map[string]string
+	currency     string
+	currencyRate float64
+
+	// pricing is what scanOptions hands every scan, set once per command by
+	// resolvePricing (or from the policy file in Lambda).
+	pricing cost.Pricing
+)
+
+func addPricingFlags(cmd *cobra.Command) {
+	cmd.Flags().Float64Var(&pricePerGB, "price-per-gb", 0, fmt.Sprintf("S3 Standard rate in USD per GB-month, e.g. a negotiated discount (default %g)", cost.StandardPricePerGB))
+	cmd.Flags().StringToStringVar(&classPrices, "price-per-gb-class", nil, "Rates in USD per GB-month for other storage classes, e.g. STANDARD_IA=0.01,GLACIER_IR=0.0035")
+	cmd.Flags().StringVar(&currency, "currency", "USD", "Currency savings are reported in (ISO 4217 code, e.g. EUR)")
+	cmd.Flags().Float64Var(&currencyRate, "currency-rate", 0, "Units of --currency per USD, e.g. 0.92 for EUR (required unless --currency is USD)")
+}
+
+// resolvePricing layers the pricing flags the user actually set over base (a
+// policies.yaml's pricing section, or the zero value) and stores the result
+// for scanOptions.
+func resolvePricing(cmd *cobra.Command, base cost.Pricing) error {
+	p := base
+	if cmd.Flags().Changed("price-per-gb") {
+		p.PricePerGB = pricePerGB
+	}
+	if len(classPrices) > 0 {
+		classes := make(map[string]float64, len(base.StorageClasses)+len(classPrices))
+		for class, price := range base.StorageClasses {
+			classes[class] = price
+		}
+		for class, raw := range classPrices {
+			price, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return fmt.Errorf("--price-per-gb-class %s: %w", class, err)
+			}
+			classes[class] = price
+		}
+		p.StorageClasses = classes
+	}
+	if cmd.Flags().Changed("currency") {
+		p.Currency = currency
+	}
+	if cmd.Flags().Changed("currency-rate") {
+		p.Rate = currencyRate
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	pricing = p
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/aslinger/s3-tidy/pkg/azure"
+	"github.com/aslinger/s3-tidy/pkg/gcs"
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/spf13/cobra"
+)
+
+// Provider Flags (shared by scan, report and daemon)
+var (
+	provider     string
+	azureAccount string
+)
+
+func addProviderFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&provider, "provider", "s3", "Storage service to scan: s3, gcs for Google Cloud Storage or azure for Azure Blob Storage")
+	cmd.Flags().StringVar(&azureAccount, "azure-account", os.Getenv("AZURE_STORAGE_ACCOUNT"), "Azure storage account name (or blob endpoint URL) holding the containers, with --provider azure")
+}
+
+// newScanner builds a scanner for --provider: on S3 with the default
+// credential chain (SSO, env vars or ~/.aws/credentials), on GCS with
+// Application Default Credentials and on Azure with DefaultAzureCredential.
+func newScanner(ctx context.Context) (*scanner.Scanner, error) {
+	switch provider {
+	case "", "s3":
+		cfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load SDK config: %w", err)
+		}
+		return scanner.NewFromConfig(cfg), nil
+	case "gcs":
+		client, err := gcs.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return scanner.New(client), nil
+	case "azure":
+		if azureAccount == "" {
+			return nil, fmt.Errorf("--provider azure needs --azure-account or AZURE_STORAGE_ACCOUNT")
+		}
+		client, err := azure.New(azureAccount)
+		if err != nil {
+			return nil, err
+		}
+		return scanner.New(client), nil
+	}
+	return nil, fmt.Errorf("unknown --provider %q (use s3, gcs or azure)", provider)
+}
@@ -0,0 +1,702 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/aslinger/s3-tidy/internal/humanize"
+	"github.com/aslinger/s3-tidy/pkg/cost"
+	"github.com/aslinger/s3-tidy/pkg/notify"
+	"github.com/aslinger/s3-tidy/pkg/policy"
+	"github.com/aslinger/s3-tidy/pkg/scanner"
+	"github.com/aslinger/s3-tidy/pkg/tiering"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/spf13/cobra"
+)
+
+// Report Flags
+var (
+	reportConfig  string
+	reportFormat  string
+	reportOut     string
+	reportEmail   []string
+	reportFrom    string
+	reportSubject string
+	reportTiering bool
+	tieringDepth  int
+	prefixDepth   int
+	topPrefixes   int
+)
+
+func newReportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Produce a FinOps cost report (text, HTML or Markdown), optionally emailed via SES",
+		Long: `Scans one bucket (--bucket plus the usual selection flags) or every policy in
+--config in report mode, never deleting anything, and renders the result.
+With --email the HTML report (plus a Markdown text part) is sent through SES.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runReport(cmd); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+		},
+	}
+
+	addSelectionFlags(cmd)
+	cmd.Flags().StringVarP(&reportConfig, "config", "c", "", "Report on every policy in this policies.yaml instead of a single --bucket")
+	cmd.Flags().StringVar(&reportFormat, "format", "text", "Output format: text, html or markdown")
+	cmd.Flags().StringVarP(&reportOut, "out", "o", "", "Write the rendered report to this file instead of stdout")
+	cmd.Flags().StringSliceVar(&reportEmail, "email", nil, "Email the report to these recipients via SES (comma-separated)")
+	cmd.Flags().StringVar(&reportFrom, "email-from", "", "Verified SES sender address (required with --email)")
+	cmd.Flags().StringVar(&reportSubject, "email-subject", "", "Email subject (default: \"s3-tidy FinOps report – <date>\")")
+	cmd.Flags().BoolVar(&reportTiering, "tiering", false, "Recommend prefixes to move to S3 Intelligent-Tiering, with projected savings versus deletion")
+	cmd.Flags().IntVar(&tieringDepth, "tiering-depth", 1, "Key segments that make up a prefix in --tiering recommendations")
+	cmd.Flags().IntVar(&prefixDepth, "prefix-depth", 1, "Key segments that make up a prefix in the per-prefix breakdown")
+	cmd.Flags().IntVar(&topPrefixes, "top-prefixes", 20, "Prefixes listed per policy in the breakdown, by stale bytes; the rest are summed into one row (0 = all)")
+	addRedactFlag(cmd)
+	addPricingFlags(cmd)
+	addProviderFlags(cmd)
+	cmd.MarkFlagsMutuallyExclusive("bucket", "config")
+	cmd.MarkFlagsMutuallyExclusive("container", "config")
+	return cmd
+}
+
+func runReport(cmd *cobra.Command) error {
+	switch reportFormat {
+	case "text", "html", "markdown":
+	default:
+		return fmt.Errorf("unknown --format %q (use text, html or markdown)", reportFormat)
+	}
+	if len(reportEmail) > 0 && reportFrom == "" {
+		return fmt.Errorf("--email-from is required with --email")
+	}
+
+	var policies []policy.Policy
+	var basePricing cost.Pricing
+	switch {
+	case reportConfig != "":
+		pf, err := policy.LoadFile(reportConfig)
+		if err != nil {
+			return err
+		}
+		policies = pf.Policies
+		basePricing = pf.Pricing
+	case bucketName != "":
+		policies = []policy.Policy{policyFromFlags(cmd)}
+	default:
+		return fmt.Errorf("either --bucket (or --container) or --config is required")
+	}
+	if err := resolvePricing(cmd, basePricing); err != nil {
+		return err
+	}
+
+	// Keep scan progress off stdout when stdout carries the rendered document.
+	var progress io.Writer = os.Stdout
+	if reportFormat != "text" && reportOut == "" {
+		progress = os.Stderr
+	}
+
+	ctx := context.Background()
+	sc, err := newScanner(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var results []*scanner.Result
+	var prefixes []prefixRow
+	var classes []classRow
+	for _, p := range policies {
+		p.Report = true
+		opts, err := scanOptions(p, now)
+		if err != nil {
+			return fmt.Errorf("policy %q: %w", p.Name, err)
+		}
+		opts.Out = progress
+		if reportTiering {
+			opts.Tiering = tiering.NewAnalyzer(now, tieringDepth, pricing)
+		}
+		totals := newPrefixTotals(prefixDepth)
+		byClass := newClassTotals(now)
+		opts.Sinks = []scanner.Sink{totals, byClass}
+		res, err := sc.Run(ctx, opts)
+		if err != nil {
+			return fmt.Errorf("policy %q: %w", p.Name, err)
+		}
+		results = append(results, res)
+		prefixes = append(prefixes, totals.Rows(res, topPrefixes)...)
+		classes = append(classes, byClass.Rows(res)...)
+	}
+
+	data := newReportData(results, now)
+	data.Prefixes = prefixes
+	data.StorageClasses = classes
+	if reportFormat == "text" {
+		printStorageClasses(os.Stdout, data.StorageClasses)
+		printPrefixes(os.Stdout, data.Prefixes)
+	}
+	if reportFormat == "text" && reportTiering {
+		printTiering(os.Stdout, data.Tiering)
+	}
+	if reportFormat != "text" {
+		doc, err := renderReport(reportFormat, data)
+		if err != nil {
+			return err
+		}
+		if reportOut == "" {
+			fmt.Print(doc)
+		} else if err := os.WriteFile(reportOut, []byte(doc), 0o644); err != nil {
+			return err
+		}
+	}
+
+	if len(reportEmail) == 0 {
+		return nil
+	}
+
+	subject := reportSubject
+	if subject == "" {
+		subject = "s3-tidy FinOps report – " + now.Format("2006-01-02")
+	}
+	// Email goes through SES whichever provider was scanned.
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to load SDK config: %w", err)
+	}
+	if err := emailReport(ctx, cfg, reportFrom, reportEmail, subject, data); err != nil {
+		return fmt.Errorf("unable to email report: %w", err)
+	}
+	fmt.Fprintf(progress, "📧 Report emailed to %s\n", strings.Join(reportEmail, ", "))
+	return nil
+}
+
+// reportData is what the report templates render.
+type reportData struct {
+	Generated  time.Time
+	Results    []*scanner.Result
+	Scanned    int
+	Stale      int
+	StaleBytes int64
+	Savings    float64 // in Currency
+	// RequestCost is what the scans spent on S3 API calls, and NetSavings the
+	// first month's savings after it, both in Currency.
+	RequestCost float64
+	NetSavings  float64
+	Currency    string
+	PricePerGB  string // S3 Standard rate, formatted in Currency
+	// StorageClasses breaks every policy's stale objects down by storage
+	// class, and each class by age, under the AgeBands headings.
+	StorageClasses []classRow
+	AgeBands       []string
+	// Prefixes breaks every policy's stale objects down by prefix.
+	Prefixes []prefixRow
+	// Tiering holds the --tiering recommendations of every policy.
+	Tiering []tieringRow
+}
+
+// prefixRow is one prefix of the breakdown, savings in Currency. A Label row
+// names something other than a key prefix: the bucket root, or the sum of
+// the prefixes past --top-prefixes.
+type prefixRow struct {
+	Policy, Bucket, Prefix string
+	Label                  bool
+	Stale                  int
+	StaleBytes             int64
+	Savings                float64
+	Currency               string
+}
+
+// prefixTotals is a scanner.Sink totalling one report scan's stale objects
+// per prefix. It holds one entry per prefix, never per object.
+type prefixTotals struct {
+	depth    int
+	byPrefix map[string]*prefixRow
+}
+
+func newPrefixTotals(depth int) *prefixTotals {
+	return &prefixTotals{depth: depth, byPrefix: make(map[string]*prefixRow)}
+}
+
+func (t *prefixTotals) Write(f scanner.Finding) error {
+	// Objects kept for replication or encryption are findings but not stale.
+	if f.Outcome != scanner.OutcomeStale {
+		return nil
+	}
+	prefix := policy.GroupKey(f.Key, t.depth)
+	row, ok := t.byPrefix[prefix]
+	if !ok {
+		row = &prefixRow{Prefix: prefix}
+		t.byPrefix[prefix] = row
+	}
+	row.Stale++
+	row.StaleBytes += f.Size
+	row.Savings += pricing.MonthlySavings(f.Size, f.StorageClass)
+	return nil
+}
+
+func (t *prefixTotals) Flush() error { return nil }
+
+// Rows returns the prefixes of res by stale bytes, largest first, with any
+// past the top n summed into one row. Prefixes are grouped before they
+// are redacted, so --redact-keys hash still totals per prefix.
+func (t *prefixTotals) Rows(res *scanner.Result, n int) []prefixRow {
+	rows := make([]prefixRow, 0, len(t.byPrefix))
+	for _, r := range t.byPrefix {
+		rows = append(rows, *r)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].StaleBytes != rows[j].StaleBytes {
+			return rows[i].StaleBytes > rows[j].StaleBytes
+		}
+		return rows[i].Prefix < rows[j].Prefix
+	})
+	if n > 0 && len(rows) > n {
+		other := prefixRow{Prefix: fmt.Sprintf("%d more prefixes", len(rows)-n), Label: true}
+		for _, r := range rows[n:] {
+			other.Stale += r.Stale
+			other.StaleBytes += r.StaleBytes
+			other.Savings += r.Savings
+		}
+		rows = append(rows[:n], other)
+	}
+	redact := redaction()
+	for i := range rows {
+		rows[i].Policy, rows[i].Bucket, rows[i].Currency = res.Policy, res.Bucket, pricing.CurrencyCode()
+		rows[i].Savings = pricing.Convert(rows[i].Savings)
+		switch {
+		case rows[i].Label:
+		case rows[i].Prefix == "":
+			rows[i].Prefix, rows[i].Label = "(bucket root)", true
+		default:
+			rows[i].Prefix = redact.Key(rows[i].Prefix)
+		}
+	}
+	return rows
+}
+
+// ageBands are the age columns of the storage class breakdown, by the upper
+// bound of each in days; the last one is open-ended.
+var ageBands = []struct {
+	label string
+	days  int
+}{
+	{"< 90 days", 90},
+	{"90 days – 1 year", 365},
+	{"1–2 years", 730},
+	{"2+ years", 0},
+}
+
+func ageBandLabels() []string {
+	labels := make([]string, len(ageBands))
+	for i, b := range ageBands {
+		labels[i] = b.label
+	}
+	return labels
+}
+
+// classRow is one storage class of the breakdown, savings in Currency. Ages
+// splits its stale objects by the age the policy judged them by.
+type classRow struct {
+	Policy, Bucket, StorageClass string
+	Stale                        int
+	StaleBytes                   int64
+	Savings                      float64
+	Currency                     string
+	Ages                         []ageCell
+}
+
+// ageCell is the stale objects of one class within one age band.
+type ageCell struct {
+	Stale int
+	Bytes int64
+}
+
+// classTotals is a scanner.Sink totalling one report scan's stale objects
+// per storage class and age band, so a report shows how much stale data is
+// already cold and how much still pays Standard rates.
+type classTotals struct {
+	now     time.Time
+	byClass map[string]*classRow
+}
+
+func newClassTotals(now time.Time) *classTotals {
+	return &classTotals{now: now, byClass: make(map[string]*classRow)}
+}
+
+func (t *classTotals) Write(f scanner.Finding) error {
+	if f.Outcome != scanner.OutcomeStale {
+		return nil
+	}
+	class := f.StorageClass
+	if class == "" {
+		class = "STANDARD"
+	}
+	row, ok := t.byClass[class]
+	if !ok {
+		row = &classRow{StorageClass: class, Ages: make([]ageCell, len(ageBands))}
+		t.byClass[class] = row
+	}
+	row.Stale++
+	row.StaleBytes += f.Size
+	row.Savings += pricing.MonthlySavings(f.Size, f.StorageClass)
+
+	days := int(t.now.Sub(f.ModTime).Hours() / 24)
+	band := len(ageBands) - 1
+	for i, b := range ageBands[:band] {
+		if days < b.days {
+			band = i
+			break
+		}
+	}
+	row.Ages[band].Stale++
+	row.Ages[band].Bytes += f.Size
+	return nil
+}
+
+func (t *classTotals) Flush() error { return nil }
+
+// Rows returns the storage classes of res by stale bytes, largest first.
+func (t *classTotals) Rows(res *scanner.Result) []classRow {
+	rows := make([]classRow, 0, len(t.byClass))
+	for _, r := range t.byClass {
+		r.Policy, r.Bucket, r.Currency = res.Policy, res.Bucket, pricing.CurrencyCode()
+		r.Savings = pricing.Convert(r.Savings)
+		rows = append(rows, *r)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].StaleBytes != rows[j].StaleBytes {
+			return rows[i].StaleBytes > rows[j].StaleBytes
+		}
+		return rows[i].StorageClass < rows[j].StorageClass
+	})
+	return rows
+}
+
+// tieringRow is one Intelligent-Tiering recommendation, amounts in Currency.
+type tieringRow struct {
+	Policy, Bucket, Prefix string
+	Objects                int
+	Bytes                  int64
+	RecentlyRead           int
+	TieringSavings         float64
+	DeletionSavings        float64
+	Advice                 tiering.Advice
+	Currency               string
+}
+
+func newReportData(results []*scanner.Result, generated time.Time) reportData {
+	d := reportData{Generated: generated, Results: results, Currency: pricing.CurrencyCode(), PricePerGB: pricing.Format(pricing.PerGB(""), 4), AgeBands: ageBandLabels()}
+	for _, r := range results {
+		d.Scanned += r.Scanned
+		d.Stale += r.Stale
+		d.StaleBytes += r.StaleBytes
+		d.Savings += r.EstimatedSavingsLocal
+		d.RequestCost += pricing.Convert(r.RequestCost)
+		for _, t := range r.Tiering {
+			d.Tiering = append(d.Tiering, tieringRow{
+				Policy: r.Policy, Bucket: r.Bucket, Prefix: t.Prefix,
+				Objects: t.Objects, Bytes: t.Bytes, RecentlyRead: t.RecentlyRead,
+				TieringSavings:  pricing.Convert(t.TieringSavings),
+				DeletionSavings: pricing.Convert(t.DeletionSavings),
+				Advice:          t.Advice,
+				Currency:        d.Currency,
+			})
+		}
+	}
+	d.NetSavings = d.Savings - d.RequestCost
+	return d
+}
+
+var reportFuncs = map[string]any{
+	"bytes": humanize.Bytes,
+	"money": func(v float64, currency string) string { return cost.FormatAmount(v, currency, 2) },
+	"date":  func(t time.Time) string { return t.Format("2006-01-02 15:04 MST") },
+	// Pipes would break the Markdown table.
+	"md": func(s string) string { return strings.ReplaceAll(s, "|", `\|`) },
+}
+
+const markdownReportTemplate = `# s3-tidy FinOps Report
+
+Generated {{ date .Generated }}
+
+| Policy | Bucket | Objects Scanned | Stale Objects | Reclaimable | Est. Monthly Savings |
+|---|---|---:|---:|---:|---:|
+{{- range .Results }}
+| {{ md .Policy }} | ` + "`{{ .Bucket }}`" + ` | {{ .Scanned }} | {{ .Stale }} | {{ bytes .StaleBytes }} | {{ money .EstimatedSavingsLocal .Currency }} |
+{{- end }}
+| **Total** | | **{{ .Scanned }}** | **{{ .Stale }}** | **{{ bytes .StaleBytes }}** | **{{ money .Savings .Currency }}** |
+
+_Based on S3 Standard pricing of ~{{ .PricePerGB }}/GB-month. These scans cost ~{{ money .RequestCost .Currency }} in S3 API requests, for net first-month savings of ~{{ money .NetSavings .Currency }}._
+{{- if .StorageClasses }}
+
+## Stale Objects by Storage Class
+
+| Policy | Bucket | Storage Class | Stale Objects | Reclaimable | Est. Monthly Savings |{{ range .AgeBands }} {{ . }} |{{ end }}
+|---|---|---|---:|---:|---:|{{ range .AgeBands }}---:|{{ end }}
+{{- range .StorageClasses }}
+| {{ md .Policy }} | ` + "`{{ .Bucket }}`" + ` | {{ .StorageClass }} | {{ .Stale }} | {{ bytes .StaleBytes }} | {{ money .Savings .Currency }} |{{ range .Ages }} {{ if .Stale }}{{ bytes .Bytes }} ({{ .Stale }}){{ else }}–{{ end }} |{{ end }}
+{{- end }}
+
+_Ages are as the policy judged them (last modified, or the date in the key), grouped by reclaimable size with object counts in parentheses._
+{{- end }}
+{{- if .Prefixes }}
+
+## Stale Objects by Prefix
+
+| Policy | Bucket | Prefix | Stale Objects | Reclaimable | Est. Monthly Savings |
+|---|---|---|---:|---:|---:|
+{{- range .Prefixes }}
+| {{ md .Policy }} | ` + "`{{ .Bucket }}`" + ` | {{ if .Label }}_{{ .Prefix }}_{{ else }}` + "`{{ md .Prefix }}`" + `{{ end }} | {{ .Stale }} | {{ bytes .StaleBytes }} | {{ money .Savings .Currency }} |
+{{- end }}
+{{- end }}
+{{- if .Tiering }}
+
+## Intelligent-Tiering Candidates
+
+| Policy | Prefix | Objects | Size | Read Recently | Tiering Saves | Deleting Stale Saves | Advice |
+|---|---|---:|---:|---:|---:|---:|---|
+{{- range .Tiering }}
+| {{ md .Policy }} | ` + "`{{ md .Prefix }}`" + ` | {{ .Objects }} | {{ bytes .Bytes }} | {{ .RecentlyRead }} | {{ money .TieringSavings .Currency }} | {{ money .DeletionSavings .Currency }} | {{ .Advice }} |
+{{- end }}
+
+_Monthly, once objects have settled into their access tiers._
+{{- end }}
+`
+
+// Email clients ignore <style> blocks, so everything is inline.
+const htmlReportTemplate = `<!DOCTYPE html>
+<html>
+<body style="font-family: -apple-system, Segoe UI, Helvetica, Arial, sans-serif; color: #1f2328;">
+<h2 style="margin-bottom: 4px;">s3-tidy FinOps Report</h2>
+<p style="color: #656d76; margin-top: 0;">Generated {{ date .Generated }}</p>
+<table style="border-collapse: collapse; font-size: 14px;">
+<thead>
+<tr style="background: #f6f8fa;">
+<th style="text-align: left; padding: 6px 12px; border: 1px solid #d0d7de;">Policy</th>
+<th style="text-align: left; padding: 6px 12px; border: 1px solid #d0d7de;">Bucket</th>
+<th style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">Objects Scanned</th>
+<th style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">Stale Objects</th>
+<th style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">Reclaimable</th>
+<th style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">Est. Monthly Savings</th>
+</tr>
+</thead>
+<tbody>
+{{- range .Results }}
+<tr>
+<td style="padding: 6px 12px; border: 1px solid #d0d7de;">{{ .Policy }}</td>
+<td style="padding: 6px 12px; border: 1px solid #d0d7de;"><code>{{ .Bucket }}</code></td>
+<td style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ .Scanned }}</td>
+<td style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ .Stale }}</td>
+<td style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ bytes .StaleBytes }}</td>
+<td style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ money .EstimatedSavingsLocal .Currency }}</td>
+</tr>
+{{- end }}
+<tr style="font-weight: bold; background: #f6f8fa;">
+<td style="padding: 6px 12px; border: 1px solid #d0d7de;" colspan="2">Total</td>
+<td style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ .Scanned }}</td>
+<td style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ .Stale }}</td>
+<td style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ bytes .StaleBytes }}</td>
+<td style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ money .Savings .Currency }}</td>
+</tr>
+</tbody>
+</table>
+<p style="color: #656d76; font-size: 12px;">Based on S3 Standard pricing of ~{{ .PricePerGB }}/GB-month. These scans cost ~{{ money .RequestCost .Currency }} in S3 API requests, for net first-month savings of ~{{ money .NetSavings .Currency }}.</p>
+{{- if .StorageClasses }}
+<h3>Stale Objects by Storage Class</h3>
+<table style="border-collapse: collapse; font-size: 14px;">
+<thead>
+<tr style="background: #f6f8fa;">
+<th style="text-align: left; padding: 6px 12px; border: 1px solid #d0d7de;">Policy</th>
+<th style="text-align: left; padding: 6px 12px; border: 1px solid #d0d7de;">Bucket</th>
+<th style="text-align: left; padding: 6px 12px; border: 1px solid #d0d7de;">Storage Class</th>
+<th style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">Stale Objects</th>
+<th style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">Reclaimable</th>
+<th style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">Est. Monthly Savings</th>
+{{- range .AgeBands }}
+<th style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ . }}</th>
+{{- end }}
+</tr>
+</thead>
+<tbody>
+{{- range .StorageClasses }}
+<tr>
+<td style="padding: 6px 12px; border: 1px solid #d0d7de;">{{ .Policy }}</td>
+<td style="padding: 6px 12px; border: 1px solid #d0d7de;"><code>{{ .Bucket }}</code></td>
+<td style="padding: 6px 12px; border: 1px solid #d0d7de;">{{ .StorageClass }}</td>
+<td style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ .Stale }}</td>
+<td style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ bytes .StaleBytes }}</td>
+<td style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ money .Savings .Currency }}</td>
+{{- range .Ages }}
+<td style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ if .Stale }}{{ bytes .Bytes }} ({{ .Stale }}){{ else }}–{{ end }}</td>
+{{- end }}
+</tr>
+{{- end }}
+</tbody>
+</table>
+<p style="color: #656d76; font-size: 12px;">Ages are as the policy judged them (last modified, or the date in the key), grouped by reclaimable size with object counts in parentheses.</p>
+{{- end }}
+{{- if .Prefixes }}
+<h3>Stale Objects by Prefix</h3>
+<table style="border-collapse: collapse; font-size: 14px;">
+<thead>
+<tr style="background: #f6f8fa;">
+<th style="text-align: left; padding: 6px 12px; border: 1px solid #d0d7de;">Policy</th>
+<th style="text-align: left; padding: 6px 12px; border: 1px solid #d0d7de;">Bucket</th>
+<th style="text-align: left; padding: 6px 12px; border: 1px solid #d0d7de;">Prefix</th>
+<th style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">Stale Objects</th>
+<th style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">Reclaimable</th>
+<th style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">Est. Monthly Savings</th>
+</tr>
+</thead>
+<tbody>
+{{- range .Prefixes }}
+<tr>
+<td style="padding: 6px 12px; border: 1px solid #d0d7de;">{{ .Policy }}</td>
+<td style="padding: 6px 12px; border: 1px solid #d0d7de;"><code>{{ .Bucket }}</code></td>
+<td style="padding: 6px 12px; border: 1px solid #d0d7de;">{{ if .Label }}<em>{{ .Prefix }}</em>{{ else }}<code>{{ .Prefix }}</code>{{ end }}</td>
+<td style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ .Stale }}</td>
+<td style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ bytes .StaleBytes }}</td>
+<td style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ money .Savings .Currency }}</td>
+</tr>
+{{- end }}
+</tbody>
+</table>
+{{- end }}
+{{- if .Tiering }}
+<h3>Intelligent-Tiering Candidates</h3>
+<table style="border-collapse: collapse; font-size: 14px;">
+<thead>
+<tr style="background: #f6f8fa;">
+<th style="text-align: left; padding: 6px 12px; border: 1px solid #d0d7de;">Policy</th>
+<th style="text-align: left; padding: 6px 12px; border: 1px solid #d0d7de;">Prefix</th>
+<th style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">Objects</th>
+<th style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">Size</th>
+<th style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">Read Recently</th>
+<th style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">Tiering Saves</th>
+<th style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">Deleting Stale Saves</th>
+<th style="text-align: left; padding: 6px 12px; border: 1px solid #d0d7de;">Advice</th>
+</tr>
+</thead>
+<tbody>
+{{- range .Tiering }}
+<tr>
+<td style="padding: 6px 12px; border: 1px solid #d0d7de;">{{ .Policy }}</td>
+<td style="padding: 6px 12px; border: 1px solid #d0d7de;"><code>{{ .Prefix }}</code></td>
+<td style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ .Objects }}</td>
+<td style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ bytes .Bytes }}</td>
+<td style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ .RecentlyRead }}</td>
+<td style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ money .TieringSavings .Currency }}</td>
+<td style="text-align: right; padding: 6px 12px; border: 1px solid #d0d7de;">{{ money .DeletionSavings .Currency }}</td>
+<td style="padding: 6px 12px; border: 1px solid #d0d7de;">{{ .Advice }}</td>
+</tr>
+{{- end }}
+</tbody>
+</table>
+<p style="color: #656d76; font-size: 12px;">Monthly, once objects have settled into their access tiers.</p>
+{{- end }}
+</body>
+</html>
+`
+
+var (
+	markdownReport = texttemplate.Must(texttemplate.New("markdown").Funcs(reportFuncs).Parse(markdownReportTemplate))
+	htmlReport     = htmltemplate.Must(htmltemplate.New("html").Funcs(reportFuncs).Parse(htmlReportTemplate))
+)
+
+// printStorageClasses prints the storage class breakdown as a table.
+func printStorageClasses(w io.Writer, rows []classRow) {
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "------------------------------------------------")
+	fmt.Fprintln(w, "🧊 STALE OBJECTS BY STORAGE CLASS (size and count per age band)")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "POLICY\tSTORAGE CLASS\tSTALE OBJECTS\tRECLAIMABLE\tEST. MONTHLY SAVINGS")
+	for _, b := range ageBands {
+		fmt.Fprint(tw, "\t"+strings.ToUpper(b.label))
+	}
+	fmt.Fprintln(tw)
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s", r.Policy, r.StorageClass, r.Stale, humanize.Bytes(r.StaleBytes), cost.FormatAmount(r.Savings, r.Currency, 2))
+		for _, a := range r.Ages {
+			cell := "-"
+			if a.Stale > 0 {
+				cell = fmt.Sprintf("%s (%d)", humanize.Bytes(a.Bytes), a.Stale)
+			}
+			fmt.Fprint(tw, "\t"+cell)
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
+
+// printPrefixes prints the per-prefix breakdown as a table.
+func printPrefixes(w io.Writer, rows []prefixRow) {
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "------------------------------------------------")
+	fmt.Fprintln(w, "📂 STALE OBJECTS BY PREFIX")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "POLICY\tPREFIX\tSTALE OBJECTS\tRECLAIMABLE\tEST. MONTHLY SAVINGS")
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", r.Policy, r.Prefix, r.Stale, humanize.Bytes(r.StaleBytes), cost.FormatAmount(r.Savings, r.Currency, 2))
+	}
+	tw.Flush()
+}
+
+// printTiering prints the --tiering recommendations as a table.
+func printTiering(w io.Writer, rows []tieringRow) {
+	fmt.Fprintln(w, "------------------------------------------------")
+	if len(rows) == 0 {
+		fmt.Fprintln(w, "🧊 No prefixes would be cheaper in Intelligent-Tiering.")
+		return
+	}
+	fmt.Fprintln(w, "🧊 INTELLIGENT-TIERING CANDIDATES (monthly, once objects have settled into their access tiers)")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "POLICY\tPREFIX\tOBJECTS\tSIZE\tREAD RECENTLY\tTIERING SAVES\tDELETING STALE SAVES\tADVICE")
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%d\t%s\t%s\t%s\n", r.Policy, r.Prefix, r.Objects, humanize.Bytes(r.Bytes), r.RecentlyRead,
+			cost.FormatAmount(r.TieringSavings, r.Currency, 2), cost.FormatAmount(r.DeletionSavings, r.Currency, 2), r.Advice)
+	}
+	tw.Flush()
+}
+
+// renderReport renders data as "html" or "markdown".
+func renderReport(format string, data reportData) (string, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "html":
+		err = htmlReport.Execute(&buf, data)
+	case "markdown":
+		err = markdownReport.Execute(&buf, data)
+	default:
+		err = fmt.Errorf("no renderer for format %q", format)
+	}
+	return buf.String(), err
+}
+
+// emailReport sends the report as HTML with a Markdown plain-text alternative,
+// which reads fine in clients that don't render HTML.
+func emailReport(ctx context.Context, cfg aws.Config, from string, to []string, subject string, data reportData) error {
+	html, err := renderReport("html", data)
+	if err != nil {
+		return err
+	}
+	text, err := renderReport("markdown", data)
+	if err != nil {
+		return err
+	}
+	return notify.SendEmail(ctx, cfg, from, to, subject, html, text)
+}
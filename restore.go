@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aslinger/s3-tidy/internal/humanize"
+	"github.com/aslinger/s3-tidy/pkg/restore"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/spf13/cobra"
+)
+
+// Restore Flags
+var (
+	restoreBucket  string
+	restorePrefix  string
+	restoreClasses string
+	restoreDays    int32
+	restoreTier    string
+	restoreDryRun  bool
+	restoreVerbose bool
+)
+
+func newRestoreCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Request restores of archived (Glacier/Deep Archive) objects under a prefix",
+		Long: `Issues a RestoreObject request for every object under --prefix stored in one of
+--storage-class. Restores take minutes (Expedited) to up to 48 hours (Deep Archive
+Bulk); follow them with 's3-tidy restore status'.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runRestore(); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+		},
+	}
+	addRestoreTargetFlags(cmd)
+	cmd.Flags().Int32Var(&restoreDays, "days", 7, "Days the restored copy stays readable")
+	cmd.Flags().StringVar(&restoreTier, "tier", "Standard", "Retrieval tier: Standard, Bulk or Expedited")
+	cmd.Flags().BoolVar(&restoreDryRun, "dry-run", true, "List what would be restored without requesting anything")
+
+	status := &cobra.Command{
+		Use:   "status",
+		Short: "Report restore progress for archived objects under a prefix",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runRestoreStatus(); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+		},
+	}
+	addRestoreTargetFlags(status)
+	status.Flags().BoolVarP(&restoreVerbose, "verbose", "v", false, "Print the state of every object")
+
+	cmd.AddCommand(status)
+	return cmd
+}
+
+func addRestoreTargetFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&restoreBucket, "bucket", "b", "", "Target S3 bucket name (required)")
+	cmd.Flags().StringVar(&restorePrefix, "prefix", "", "Only objects under this key prefix")
+	cmd.Flags().StringVar(&restoreClasses, "storage-class", "GLACIER,DEEP_ARCHIVE", "Comma-separated storage classes to restore")
+	cmd.MarkFlagRequired("bucket")
+}
+
+func restoreOptions() (restore.Options, error) {
+	classes, err := restore.ParseStorageClasses(restoreClasses)
+	if err != nil {
+		return restore.Options{}, err
+	}
+	if err := restore.ValidateTier(restoreTier); err != nil {
+		return restore.Options{}, err
+	}
+	return restore.Options{
+		Bucket:         restoreBucket,
+		Prefix:         restorePrefix,
+		StorageClasses: classes,
+		Days:           restoreDays,
+		Tier:           types.Tier(restoreTier),
+		DryRun:         restoreDryRun,
+	}, nil
+}
+
+func newRestoreClient(ctx context.Context) (*s3.Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load SDK config: %w", err)
+	}
+	return s3.NewFromConfig(cfg), nil
+}
+
+func runRestore() error {
+	opts, err := restoreOptions()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	client, err := newRestoreClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🧊 Restoring archived objects in 's3://%s/%s' (%s tier, %d days)...\n", opts.Bucket, opts.Prefix, opts.Tier, opts.Days)
+	res, err := restore.Start(ctx, client, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("------------------------------------------------")
+	fmt.Printf("   • Archived Objects Found: %d (%s)\n", res.Matched, humanize.Bytes(res.Bytes))
+	if opts.DryRun {
+		fmt.Println("✅ Dry run complete. Run with --dry-run=false to request the restores.")
+		return nil
+	}
+	fmt.Printf("   • Restores Requested: %d\n", res.Requested)
+	if res.InProgress > 0 {
+		fmt.Printf("   • Already In Progress: %d\n", res.InProgress)
+	}
+	if res.Errors > 0 {
+		fmt.Printf("   • Errors: %d\n", res.Errors)
+	}
+	fmt.Printf("✅ Check progress with: s3-tidy restore status --bucket %s --prefix '%s'\n", opts.Bucket, opts.Prefix)
+	return nil
+}
+
+func runRestoreStatus() error {
+	opts, err := restoreOptions()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	client, err := newRestoreClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🔍 Checking restore status in 's3://%s/%s'...\n", opts.Bucket, opts.Prefix)
+	st, err := restore.CheckStatus(ctx, client, opts, restoreVerbose)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("------------------------------------------------")
+	fmt.Printf("   • ✅ Restored: %d\n", st.Restored)
+	fmt.Printf("   • ⏳ In Progress: %d\n", st.InProgress)
+	fmt.Printf("   • 🧊 Not Requested: %d\n", st.Archived)
+	if st.Errors > 0 {
+		fmt.Printf("   • Errors: %d\n", st.Errors)
+	}
+	if !st.Expires.IsZero() {
+		fmt.Printf("   First restored copy expires %s (in %s)\n", st.Expires.Format("2006-01-02 15:04 MST"), humanize.Age(time.Until(st.Expires)))
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Telemetry Flags (scan, delete and daemon)
+var otlpEndpoint string
+
+// telemetryTimeout bounds how long exiting waits for the last spans and
+// metrics to reach the collector.
+const telemetryTimeout = 10 * time.Second
+
+func addTelemetryFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&otlpEndpoint, "otlp-endpoint", "", "Export OpenTelemetry traces and metrics over OTLP/HTTP to this URL, e.g. http://localhost:4318 (default: $OTEL_EXPORTER_OTLP_ENDPOINT; disabled when neither is set)")
+}
+
+// otlpConfigured reports whether an OTLP endpoint was given on the command
+// line or in the standard OTEL_EXPORTER_OTLP_* variables.
+func otlpConfigured() bool {
+	for _, v := range []string{"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"} {
+		if os.Getenv(v) != "" {
+			return true
+		}
+	}
+	return otlpEndpoint != ""
+}
+
+// telemetry is the OTLP export pipeline behind the global providers the
+// scanner reports to.
+type telemetry struct {
+	traces  *sdktrace.TracerProvider
+	metrics *sdkmetric.MeterProvider
+}
+
+// startTelemetry installs OTLP exporters as the global tracer and meter
+// providers, or returns nil when no endpoint is configured. The exporters
+// read the other OTEL_EXPORTER_OTLP_* variables (headers, TLS) themselves.
+func startTelemetry(ctx context.Context) (*telemetry, error) {
+	if !otlpConfigured() {
+		return nil, nil
+	}
+	var traceOpts []otlptracehttp.Option
+	var metricOpts []otlpmetrichttp.Option
+	if otlpEndpoint != "" {
+		// Like $OTEL_EXPORTER_OTLP_ENDPOINT, the flag is the collector's base URL.
+		base := strings.TrimSuffix(otlpEndpoint, "/")
+		traceOpts = append(traceOpts, otlptracehttp.WithEndpointURL(base+"/v1/traces"))
+		metricOpts = append(metricOpts, otlpmetrichttp.WithEndpointURL(base+"/v1/metrics"))
+	}
+	spans, err := otlptracehttp.New(ctx, traceOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to set up OTLP trace export: %w", err)
+	}
+	points, err := otlpmetrichttp.New(ctx, metricOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to set up OTLP metric export: %w", err)
+	}
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults.
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(attribute.String("service.name", "s3-tidy")),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to describe the OpenTelemetry resource: %w", err)
+	}
+
+	t := &telemetry{
+		traces:  sdktrace.NewTracerProvider(sdktrace.WithBatcher(spans), sdktrace.WithResource(res)),
+		metrics: sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(points)), sdkmetric.WithResource(res)),
+	}
+	otel.SetTracerProvider(t.traces)
+	otel.SetMeterProvider(t.metrics)
+	return t, nil
+}
+
+// flush exports everything recorded so far, e.g. before Lambda freezes the
+// process between invocations. Like notifications, export failures are
+// logged and never fail a run. A nil receiver is a no-op.
+func (t *telemetry) flush(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, telemetryTimeout)
+	defer cancel()
+	if err := t.traces.ForceFlush(ctx); err != nil {
+		log.Printf("⚠️ Unable to export traces: %v\n", err)
+	}
+	if err := t.metrics.ForceFlush(ctx); err != nil {
+		log.Printf("⚠️ Unable to export metrics: %v\n", err)
+	}
+}
+
+// shutdown flushes and stops the exporters before the process exits.
+func (t *telemetry) shutdown() {
+	if t == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryTimeout)
+	defer cancel()
+	if err := t.traces.Shutdown(ctx); err != nil {
+		log.Printf("⚠️ Unable to export traces: %v\n", err)
+	}
+	if err := t.metrics.Shutdown(ctx); err != nil {
+		log.Printf("⚠️ Unable to export metrics: %v\n", err)
+	}
+}
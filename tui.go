@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aslinger/s3-tidy/internal/humanize"
+	"github.com/aslinger/s3-tidy/pkg/policy"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// reviewGroup is one selectable row in the interactive review: every stale
+// object under a top-level prefix.
+type reviewGroup struct {
+	Prefix   string
+	Objects  []policy.Candidate
+	Bytes    int64
+	Savings  float64 // USD per month, at each object's storage class rate
+	Oldest   time.Time
+	Newest   time.Time
+	Selected bool
+}
+
+func (g *reviewGroup) add(c policy.Candidate) {
+	if len(g.Objects) == 0 || c.ModTime.Before(g.Oldest) {
+		g.Oldest = c.ModTime
+	}
+	if len(g.Objects) == 0 || c.ModTime.After(g.Newest) {
+		g.Newest = c.ModTime
+	}
+	g.Objects = append(g.Objects, c)
+	g.Bytes += c.Size
+	g.Savings += pricing.MonthlySavings(c.Size, c.StorageClass)
+}
+
+// groupForReview buckets candidates by top-level prefix, largest groups first.
+func groupForReview(cands []policy.Candidate) []*reviewGroup {
+	byPrefix := make(map[string]*reviewGroup)
+	for _, c := range cands {
+		prefix := policy.GroupKey(c.Key, 1)
+		g, ok := byPrefix[prefix]
+		if !ok {
+			g = &reviewGroup{Prefix: prefix}
+			byPrefix[prefix] = g
+		}
+		g.add(c)
+	}
+
+	groups := make([]*reviewGroup, 0, len(byPrefix))
+	for _, g := range byPrefix {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Bytes != groups[j].Bytes {
+			return groups[i].Bytes > groups[j].Bytes
+		}
+		return groups[i].Prefix < groups[j].Prefix
+	})
+	return groups
+}
+
+var (
+	tuiTitle    = lipgloss.NewStyle().Bold(true)
+	tuiCursor   = lipgloss.NewStyle().Foreground(lipgloss.Color("212")).Bold(true)
+	tuiSelected = lipgloss.NewStyle().Foreground(lipgloss.Color("203"))
+	tuiHelp     = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+)
+
+type reviewModel struct {
+	bucket   string
+	groups   []*reviewGroup
+	cursor   int
+	offset   int
+	height   int
+	now      time.Time
+	approved bool
+}
+
+func (m *reviewModel) Init() tea.Cmd { return nil }
+
+func (m *reviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		// Leave room for the header and footer lines.
+		m.height = max(msg.Height-7, 3)
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q", "esc":
+			m.approved = false
+			return m, tea.Quit
+		case "enter":
+			m.approved = true
+			return m, tea.Quit
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.groups)-1 {
+				m.cursor++
+			}
+		case " ", "x":
+			m.groups[m.cursor].Selected = !m.groups[m.cursor].Selected
+		case "a":
+			for _, g := range m.groups {
+				g.Selected = true
+			}
+		case "n":
+			for _, g := range m.groups {
+				g.Selected = false
+			}
+		}
+	}
+
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+m.height {
+		m.offset = m.cursor - m.height + 1
+	}
+	return m, nil
+}
+
+func (m *reviewModel) View() string {
+	var selObjects int
+	var selBytes int64
+	var selSavings float64
+	for _, g := range m.groups {
+		if g.Selected {
+			selObjects += len(g.Objects)
+			selBytes += g.Bytes
+			selSavings += g.Savings
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(tuiTitle.Render(fmt.Sprintf("🧹 Review stale objects in s3://%s", m.bucket)))
+	b.WriteString("\n")
+	b.WriteString(fmt.Sprintf("Selected: %d objects, %s (~%s/month)\n\n", selObjects, humanize.Bytes(selBytes), pricing.Format(selSavings, 2)))
+
+	end := min(m.offset+m.height, len(m.groups))
+	for i := m.offset; i < end; i++ {
+		g := m.groups[i]
+		check := "[ ]"
+		if g.Selected {
+			check = "[x]"
+		}
+		prefix := g.Prefix
+		if prefix == "" {
+			prefix = "(bucket root)"
+		}
+		line := fmt.Sprintf("%s %-40s %8d objs %10s   %s – %s",
+			check, prefix, len(g.Objects), humanize.Bytes(g.Bytes),
+			humanize.Age(m.now.Sub(g.Newest)), humanize.Age(m.now.Sub(g.Oldest)))
+
+		switch {
+		case i == m.cursor:
+			line = tuiCursor.Render("> " + line)
+		case g.Selected:
+			line = tuiSelected.Render("  " + line)
+		default:
+			line = "  " + line
+		}
+		b.WriteString(line + "\n")
+	}
+
+	b.WriteString("\n")
+	b.WriteString(tuiHelp.Render("↑/↓ move • space toggle • a all • n none • enter run selected • q abort"))
+	return b.String()
+}
+
+// reviewInteractively shows the stale candidates grouped by prefix and returns
+// only the objects the operator approved. Aborting approves nothing.
+func reviewInteractively(bucket string, cands []policy.Candidate, now time.Time) ([]policy.Candidate, error) {
+	if len(cands) == 0 {
+		return nil, nil
+	}
+
+	m := &reviewModel{bucket: bucket, groups: groupForReview(cands), height: 20, now: now}
+	if _, err := tea.NewProgram(m, tea.WithAltScreen()).Run(); err != nil {
+		return nil, err
+	}
+	if !m.approved {
+		return nil, nil
+	}
+
+	var approved []policy.Candidate
+	for _, g := range m.groups {
+		if g.Selected {
+			approved = append(approved, g.Objects...)
+		}
+	}
+	return approved, nil
+}
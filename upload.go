@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// uploadPartSize is the part size of streamed uploads. At 10,000 parts it
+// allows outputs up to ~320 GiB, and it is all the memory a stream holds.
+const uploadPartSize = 32 << 20
+
+// output is a file, or an S3 upload, behind a sink.
+type output interface {
+	io.WriteCloser
+	Name() string
+}
+
+// createOutput opens a local file, or for an s3://bucket/key destination a
+// multipart upload that is completed on Close, so outputs larger than the
+// disk (or Lambda's /tmp) never touch it.
+func createOutput(ctx context.Context, dest string) (output, error) {
+	rest, ok := strings.CutPrefix(dest, "s3://")
+	if !ok {
+		f, err := os.Create(dest)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	bucket, key, _ := strings.Cut(rest, "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("%s: want s3://bucket/key", dest)
+	}
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load SDK config: %w", err)
+	}
+	client := s3.NewFromConfig(cfg)
+	upload, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dest, err)
+	}
+	return &s3Upload{ctx: ctx, client: client, dest: dest, bucket: bucket, key: key, id: upload.UploadId}, nil
+}
+
+// s3Upload streams writes into the parts of one multipart upload.
+type s3Upload struct {
+	ctx         context.Context
+	client      *s3.Client
+	dest        string
+	bucket, key string
+	id          *string
+	buf         []byte
+	parts       []types.CompletedPart
+	err         error
+}
+
+func (u *s3Upload) Write(p []byte) (int, error) {
+	if u.err != nil {
+		return 0, u.err
+	}
+	u.buf = append(u.buf, p...)
+	for len(u.buf) >= uploadPartSize && u.err == nil {
+		u.uploadPart(u.buf[:uploadPartSize])
+		u.buf = append(u.buf[:0], u.buf[uploadPartSize:]...)
+	}
+	if u.err != nil {
+		return 0, u.err
+	}
+	return len(p), nil
+}
+
+func (u *s3Upload) uploadPart(data []byte) {
+	n := int32(len(u.parts) + 1)
+	out, err := u.client.UploadPart(u.ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(u.bucket),
+		Key:        aws.String(u.key),
+		UploadId:   u.id,
+		PartNumber: aws.Int32(n),
+		Body:       bytes.NewReader(data),
+	})
+	if err != nil {
+		u.err = fmt.Errorf("%s: upload part %d: %w", u.dest, n, err)
+		return
+	}
+	u.parts = append(u.parts, types.CompletedPart{ETag: out.ETag, PartNumber: aws.Int32(n)})
+}
+
+// Close uploads what is buffered and completes the upload, or aborts it
+// after a failed part so no orphaned parts keep costing storage.
+func (u *s3Upload) Close() error {
+	if u.err == nil && (len(u.buf) > 0 || len(u.parts) == 0) {
+		u.uploadPart(u.buf)
+	}
+	if u.err != nil {
+		u.client.AbortMultipartUpload(context.Background(), &s3.AbortMultipartUploadInput{Bucket: aws.String(u.bucket), Key: aws.String(u.key), UploadId: u.id})
+		return u.err
+	}
+	_, err := u.client.CompleteMultipartUpload(u.ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(u.bucket),
+		Key:             aws.String(u.key),
+		UploadId:        u.id,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: u.parts},
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", u.dest, err)
+	}
+	return nil
+}
+
+// Name labels the output in messages, like *os.File.
+func (u *s3Upload) Name() string { return u.dest }
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aslinger/s3-tidy/pkg/signing"
+	"github.com/spf13/cobra"
+)
+
+// Verify Manifest Flags
+var (
+	verifyManifestPath string
+	verifySigPath      string
+	verifyKeyPath      string
+)
+
+func newVerifyManifestCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify-manifest",
+		Short: "Check that a signed deletion manifest hasn't changed since it was signed",
+		Long: `Verifies the detached signature that 'scan --manifest-signing-key' wrote next
+to a manifest, with the same HMAC secret or the Ed25519 public key. Exits
+non-zero when the manifest was edited, the signature is from another key, or
+it doesn't verify, so change-management steps can gate on it.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			key, err := signing.LoadKey(verifyKeyPath)
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			_, sig, err := key.VerifyFile(verifyManifestPath, verifySigPath)
+			if err != nil {
+				log.Fatalf("❌ %s: %v", verifyManifestPath, err)
+			}
+			fmt.Printf("✅ %s verified: sha256 %s, signed %s by key %s (%s)\n", verifyManifestPath, sig.SHA256, sig.Signed.Format("2006-01-02 15:04 MST"), sig.KeyID, sig.Algorithm)
+		},
+	}
+	cmd.Flags().StringVar(&verifyManifestPath, "manifest", "", "Manifest written by scan --manifest (required)")
+	cmd.Flags().StringVar(&verifySigPath, "signature", "", "Signature file (default: <manifest>.sig)")
+	cmd.Flags().StringVar(&verifyKeyPath, "key", "", "HMAC secret, or the Ed25519 public (or private) key in PEM (required)")
+	cmd.MarkFlagRequired("manifest")
+	cmd.MarkFlagRequired("key")
+	return cmd
+}